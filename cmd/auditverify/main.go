@@ -0,0 +1,51 @@
+package main
+
+import (
+	"authorization_authentication/config"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/service"
+	"authorization_authentication/internal/storage"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"fmt"
+	"os"
+)
+
+// auditverify проходит цепочку журнала аудита и сверяет подписанные отметки.
+// Код выхода: 0 — цепочка цела, 1 — найдено нарушение, 2 — проверку не удалось выполнить.
+func main() {
+	logger.InitLogger()
+	cfg := config.LoadConfig()
+	storage.InitDB()
+	defer storage.CloseDB()
+
+	jwtService, err := service.NewJWTService(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to load signing keys:", err)
+		os.Exit(2)
+	}
+
+	auditRepo := repository.NewAuditRepository(storage.DB)
+	auditService := service.NewAuditService(*auditRepo, jwtService, nil)
+
+	report, err := auditService.VerifyChain(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to verify audit chain:", err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("Events checked:      %d\n", report.EventsChecked)
+	fmt.Printf("Last sequence:       %d\n", report.LastSeq)
+	fmt.Printf("Checkpoints checked: %d\n", report.CheckpointsChecked)
+
+	if report.Broken != nil {
+		fmt.Printf("\nBROKEN at seq %d", report.Broken.Seq)
+		if report.Broken.EventID != "" {
+			fmt.Printf(" (event %s)", report.Broken.EventID)
+		}
+		fmt.Printf(": %s\n", report.Broken.Reason)
+		os.Exit(1)
+	}
+
+	fmt.Println("\nChain OK")
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/service"
+	"authorization_authentication/internal/storage"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `Usage:
+  oauthclient create -name "Web app" -redirect-uri https://app.example.com/callback \
+      [-grant authorization_code,refresh_token] [-scope "profile email"] [-public]
+  oauthclient create-service -name "Billing" -scope "users:read"
+  oauthclient rotate -client-id <id> [-overlap 24h]
+
+Секреты выводятся один раз — сохраните их сразу.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	logger.InitLogger()
+	storage.InitDB()
+	defer storage.CloseDB()
+
+	clientRepo := repository.NewOAuthClientRepository(storage.DB)
+
+	switch os.Args[1] {
+	case "create":
+		createClient(clientRepo, os.Args[2:])
+	case "create-service":
+		createServiceAccount(clientRepo, os.Args[2:])
+	case "rotate":
+		rotateSecret(clientRepo, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func createClient(clientRepo *repository.OAuthClientRepository, args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	name := flags.String("name", "", "human-readable client name")
+	redirectURIs := flags.String("redirect-uri", "", "comma-separated list of allowed redirect URIs")
+	grantTypes := flags.String("grant", model.GrantAuthorizationCode+","+model.GrantRefreshToken, "comma-separated list of allowed grant types")
+	scopes := flags.String("scope", "", "space-separated list of allowed scopes")
+	public := flags.Bool("public", false, "public client without a secret (SPA, mobile)")
+	flags.Parse(args)
+
+	client, err := service.NewOAuthClient(
+		*name,
+		splitList(*redirectURIs),
+		splitList(*grantTypes),
+		model.ParseScope(*scopes),
+		!*public,
+	)
+	if err != nil {
+		logger.Log.Fatal(err)
+	}
+
+	saveClient(clientRepo, client)
+}
+
+func createServiceAccount(clientRepo *repository.OAuthClientRepository, args []string) {
+	flags := flag.NewFlagSet("create-service", flag.ExitOnError)
+	name := flags.String("name", "", "human-readable service name")
+	scopes := flags.String("scope", "", "space-separated list of allowed scopes")
+	flags.Parse(args)
+
+	client, err := service.NewServiceAccount(*name, model.ParseScope(*scopes))
+	if err != nil {
+		logger.Log.Fatal(err)
+	}
+
+	saveClient(clientRepo, client)
+}
+
+func saveClient(clientRepo *repository.OAuthClientRepository, client *model.OAuthClient) {
+	var secret *model.OAuthClientSecret
+	var plain string
+	if client.IsConfidential() {
+		var err error
+		secret, plain, err = service.NewClientSecret(client.ID)
+		if err != nil {
+			logger.Log.Fatal(err)
+		}
+	}
+
+	if err := clientRepo.CreateClient(context.Background(), client, secret); err != nil {
+		logger.Log.Fatal(err)
+	}
+
+	fmt.Println("client_id:    ", client.ID)
+	if plain != "" {
+		fmt.Println("client_secret:", plain)
+	}
+}
+
+func rotateSecret(clientRepo *repository.OAuthClientRepository, args []string) {
+	flags := flag.NewFlagSet("rotate", flag.ExitOnError)
+	clientID := flags.String("client-id", "", "client to rotate the secret for")
+	overlap := flags.Duration("overlap", service.DefaultSecretRotationOverlap, "how long previous secrets stay valid")
+	flags.Parse(args)
+
+	ctx := context.Background()
+	client, err := clientRepo.GetClientByID(ctx, *clientID)
+	if err != nil {
+		logger.Log.Fatal(err)
+	}
+	if !client.IsConfidential() {
+		logger.Log.Fatal("public clients have no secret to rotate")
+	}
+
+	secret, plain, err := service.NewClientSecret(client.ID)
+	if err != nil {
+		logger.Log.Fatal(err)
+	}
+
+	previousExpiresAt := secret.CreatedAt.Add(*overlap)
+	if err := clientRepo.RotateSecret(ctx, secret, previousExpiresAt); err != nil {
+		logger.Log.Fatal(err)
+	}
+
+	fmt.Println("client_secret:", plain)
+	fmt.Println("previous secrets valid until:", previousExpiresAt.Format(time.RFC3339))
+}
+
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
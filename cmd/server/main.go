@@ -3,9 +3,12 @@ package main
 import (
 	"authorization_authentication/config"
 	"authorization_authentication/internal/handler"
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/ratelimit"
 	"authorization_authentication/internal/repository"
 	"authorization_authentication/internal/service"
 	"authorization_authentication/internal/storage"
+	"authorization_authentication/internal/util"
 	"authorization_authentication/pkg/logger"
 	"context"
 	_ "github.com/lib/pq"
@@ -13,6 +16,7 @@ import (
 	"github.com/twilio/twilio-go"
 	"log"
 	"net/http"
+	"slices"
 )
 
 func main() {
@@ -34,24 +38,240 @@ func main() {
 
 	userRepo := repository.NewUserRepository(storage.DB)
 	sessionRepo := repository.NewSessionRepository(storage.DB)
+	oauthClientRepo := repository.NewOAuthClientRepository(storage.DB)
+	apiKeyRepo := repository.NewAPIKeyRepository(storage.DB)
+	mfaRepo := repository.NewMFARepository(storage.DB)
+	auditRepo := repository.NewAuditRepository(storage.DB)
+	webAuthnRepo := repository.NewWebAuthnRepository(storage.DB)
 	jwtService, err := service.NewJWTService(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	authService := service.NewAuthService(*userRepo, *sessionRepo, jwtService, redisClient, twilioClient, cfg.ServiceSID)
+	phones, err := util.NewPhoneNormalizer(cfg.DefaultPhoneRegion)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var blocklist *util.DomainBlocklist
+	if cfg.EmailBlocklistPath != "" {
+		blocklist, err = util.LoadDomainBlocklist(cfg.EmailBlocklistPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var geo *util.GeoIPTable
+	if cfg.GeoIPTablePath != "" {
+		geo, err = util.LoadGeoIPTable(cfg.GeoIPTablePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	verifier := service.NewTwilioVerifier(twilioClient, cfg.ServiceSID)
+	smsSender := service.NewTwilioSMSSender(twilioClient, cfg.FromPhone)
+	var mailer service.Mailer = service.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	if cfg.MailDropDir != "" {
+		mailer, err = service.NewFileMailer(cfg.MailDropDir, cfg.MailFrom)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	limiter := ratelimit.NewRedisLimiter(redisClient)
+	mfaService, err := service.NewMFAService(*mfaRepo, *userRepo, verifier, limiter, cfg.MFAEncryptionKey, cfg.MFAIssuer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter, err := newEventExporter(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auditService := service.NewAuditService(*auditRepo, jwtService, exporter)
+	emailVerificationService := service.NewEmailVerificationService(*userRepo, mailer, redisClient, auditService, cfg.PublicURL)
+	passwordService := service.NewPasswordService(*userRepo, *sessionRepo, auditService, mailer, redisClient, cfg.PublicURL)
+	loginAlertSender := service.NewMessageLoginAlertSender(mailer, smsSender)
+	loginAlertService := service.NewLoginAlertService(*userRepo, *sessionRepo, passwordService, auditService, loginAlertSender, geo, redisClient, cfg.PublicURL)
+	var captcha service.CaptchaVerifier = service.NewStubCaptchaVerifier(cfg.CaptchaStubToken)
+	if cfg.CaptchaVerifyURL != "" {
+		captcha = service.NewSiteVerifyCaptcha(cfg.CaptchaVerifyURL, cfg.CaptchaSecret)
+	}
+	ipReputation, err := service.NewIPReputationService(limiter, cfg.IPBlockIPv4Prefix, cfg.IPBlockIPv6Prefix, cfg.IPAllowlistPath, cfg.IPDenylistPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	authService := service.NewAuthService(*userRepo, *sessionRepo, jwtService, mfaService, emailVerificationService, redisClient, limiter, limiter, captcha, ipReputation, verifier, phones, blocklist, auditService, loginAlertService)
+	oauthService := service.NewOAuthService(*oauthClientRepo, authService, jwtService, redisClient)
+	accountService := service.NewAccountService(*userRepo, auditService, mailer, smsSender, verifier, redisClient, phones, blocklist, cfg.PublicURL)
+	profileService := service.NewProfileService(*userRepo, auditService)
+	privacyService := service.NewPrivacyService(*userRepo, *sessionRepo, *apiKeyRepo, *webAuthnRepo, *mfaRepo, *auditRepo, authService, auditService, cfg.AccountDeletionGracePeriod)
+	adminService := service.NewAdminService(*userRepo, *sessionRepo, passwordService, privacyService, auditService, ipReputation, phones)
+	magicLinkService := service.NewMagicLinkService(*userRepo, authService, mailer, redisClient, cfg.PublicURL)
+	apiKeyService := service.NewAPIKeyService(*apiKeyRepo)
+	webAuthnService, err := service.NewWebAuthnService(
+		*webAuthnRepo,
+		*userRepo,
+		authService,
+		mfaService,
+		redisClient,
+		limiter,
+		cfg.WebAuthnRPID,
+		cfg.WebAuthnRPName,
+		cfg.WebAuthnRPOrigins,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
 	authHandler := handlers.NewAuthHandler(authService)
+	magicLinkHandler := handlers.NewMagicLinkHandler(magicLinkService)
+	emailVerificationHandler := handlers.NewEmailVerificationHandler(emailVerificationService)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	profileHandler := handlers.NewProfileHandler(profileService)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService)
+	passwordHandler := handlers.NewPasswordHandler(passwordService)
+	loginAlertHandler := handlers.NewLoginAlertHandler(loginAlertService)
+	adminHandler := handlers.NewAdminHandler(adminService)
+	securityActivityHandler := handlers.NewSecurityActivityHandler(auditService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	webAuthnHandler := handlers.NewWebAuthnHandler(webAuthnService)
+	authenticator := handlers.NewAuthenticator(jwtService, apiKeyService, authService)
+	rateLimiter := handlers.NewRateLimiter(limiter, ipReputation)
+	clientIPs, err := util.NewClientIPResolver(cfg.TrustedProxies, cfg.TrustedProxyHeader)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	loginLimit := ratePolicy("login", cfg.RateLimitLogin, cfg.RateLimitFailClosed)
+	registerLimit := ratePolicy("register", cfg.RateLimitRegister, cfg.RateLimitFailClosed)
+	refreshLimit := ratePolicy("refresh", cfg.RateLimitRefresh, cfg.RateLimitFailClosed)
+	verifyPhoneLimit := ratePolicy("verify_phone", cfg.RateLimitVerifyPhone, cfg.RateLimitFailClosed)
+	resendLimit := ratePolicy("resend", cfg.RateLimitResend, cfg.RateLimitFailClosed)
 
 	// Запускаем фоновую очистку
 	ctx := context.Background()
-	authService.StartCleanupRoutine(ctx)
+	oauthService.StartCleanupRoutine(ctx)
+	apiKeyService.StartLastUsedWorker(ctx)
+	privacyService.StartPurgeRoutine(ctx)
+	auditService.StartCheckpointRoutine(ctx, cfg.AuditCheckpointInterval)
+	exporter.Start(ctx)
+	ipReputation.StartReloadRoutine(ctx, cfg.IPListReloadInterval)
 
-	http.HandleFunc("/register", authHandler.Register)
-	http.HandleFunc("/login", authHandler.Login)
-	http.HandleFunc("/refresh", authHandler.Refresh)
-	http.HandleFunc("/verify", authHandler.Verify)
+	http.HandleFunc("/register", rateLimiter.ByIP(registerLimit, authHandler.Register))
+	http.HandleFunc("/login", rateLimiter.ByIP(loginLimit, authHandler.Login))
+	http.HandleFunc("/login/mfa", authHandler.LoginMFA)
+	http.HandleFunc("/login/mfa/sms", authHandler.SendMFASMS)
+	http.HandleFunc("POST /login/magic-link", magicLinkHandler.Request)
+	http.HandleFunc("GET /login/magic-link/consume", magicLinkHandler.Consume)
+	http.HandleFunc("POST /login/magic-link/consume", magicLinkHandler.Consume)
+	http.HandleFunc("/refresh", rateLimiter.ByIP(refreshLimit, authHandler.Refresh))
 	http.HandleFunc("/logout", authHandler.Logout)
-	http.HandleFunc("/verify-phone", authHandler.VerifyPhone)
+	http.HandleFunc("/verify-phone", rateLimiter.ByIP(verifyPhoneLimit, authHandler.VerifyPhone))
+	http.HandleFunc("POST /password/reset", passwordHandler.Reset)
+	http.HandleFunc("GET /login/not-me", loginAlertHandler.NotMePage)
+	http.HandleFunc("POST /login/not-me", loginAlertHandler.NotMe)
+	http.HandleFunc("GET /verify-email", emailVerificationHandler.Verify)
+	http.HandleFunc("POST /verify-email", emailVerificationHandler.Verify)
+	http.HandleFunc("POST /verify-email/resend", authenticator.RequireScope(model.ScopeProfileWrite, rateLimiter.ByUser(resendLimit, emailVerificationHandler.Resend)))
+
+	// OAuth 2.0
+	http.HandleFunc("/authorize", oauthHandler.Authorize)
+	http.HandleFunc("/token", oauthHandler.Token)
+	http.HandleFunc("/introspect", oauthHandler.Introspect)
+	http.HandleFunc("/client/rotate-secret", oauthHandler.RotateSecret)
+
+	// Профиль текущего пользователя
+	http.HandleFunc("GET /me", authenticator.RequireScope(model.ScopeProfileRead, profileHandler.Get))
+	http.HandleFunc("PATCH /me", authenticator.AuthenticateInteractive(profileHandler.Update))
+	http.HandleFunc("DELETE /me", authenticator.AuthenticateInteractive(privacyHandler.DeleteAccount))
+	http.HandleFunc("GET /me/export", authenticator.AuthenticateInteractive(privacyHandler.Export))
+	http.HandleFunc("GET /me/security-activity", authenticator.RequireScope(model.ScopeSecurityRead, securityActivityHandler.List))
+	http.HandleFunc("POST /account/restore", privacyHandler.RestoreAccount)
+
+	// Смена email и телефона
+	http.HandleFunc("POST /account/email", authenticator.AuthenticateInteractive(accountHandler.ChangeEmail))
+	http.HandleFunc("GET /account/email/confirm", accountHandler.ConfirmEmail)
+	http.HandleFunc("POST /account/email/confirm", accountHandler.ConfirmEmail)
+	http.HandleFunc("POST /account/phone", authenticator.AuthenticateInteractive(accountHandler.ChangePhone))
+	http.HandleFunc("POST /account/phone/confirm", authenticator.AuthenticateInteractive(accountHandler.ConfirmPhone))
+
+	// Персональные API-ключи
+	http.HandleFunc("POST /api-keys", authenticator.AuthenticateInteractive(apiKeyHandler.Create))
+	http.HandleFunc("GET /api-keys", authenticator.RequireScope(model.ScopeAPIKeysRead, apiKeyHandler.List))
+	http.HandleFunc("DELETE /api-keys/{id}", authenticator.AuthenticateInteractive(apiKeyHandler.Revoke))
+
+	// Двухфакторная аутентификация
+	http.HandleFunc("GET /mfa", authenticator.AuthenticateInteractive(mfaHandler.Status))
+	http.HandleFunc("POST /mfa/totp/enroll", authenticator.AuthenticateInteractive(mfaHandler.EnrollTOTP))
+	http.HandleFunc("POST /mfa/totp/confirm", authenticator.AuthenticateInteractive(mfaHandler.ConfirmTOTP))
+	http.HandleFunc("DELETE /mfa/totp", authenticator.AuthenticateInteractive(mfaHandler.DisableTOTP))
+	http.HandleFunc("POST /mfa/recovery-codes", authenticator.AuthenticateInteractive(mfaHandler.RegenerateRecoveryCodes))
+	http.HandleFunc("POST /mfa/sms/enable", authenticator.AuthenticateInteractive(mfaHandler.EnableSMS))
+	http.HandleFunc("POST /mfa/sms/code", authenticator.AuthenticateInteractive(mfaHandler.SendSMSCode))
+	http.HandleFunc("DELETE /mfa/sms", authenticator.AuthenticateInteractive(mfaHandler.DisableSMS))
+
+	// Passkeys (WebAuthn)
+	http.HandleFunc("POST /webauthn/register/options", authenticator.AuthenticateInteractive(webAuthnHandler.RegisterOptions))
+	http.HandleFunc("POST /webauthn/register/finish", authenticator.AuthenticateInteractive(webAuthnHandler.RegisterFinish))
+	http.HandleFunc("POST /webauthn/login/options", webAuthnHandler.LoginOptions)
+	http.HandleFunc("POST /webauthn/login/finish", webAuthnHandler.LoginFinish)
+	http.HandleFunc("GET /webauthn/credentials", authenticator.AuthenticateInteractive(webAuthnHandler.ListCredentials))
+	http.HandleFunc("DELETE /webauthn/credentials/{id}", authenticator.AuthenticateInteractive(webAuthnHandler.DeleteCredential))
+
+	// Администрирование пользователей
+	admin := func(next http.HandlerFunc) http.HandlerFunc {
+		return authenticator.AuthenticateInteractive(adminHandler.RequireAdmin(next))
+	}
+	http.HandleFunc("GET /admin/users", admin(adminHandler.SearchUsers))
+	http.HandleFunc("GET /admin/users/{id}", admin(adminHandler.GetUser))
+	http.HandleFunc("DELETE /admin/users/{id}", admin(adminHandler.DeleteUser))
+	http.HandleFunc("GET /admin/users/{id}/sessions", admin(adminHandler.ListSessions))
+	http.HandleFunc("DELETE /admin/users/{id}/sessions", admin(adminHandler.RevokeSessions))
+	http.HandleFunc("POST /admin/users/{id}/verify", admin(adminHandler.Verify))
+	http.HandleFunc("POST /admin/users/{id}/status", admin(adminHandler.SetStatus))
+	http.HandleFunc("POST /admin/users/{id}/password-reset", admin(adminHandler.ForcePasswordReset))
+	http.HandleFunc("GET /admin/audit", admin(adminHandler.QueryAudit))
+	http.HandleFunc("GET /admin/ip-blocks", admin(adminHandler.ListIPBlocks))
+	http.HandleFunc("DELETE /admin/ip-blocks", admin(adminHandler.ClearIPBlock))
 
 	logger.Log.Println("Auth service running on :8080")
-	logger.Log.Fatal(http.ListenAndServe(":8080", nil))
+	logger.Log.Fatal(http.ListenAndServe(":8080", handlers.RequestContext(clientIPs, handlers.DenyListed(ipReputation, http.DefaultServeMux))))
+}
+
+// newEventExporter подключает приемники событий журнала, заданные в конфигурации
+func newEventExporter(cfg *config.Config) (*service.EventExporter, error) {
+	exporter := service.NewEventExporter()
+
+	if cfg.AuditSyslogURL != "" {
+		sink, err := service.NewSyslogSink(cfg.AuditSyslogURL, cfg.AuditSyslogFormat)
+		if err != nil {
+			return nil, err
+		}
+		exporter.AddSink("syslog", sink, cfg.AuditSyslogEvents)
+	}
+
+	if cfg.AuditFilePath != "" {
+		sink, err := service.NewFileSink(cfg.AuditFilePath, int64(cfg.AuditFileMaxSizeMB)<<20, cfg.AuditFileMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		exporter.AddSink("file", sink, cfg.AuditFileEvents)
+	}
+
+	if cfg.AuditWebhookURL != "" {
+		sink, err := service.NewWebhookSink(cfg.AuditWebhookURL, cfg.AuditWebhookSecret, cfg.AuditWebhookMaxRetries)
+		if err != nil {
+			return nil, err
+		}
+		exporter.AddSink("webhook", sink, cfg.AuditWebhookEvents)
+	}
+
+	return exporter, nil
+}
+
+// ratePolicy разбирает правило ограничения частоты из конфигурации
+func ratePolicy(name, spec string, failClosed []string) ratelimit.Policy {
+	policy, err := ratelimit.ParsePolicy(name, spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy.FailClosed = slices.Contains(failClosed, name)
+	return policy
 }
@@ -3,6 +3,9 @@ package config
 import (
 	"authorization_authentication/pkg/logger"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -22,6 +25,73 @@ type Config struct {
 	AuthToken     string
 	ServiceSID    string
 	FromPhone     string
+
+	DefaultPhoneRegion string // ISO 3166-1 alpha-2 для номеров без кода страны
+	EmailBlocklistPath string // Файл с доменами одноразовой почты, по одному в строке
+	GeoIPTablePath     string // Файл "CIDR,местоположение" для уведомлений о входе
+
+	MFAEncryptionKey string // base64, 32 байта: ключ шифрования TOTP-секретов
+	MFAIssuer        string // Имя сервиса в приложении-аутентификаторе
+
+	WebAuthnRPID      string // Домен relying party; пустой отключает passkeys
+	WebAuthnRPName    string
+	WebAuthnRPOrigins []string // Разрешенные origin страниц, с которых вызывается WebAuthn
+
+	PublicURL    string // Внешний адрес сервиса для ссылок в письмах
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+	MailDropDir  string // Если задан, письма сохраняются в каталог вместо отправки по SMTP
+
+	AccountDeletionGracePeriod time.Duration // Сколько удаленный аккаунт можно восстановить
+	AuditCheckpointInterval    time.Duration // Как часто подписывается голова цепочки журнала
+
+	// Экспорт событий журнала. Пустой адрес/путь отключает приемник,
+	// пустой список типов — отправлять все события.
+	AuditSyslogURL         string // udp://host:514 или tcp://host:601
+	AuditSyslogFormat      string // json или cef
+	AuditSyslogEvents      []string
+	AuditFilePath          string // JSON Lines с ротацией по размеру
+	AuditFileMaxSizeMB     int
+	AuditFileMaxBackups    int
+	AuditFileEvents        []string
+	AuditWebhookURL        string
+	AuditWebhookSecret     string // Ключ HMAC-подписи запросов
+	AuditWebhookMaxRetries int
+	AuditWebhookEvents     []string
+
+	// Ограничения частоты запросов по IP: "20/1m" — скользящее окно, "bucket:30/1m" — token bucket
+	RateLimitLogin       string
+	RateLimitRegister    string
+	RateLimitRefresh     string
+	RateLimitVerifyPhone string
+	RateLimitResend      string // Повторная отправка письма подтверждения, по пользователю
+	// Правила, по которым запросы отклоняются, пока хранилище счетчиков недоступно;
+	// остальные в этом случае пропускаются
+	RateLimitFailClosed []string
+
+	// CAPTCHA после серии неудачных входов. Без CaptchaVerifyURL используется локальная
+	// заглушка, принимающая только CaptchaStubToken (пустой — отклоняет все ответы).
+	CaptchaVerifyURL string // siteverify reCAPTCHA, hCaptcha или Turnstile
+	CaptchaSecret    string
+	CaptchaStubToken string
+
+	// Блокировка после неудачных входов считается по сетям с такой длиной префикса,
+	// по ним же считаются ограничения частоты по IP
+	IPBlockIPv4Prefix int
+	IPBlockIPv6Prefix int
+	// Файлы сетей (CIDR или адрес в строке): разрешенные не блокируются и не ограничиваются
+	// по частоте, запрещенные не получают доступа. Перечитываются при изменении.
+	IPAllowlistPath      string
+	IPDenylistPath       string
+	IPListReloadInterval time.Duration
+
+	// Адрес клиента берется из TrustedProxyHeader (Forwarded, X-Forwarded-For или X-Real-IP),
+	// только если соединение пришло из одной из TrustedProxies. Пустой список — заголовок игнорируется.
+	TrustedProxies     []string
+	TrustedProxyHeader string
 }
 
 func LoadConfig() *Config {
@@ -44,6 +114,60 @@ func LoadConfig() *Config {
 		AuthToken:     getEnv("AUTH_TOKEN", ""),
 		ServiceSID:    getEnv("SERVICE_SID", ""),
 		FromPhone:     getEnv("FROM_PHONE", ""),
+
+		DefaultPhoneRegion: getEnv("DEFAULT_PHONE_REGION", ""),
+		EmailBlocklistPath: getEnv("EMAIL_DOMAIN_BLOCKLIST", ""),
+		GeoIPTablePath:     getEnv("GEOIP_TABLE_PATH", ""),
+
+		MFAEncryptionKey: getEnv("MFA_ENCRYPTION_KEY", ""),
+		MFAIssuer:        getEnv("MFA_ISSUER", "Auth"),
+
+		WebAuthnRPID:      getEnv("WEBAUTHN_RP_ID", ""),
+		WebAuthnRPName:    getEnv("WEBAUTHN_RP_NAME", "Auth"),
+		WebAuthnRPOrigins: getEnvList("WEBAUTHN_RP_ORIGINS", nil),
+
+		PublicURL:    getEnv("PUBLIC_URL", "http://localhost:8080"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@localhost"),
+		MailDropDir:  getEnv("MAIL_DROP_DIR", ""),
+
+		AccountDeletionGracePeriod: getEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour),
+		AuditCheckpointInterval:    getEnvDuration("AUDIT_CHECKPOINT_INTERVAL", time.Hour),
+
+		AuditSyslogURL:         getEnv("AUDIT_SYSLOG_URL", ""),
+		AuditSyslogFormat:      getEnv("AUDIT_SYSLOG_FORMAT", "json"),
+		AuditSyslogEvents:      getEnvList("AUDIT_SYSLOG_EVENTS", nil),
+		AuditFilePath:          getEnv("AUDIT_FILE_PATH", ""),
+		AuditFileMaxSizeMB:     getEnvInt("AUDIT_FILE_MAX_SIZE_MB", 100),
+		AuditFileMaxBackups:    getEnvInt("AUDIT_FILE_MAX_BACKUPS", 5),
+		AuditFileEvents:        getEnvList("AUDIT_FILE_EVENTS", nil),
+		AuditWebhookURL:        getEnv("AUDIT_WEBHOOK_URL", ""),
+		AuditWebhookSecret:     getEnv("AUDIT_WEBHOOK_SECRET", ""),
+		AuditWebhookMaxRetries: getEnvInt("AUDIT_WEBHOOK_MAX_RETRIES", 5),
+		AuditWebhookEvents:     getEnvList("AUDIT_WEBHOOK_EVENTS", nil),
+
+		RateLimitLogin:       getEnv("RATE_LIMIT_LOGIN", "20/1m"),
+		RateLimitRegister:    getEnv("RATE_LIMIT_REGISTER", "5/1h"),
+		RateLimitRefresh:     getEnv("RATE_LIMIT_REFRESH", "bucket:30/1m"),
+		RateLimitVerifyPhone: getEnv("RATE_LIMIT_VERIFY_PHONE", "10/15m"),
+		RateLimitResend:      getEnv("RATE_LIMIT_RESEND", "3/15m"),
+		RateLimitFailClosed:  getEnvList("RATE_LIMIT_FAIL_CLOSED", []string{"login"}),
+
+		CaptchaVerifyURL: getEnv("CAPTCHA_VERIFY_URL", ""),
+		CaptchaSecret:    getEnv("CAPTCHA_SECRET", ""),
+		CaptchaStubToken: getEnv("CAPTCHA_STUB_TOKEN", ""),
+
+		IPBlockIPv4Prefix:    getEnvInt("IP_BLOCK_IPV4_PREFIX", 32),
+		IPBlockIPv6Prefix:    getEnvInt("IP_BLOCK_IPV6_PREFIX", 64),
+		IPAllowlistPath:      getEnv("IP_ALLOWLIST_PATH", ""),
+		IPDenylistPath:       getEnv("IP_DENYLIST_PATH", ""),
+		IPListReloadInterval: getEnvDuration("IP_LIST_RELOAD_INTERVAL", 30*time.Second),
+
+		TrustedProxies:     getEnvList("TRUSTED_PROXIES", nil),
+		TrustedProxyHeader: getEnv("TRUSTED_PROXY_HEADER", "X-Forwarded-For"),
 	}
 }
 
@@ -53,3 +177,49 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getEnvList читает список значений через запятую
+func getEnvList(key string, fallback []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvInt читает целое число
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Log.Warnf("Invalid %s %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getEnvDuration читает длительность в формате time.ParseDuration ("720h")
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Log.Warnf("Invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"authorization_authentication/internal/util"
+	"encoding/json"
+	"net/http"
+)
+
+type AccountHandler struct {
+	accountService *service.AccountService
+}
+
+func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+func (h *AccountHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	var req struct {
+		NewEmail string `json:"new_email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if req.NewEmail == "" || req.Password == "" {
+		sendErrorResponse(w, "new_email and password are required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err := h.accountService.RequestEmailChange(r.Context(), principal.UserID, req.Password, req.NewEmail, r.UserAgent(), clientIP(r))
+	if err != nil {
+		h.sendAccountError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, map[string]string{"message": "Confirmation sent to the new email address"}, http.StatusAccepted)
+}
+
+// ConfirmEmail принимает токен из ссылки в письме (GET) или из тела запроса (POST)
+func (h *AccountHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := r.URL.Query().Get("token")
+	if r.Method == http.MethodPost {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	}
+
+	if err := h.accountService.ConfirmEmailChange(r.Context(), token, r.UserAgent(), clientIP(r)); err != nil {
+		h.sendAccountError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, map[string]string{"message": "Email address changed"}, http.StatusOK)
+}
+
+func (h *AccountHandler) ChangePhone(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	var req struct {
+		NewPhone string `json:"new_phone"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if req.NewPhone == "" || req.Password == "" {
+		sendErrorResponse(w, "new_phone and password are required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	err := h.accountService.RequestPhoneChange(r.Context(), principal.UserID, req.Password, req.NewPhone, r.UserAgent(), clientIP(r))
+	if err != nil {
+		h.sendAccountError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, map[string]string{"message": "Verification code sent to the new phone number"}, http.StatusAccepted)
+}
+
+func (h *AccountHandler) ConfirmPhone(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if req.Code == "" {
+		sendErrorResponse(w, "code is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := h.accountService.ConfirmPhoneChange(r.Context(), principal.UserID, req.Code, r.UserAgent(), clientIP(r)); err != nil {
+		h.sendAccountError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, map[string]string{"message": "Phone number changed"}, http.StatusOK)
+}
+
+func (h *AccountHandler) sendAccountError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch err {
+	case model.ErrInvalidCredentials:
+		status = http.StatusUnauthorized
+	case model.ErrVerificationFailed:
+		status = http.StatusForbidden
+	case model.ErrUserAlreadyExists, model.ErrPhoneAlreadyInUse:
+		status = http.StatusConflict
+	case model.ErrSameContact, model.ErrNoPendingChange, model.ErrEmailVerificationFailed,
+		util.ErrInvalidPhone, util.ErrPhoneNotAllowed, util.ErrInvalidEmail, util.ErrEmailNotAllowed:
+		status = http.StatusBadRequest
+	case model.ErrTooManyCodeRequests:
+		status = http.StatusTooManyRequests
+	case model.ErrUserNotFound:
+		status = http.StatusNotFound
+	default:
+		sendErrorResponse(w, "Account update failed", status)
+		return
+	}
+	sendErrorResponse(w, err.Error(), status)
+}
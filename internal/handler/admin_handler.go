@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type AdminHandler struct {
+	adminService *service.AdminService
+}
+
+func NewAdminHandler(adminService *service.AdminService) *AdminHandler {
+	return &AdminHandler{adminService: adminService}
+}
+
+// RequireAdmin пропускает только администраторов. Ставится после AuthenticateInteractive.
+func (h *AdminHandler) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromContext(r.Context())
+
+		// Токены OAuth-клиентов ограничены своими scope и не дают прав администратора
+		if principal.ClientID != "" {
+			sendErrorResponse(w, "Admin role required", http.StatusForbidden)
+			return
+		}
+
+		isAdmin, err := h.adminService.IsAdmin(r.Context(), principal.UserID)
+		if err != nil {
+			sendErrorResponse(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if !isAdmin {
+			sendErrorResponse(w, "Admin role required", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// SearchUsers GET /admin/users?email=&phone=&limit=&offset=
+func (h *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := model.UserSearch{
+		Email: query.Get("email"),
+		Phone: query.Get("phone"),
+	}
+
+	var err error
+	if filter.Limit, filter.Offset, err = parsePagination(query); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.adminService.SearchUsers(r.Context(), adminActor(r), filter)
+	if err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, page, http.StatusOK)
+}
+
+// QueryAudit GET /admin/audit?user_id=&actor_id=&type=&outcome=&ip=&from=&to=&limit=&offset=
+func (h *AdminHandler) QueryAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := model.AuditQuery{
+		UserID:  query.Get("user_id"),
+		ActorID: query.Get("actor_id"),
+		Type:    query.Get("type"),
+		Outcome: query.Get("outcome"),
+		IP:      query.Get("ip"),
+	}
+
+	var err error
+	if filter.Limit, filter.Offset, err = parsePagination(query); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.From, err = parseTimeParam(query, "from"); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query, "to"); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.adminService.QueryAudit(r.Context(), adminActor(r), filter)
+	if err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, page, http.StatusOK)
+}
+
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.adminService.GetUser(r.Context(), adminActor(r), r.PathValue("id"))
+	if err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, user, http.StatusOK)
+}
+
+func (h *AdminHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.adminService.ListSessions(r.Context(), adminActor(r), r.PathValue("id"))
+	if err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, sessions, http.StatusOK)
+}
+
+func (h *AdminHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	if err := h.adminService.RevokeSessions(r.Context(), adminActor(r), r.PathValue("id")); err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, nil, http.StatusOK)
+}
+
+func (h *AdminHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var req model.AdminVerify
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	if !req.Email && !req.Phone {
+		sendErrorResponse(w, "email or phone must be true", http.StatusUnprocessableEntity)
+		return
+	}
+
+	user, err := h.adminService.ForceVerify(r.Context(), adminActor(r), r.PathValue("id"), req)
+	if err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, user, http.StatusOK)
+}
+
+// SetStatus активирует, приостанавливает до даты или отключает аккаунт
+func (h *AdminHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	var req model.StatusChange
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.adminService.SetStatus(r.Context(), adminActor(r), r.PathValue("id"), req)
+	if err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, user, http.StatusOK)
+}
+
+func (h *AdminHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	if err := h.adminService.ForcePasswordReset(r.Context(), adminActor(r), r.PathValue("id")); err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, nil, http.StatusOK)
+}
+
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	purgeAt, err := h.adminService.DeleteUser(r.Context(), adminActor(r), r.PathValue("id"))
+	if err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, map[string]string{
+		"status":   "scheduled",
+		"purge_at": purgeAt.Format(time.RFC3339),
+	}, http.StatusAccepted)
+}
+
+// ListIPBlocks GET /admin/ip-blocks
+func (h *AdminHandler) ListIPBlocks(w http.ResponseWriter, r *http.Request) {
+	blocks, err := h.adminService.ListIPBlocks(r.Context(), adminActor(r))
+	if err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, blocks, http.StatusOK)
+}
+
+// ClearIPBlock DELETE /admin/ip-blocks?network=203.0.113.0/24. Сеть передается параметром,
+// потому что содержит "/".
+func (h *AdminHandler) ClearIPBlock(w http.ResponseWriter, r *http.Request) {
+	if err := h.adminService.ClearIPBlock(r.Context(), adminActor(r), r.URL.Query().Get("network")); err != nil {
+		h.sendAdminError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, nil, http.StatusOK)
+}
+
+func (h *AdminHandler) sendAdminError(w http.ResponseWriter, err error) {
+	var verr *model.ValidationError
+	if errors.As(err, &verr) {
+		sendValidationError(w, verr)
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch err {
+	case model.ErrUserNotFound, model.ErrIPBlockNotFound:
+		status = http.StatusNotFound
+	case model.ErrAdminSelfAction, model.ErrInvalidNetwork:
+		status = http.StatusBadRequest
+	case model.ErrPhoneAlreadyInUse:
+		status = http.StatusConflict
+	default:
+		sendErrorResponse(w, "Admin operation failed", status)
+		return
+	}
+	sendErrorResponse(w, err.Error(), status)
+}
+
+func adminActor(r *http.Request) service.AdminActor {
+	principal, _ := PrincipalFromContext(r.Context())
+	return service.AdminActor{
+		UserID:    principal.UserID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// Create выпускает новый API-ключ. Ключ целиком показывается только в этом ответе.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	var req struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	key, rawKey, err := h.apiKeyService.CreateAPIKey(r.Context(), principal.UserID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sendSuccessResponse(w, map[string]interface{}{
+		"key":     rawKey,
+		"api_key": key,
+	}, http.StatusCreated)
+}
+
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	keys, err := h.apiKeyService.ListAPIKeys(r.Context(), principal.UserID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to list API keys", http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []*model.APIKey{}
+	}
+
+	sendSuccessResponse(w, keys, http.StatusOK)
+}
+
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	if err := h.apiKeyService.RevokeAPIKey(r.Context(), principal.UserID, r.PathValue("id")); err != nil {
+		status := http.StatusInternalServerError
+		if err == model.ErrAPIKeyNotFound {
+			status = http.StatusNotFound
+		}
+		sendErrorResponse(w, err.Error(), status)
+		return
+	}
+
+	sendSuccessResponse(w, nil, http.StatusOK)
+}
@@ -5,19 +5,12 @@ import (
 	"authorization_authentication/internal/service"
 	"encoding/json"
 	"net/http"
-	"strings"
 )
 
 type AuthHandler struct {
 	authService *service.AuthService
 }
 
-type Response struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
-}
-
 func NewAuthHandler(authService *service.AuthService) *AuthHandler {
 	return &AuthHandler{authService: authService}
 }
@@ -32,21 +25,21 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
 		return
 	}
 
-	user, err := h.authService.Register(r.Context(), req.Email, req.Password, req.Phone)
+	result, err := h.authService.Register(r.Context(), req.Email, req.Password, req.Phone)
 	if err != nil {
 		status := http.StatusBadRequest
 		if err == model.ErrUserAlreadyExists {
 			status = http.StatusConflict
 		}
-		h.sendErrorResponse(w, err.Error(), status)
+		sendErrorResponse(w, err.Error(), status)
 		return
 	}
 
-	h.sendSuccessResponse(w, user, http.StatusCreated)
+	sendSuccessResponse(w, result, http.StatusCreated)
 }
 
 func (h *AuthHandler) VerifyPhone(w http.ResponseWriter, r *http.Request) {
@@ -54,36 +47,38 @@ func (h *AuthHandler) VerifyPhone(w http.ResponseWriter, r *http.Request) {
 
 	// 1. Парсим входящий запрос
 	var req struct {
-		Phone string `json:"phone"`
-		Code  string `json:"code"`
+		VerificationTicket string `json:"verification_ticket"`
+		Code               string `json:"code"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
 		return
 	}
 
 	// 2. Валидация входных данных
-	if req.Phone == "" || req.Code == "" {
-		h.sendErrorResponse(w, "Phone and code are required", http.StatusUnprocessableEntity)
+	if req.VerificationTicket == "" || req.Code == "" {
+		sendErrorResponse(w, "verification_ticket and code are required", http.StatusUnprocessableEntity)
 		return
 	}
 
 	// 3. Вызываем сервис верификации
-	if err := h.authService.VerifyPhone(r.Context(), req.Phone, req.Code); err != nil {
+	if err := h.authService.VerifyPhone(r.Context(), req.VerificationTicket, req.Code); err != nil {
 		switch err {
 		case model.ErrVerificationFailed:
-			h.sendErrorResponse(w, "Invalid verification code", http.StatusForbidden)
+			sendErrorResponse(w, "Invalid verification code", http.StatusForbidden)
 		case model.ErrUserNotFound:
-			h.sendErrorResponse(w, "Phone number not registered", http.StatusNotFound)
+			sendErrorResponse(w, "No account is awaiting verification of this phone number", http.StatusNotFound)
+		case model.ErrPhoneAlreadyInUse:
+			sendErrorResponse(w, err.Error(), http.StatusConflict)
 		default:
-			h.sendErrorResponse(w, "Verification failed", http.StatusInternalServerError)
+			sendErrorResponse(w, "Verification failed", http.StatusInternalServerError)
 		}
 		return
 	}
 
 	// 4. Отправляем успешный ответ
-	h.sendSuccessResponse(w, map[string]string{
+	sendSuccessResponse(w, map[string]string{
 		"status":  "success",
 		"message": "Phone number verified",
 	}, http.StatusOK)
@@ -93,93 +88,121 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email        string `json:"email"`
+		Password     string `json:"password"`
+		CaptchaToken string `json:"captcha_token"` // Нужен после ответа с кодом captcha_required
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
 		return
 	}
 
-	// Получаем реальный IP (учитываем прокси)
-	ip := r.RemoteAddr
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		ip = strings.Split(forwarded, ",")[0]
-	}
-
-	tokens, err := h.authService.Login(
+	result, err := h.authService.Login(
 		r.Context(),
 		req.Email,
 		req.Password,
+		req.CaptchaToken,
 		r.UserAgent(),
-		ip,
+		clientIP(r),
 	)
 
 	if err != nil {
+		if sendAccountStatusError(w, err) || sendLoginGuardError(w, err) {
+			return
+		}
 		status := http.StatusUnauthorized
 		switch err {
 		case model.ErrUserNotFound:
 			status = http.StatusNotFound
-		case model.ErrTooManyAttempts:
-			status = http.StatusTooManyRequests
 		case model.ErrIPBlocked:
 			status = http.StatusForbidden // 403
 		}
-		h.sendErrorResponse(w, err.Error(), status)
+		sendErrorResponse(w, err.Error(), status)
+		return
+	}
+
+	if result.Challenge != nil {
+		sendSuccessResponse(w, result.Challenge, http.StatusOK)
 		return
 	}
 
-	h.sendSuccessResponse(w, tokens, http.StatusOK)
+	sendSuccessResponse(w, result.Tokens, http.StatusOK)
 }
 
-func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+// LoginMFA завершает вход вторым фактором по mfa_token из ответа /login
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req struct {
-		RefreshToken string `json:"refresh_token"`
+		MFAToken string `json:"mfa_token"`
+		Method   string `json:"method"`
+		Code     string `json:"code"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
 		return
 	}
 
-	tokens, err := h.authService.RefreshTokens(r.Context(), req.RefreshToken)
+	if req.MFAToken == "" || req.Code == "" {
+		sendErrorResponse(w, "mfa_token and code are required", http.StatusUnprocessableEntity)
+		return
+	}
+	if req.Method == "" {
+		req.Method = model.MFAMethodTOTP
+	}
+
+	tokens, err := h.authService.CompleteMFALogin(r.Context(), req.MFAToken, req.Method, req.Code)
 	if err != nil {
-		status := http.StatusUnauthorized
-		if err == model.ErrSessionExpired {
-			status = http.StatusForbidden
+		if sendAccountStatusError(w, err) {
+			return
 		}
-		h.sendErrorResponse(w, err.Error(), status)
+		status := http.StatusInternalServerError
+		switch err {
+		case model.ErrInvalidMFACode, model.ErrMFAChallengeFailed:
+			status = http.StatusUnauthorized
+		case model.ErrTooManyMFAAttempts:
+			status = http.StatusTooManyRequests
+		}
+		sendErrorResponse(w, err.Error(), status)
 		return
 	}
 
-	h.sendSuccessResponse(w, tokens, http.StatusOK)
+	sendSuccessResponse(w, tokens, http.StatusOK)
 }
 
-func (h *AuthHandler) Verify(w http.ResponseWriter, r *http.Request) {
+// SendMFASMS отправляет (повторно) SMS-код для челленджа из ответа /login
+func (h *AuthHandler) SendMFASMS(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req struct {
-		Token string `json:"token"`
+		MFAToken string `json:"mfa_token"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
 		return
 	}
 
-	claims, err := h.authService.VerifyToken(req.Token)
-	if err != nil {
-		h.sendErrorResponse(w, "Invalid token", http.StatusUnauthorized)
+	if err := h.authService.SendMFASMS(r.Context(), req.MFAToken); err != nil {
+		status := http.StatusInternalServerError
+		switch err {
+		case model.ErrMFAChallengeFailed:
+			status = http.StatusUnauthorized
+		case model.ErrMFANotEnabled, model.ErrPhoneNotVerified:
+			status = http.StatusBadRequest
+		case model.ErrTooManyCodeRequests:
+			status = http.StatusTooManyRequests
+		}
+		sendErrorResponse(w, err.Error(), status)
 		return
 	}
 
-	h.sendSuccessResponse(w, claims, http.StatusOK)
+	sendSuccessResponse(w, nil, http.StatusOK)
 }
 
-func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req struct {
@@ -187,31 +210,42 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.authService.Logout(r.Context(), req.RefreshToken); err != nil {
-		h.sendErrorResponse(w, err.Error(), http.StatusUnauthorized)
+	tokens, err := h.authService.RefreshTokens(r.Context(), req.RefreshToken, "")
+	if err != nil {
+		if sendAccountStatusError(w, err) {
+			return
+		}
+		status := http.StatusUnauthorized
+		if err == model.ErrSessionExpired {
+			status = http.StatusForbidden
+		}
+		sendErrorResponse(w, err.Error(), status)
 		return
 	}
 
-	h.sendSuccessResponse(w, nil, http.StatusOK)
+	sendSuccessResponse(w, tokens, http.StatusOK)
 }
 
-// Вспомогательные методы для отправки ответов
-func (h *AuthHandler) sendErrorResponse(w http.ResponseWriter, errorMsg string, statusCode int) {
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(Response{
-		Success: false,
-		Error:   errorMsg,
-	})
-}
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.Logout(r.Context(), req.RefreshToken); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
 
-func (h *AuthHandler) sendSuccessResponse(w http.ResponseWriter, data interface{}, statusCode int) {
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(Response{
-		Success: true,
-		Data:    data,
-	})
+	sendSuccessResponse(w, nil, http.StatusOK)
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"encoding/json"
+	"net/http"
+)
+
+type EmailVerificationHandler struct {
+	emailVerificationService *service.EmailVerificationService
+}
+
+func NewEmailVerificationHandler(emailVerificationService *service.EmailVerificationService) *EmailVerificationHandler {
+	return &EmailVerificationHandler{emailVerificationService: emailVerificationService}
+}
+
+// Verify принимает токен из ссылки в письме (GET) или из тела запроса (POST)
+func (h *EmailVerificationHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := r.URL.Query().Get("token")
+	if r.Method == http.MethodPost {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	}
+
+	if err := h.emailVerificationService.ConfirmEmail(r.Context(), token); err != nil {
+		if err == model.ErrEmailVerificationFailed {
+			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sendErrorResponse(w, "Verification failed", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, map[string]string{
+		"status":  "success",
+		"message": "Email verified",
+	}, http.StatusOK)
+}
+
+func (h *EmailVerificationHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	if err := h.emailVerificationService.ResendVerificationEmail(r.Context(), principal.UserID); err != nil {
+		status := http.StatusInternalServerError
+		switch err {
+		case model.ErrEmailAlreadyVerified:
+			status = http.StatusConflict
+		case model.ErrTooManyCodeRequests:
+			status = http.StatusTooManyRequests
+		case model.ErrUserNotFound:
+			status = http.StatusNotFound
+		default:
+			sendErrorResponse(w, "Failed to send verification email", status)
+			return
+		}
+		sendErrorResponse(w, err.Error(), status)
+		return
+	}
+
+	sendSuccessResponse(w, nil, http.StatusAccepted)
+}
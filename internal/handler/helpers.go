@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/util"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Response struct {
+	Success bool              `json:"success"`
+	Data    interface{}       `json:"data,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Code    string            `json:"code,omitempty"`   // Машинно-читаемый код ошибки, если клиенту нужно ее различать
+	Fields  map[string]string `json:"fields,omitempty"` // Ошибки по отдельным полям запроса
+}
+
+// clientIP возвращает нормализованный IP клиента, определенный RequestContext с учетом доверенных прокси
+func clientIP(r *http.Request) string {
+	if ip := util.RequestInfoFromContext(r.Context()).IP; ip != "" {
+		return ip
+	}
+	// Вне RequestContext заголовкам прокси не доверяем
+	if ip, err := util.NormalizeIP(r.RemoteAddr); err == nil {
+		return ip
+	}
+	return r.RemoteAddr
+}
+
+// isSecureRequest сообщает, пришел ли запрос по HTTPS: напрямую или через доверенный прокси.
+// X-Forwarded-Proto от остальных клиентов не учитывается.
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || util.RequestInfoFromContext(r.Context()).Secure
+}
+
+// clientCredentials достает учетные данные клиента из Basic-авторизации или из тела формы (RFC 6749, 2.3.1)
+func clientCredentials(r *http.Request) (string, string, bool) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		// В Basic-заголовке id и secret дополнительно закодированы form-urlencoded
+		if unescaped, err := url.QueryUnescape(id); err == nil {
+			id = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(secret); err == nil {
+			secret = unescaped
+		}
+		return id, secret, true
+	}
+
+	id := r.PostForm.Get("client_id")
+	if id == "" {
+		return "", "", false
+	}
+	return id, r.PostForm.Get("client_secret"), true
+}
+
+// parsePagination читает limit и offset из query; отсутствующие значения — 0 (по умолчанию сервиса)
+func parsePagination(query url.Values) (limit, offset int, err error) {
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			return 0, 0, errors.New("limit must be a number")
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil {
+			return 0, 0, errors.New("offset must be a number")
+		}
+	}
+	return limit, offset, nil
+}
+
+// parseTimeParam читает необязательное время в формате RFC 3339
+func parseTimeParam(query url.Values, name string) (*time.Time, error) {
+	v := query.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, errors.New(name + " must be an RFC 3339 timestamp")
+	}
+	return &t, nil
+}
+
+// Вспомогательные функции для отправки ответов
+func sendErrorResponse(w http.ResponseWriter, errorMsg string, statusCode int) {
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(Response{
+		Success: false,
+		Error:   errorMsg,
+	})
+}
+
+func sendSuccessResponse(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// sendValidationError отправляет 422 с описанием ошибки по каждому полю
+func sendValidationError(w http.ResponseWriter, verr *model.ValidationError) {
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(Response{
+		Success: false,
+		Error:   verr.Error(),
+		Fields:  verr.Fields,
+	})
+}
+
+// sendAccountStatusError отвечает 403 с кодом, если вход запрещен состоянием аккаунта.
+// Возвращает false, если err не такая ошибка.
+func sendAccountStatusError(w http.ResponseWriter, err error) bool {
+	var code string
+	var statusErr *model.AccountStatusError
+	switch {
+	case errors.As(err, &statusErr):
+		code = statusErr.Code()
+	case err == model.ErrPasswordResetRequired:
+		code = "password_reset_required"
+	default:
+		return false
+	}
+
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(Response{
+		Success: false,
+		Error:   err.Error(),
+		Code:    code,
+	})
+	return true
+}
+
+// sendLoginGuardError отвечает на отказ защиты от перебора: 429 с Retry-After, пока действует
+// задержка, или 401 с кодом captcha_required/captcha_invalid. Возвращает false, если err не такая ошибка.
+func sendLoginGuardError(w http.ResponseWriter, err error) bool {
+	status := http.StatusUnauthorized
+	var code string
+	var throttled *model.LoginThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		status = http.StatusTooManyRequests
+		code = "login_throttled"
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	case err == model.ErrCaptchaRequired:
+		code = "captcha_required"
+	case err == model.ErrCaptchaInvalid:
+		code = "captcha_invalid"
+	default:
+		return false
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{
+		Success: false,
+		Error:   err.Error(),
+		Code:    code,
+	})
+	return true
+}
+
+// sendJSON отправляет данные без обертки Response (нужно для ответов по RFC)
+func sendJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
+// sendOAuthError отправляет ошибку в формате RFC 6749, 5.2
+func sendOAuthError(w http.ResponseWriter, err error) {
+	var oauthErr *model.OAuthError
+	if !errors.As(err, &oauthErr) {
+		oauthErr = model.NewOAuthError(model.OAuthServerError, "Internal server error")
+	}
+
+	status := http.StatusBadRequest
+	switch oauthErr.Code {
+	case model.OAuthInvalidClient:
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	case model.OAuthServerError:
+		status = http.StatusInternalServerError
+	}
+
+	sendJSON(w, oauthErr, status)
+}
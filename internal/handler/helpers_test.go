@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/util"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsSecureRequest(t *testing.T) {
+	resolver, err := util.NewClientIPResolver([]string{"10.0.0.0/8"}, util.HeaderXForwardedFor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		tls    bool
+		proto  string
+		want   bool
+	}{
+		{"plain http", "203.0.113.9:5000", false, "", false},
+		{"direct tls", "203.0.113.9:5000", true, "", true},
+		{"trusted proxy says https", "10.0.0.1:5000", false, "https", true},
+		{"trusted proxy says http", "10.0.0.1:5000", false, "http", false},
+		{"client claims https", "203.0.113.9:5000", false, "https", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if tt.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+
+			var got bool
+			RequestContext(resolver, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = isSecureRequest(r)
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Fatalf("isSecureRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+type LoginAlertHandler struct {
+	loginAlertService *service.LoginAlertService
+}
+
+func NewLoginAlertHandler(loginAlertService *service.LoginAlertService) *LoginAlertHandler {
+	return &LoginAlertHandler{loginAlertService: loginAlertService}
+}
+
+// notMeTemplate подтверждение перед завершением сессий. Ссылку из письма открывают и почтовые
+// сканеры, и превью в мессенджерах, поэтому GET ничего не меняет: действует только отправка формы.
+var notMeTemplate = template.Must(template.New("not-me").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Secure your account</title>
+</head>
+<body>
+  {{if .Message}}
+  <p role="status">{{.Message}}</p>
+  {{else}}
+  {{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+  {{if .Token}}
+  <h1>Wasn't you?</h1>
+  <p>This will sign out every session on your account and require a new password.
+     We will email you a link to choose it.</p>
+  <form method="post" action="/login/not-me">
+    <input type="hidden" name="token" value="{{.Token}}">
+    <button type="submit">Sign out everywhere</button>
+  </form>
+  {{end}}
+  {{end}}
+</body>
+</html>
+`))
+
+type notMePage struct {
+	Token   string
+	Error   string
+	Message string
+}
+
+const notMeDoneMessage = "All sessions have been signed out. Check your email to choose a new password."
+
+// NotMePage GET по ссылке "это был не я" из уведомления о входе: только страница подтверждения
+func (h *LoginAlertHandler) NotMePage(w http.ResponseWriter, r *http.Request) {
+	page := notMePage{Token: r.URL.Query().Get("token")}
+	status := http.StatusOK
+	if page.Token == "" {
+		page.Error = model.ErrLoginAlertInvalid.Error()
+		status = http.StatusBadRequest
+	}
+	renderNotMe(w, page, status)
+}
+
+// NotMe POST с токеном из ссылки: из формы страницы подтверждения или JSON-телом от клиента
+func (h *LoginAlertHandler) NotMe(w http.ResponseWriter, r *http.Request) {
+	fromForm := strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+
+	var token string
+	if fromForm {
+		if err := r.ParseForm(); err != nil {
+			renderNotMe(w, notMePage{Error: "Invalid request format"}, http.StatusBadRequest)
+			return
+		}
+		token = r.PostForm.Get("token")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	}
+
+	err := h.loginAlertService.ReportNotMe(r.Context(), token, r.UserAgent(), clientIP(r))
+	status := http.StatusOK
+	message := "Failed to secure the account"
+	switch {
+	case err == nil:
+	case err == model.ErrLoginAlertInvalid:
+		status, message = http.StatusUnauthorized, err.Error()
+	default:
+		status = http.StatusInternalServerError
+	}
+
+	if fromForm {
+		if err != nil {
+			renderNotMe(w, notMePage{Error: message}, status)
+			return
+		}
+		renderNotMe(w, notMePage{Message: notMeDoneMessage}, status)
+		return
+	}
+
+	if err != nil {
+		sendErrorResponse(w, message, status)
+		return
+	}
+	sendSuccessResponse(w, map[string]string{"message": notMeDoneMessage}, status)
+}
+
+func renderNotMe(w http.ResponseWriter, page notMePage, statusCode int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	// Токен в адресе страницы не должен уходить сторонним сайтам в Referer
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(statusCode)
+	notMeTemplate.Execute(w, page)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotMePageDoesNotRevoke(t *testing.T) {
+	// Без сервиса: любой вызов ReportNotMe из GET закончился бы паникой
+	h := NewLoginAlertHandler(nil)
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantForm   bool
+	}{
+		{"with token", "/login/not-me?token=abc%22def", http.StatusOK, true},
+		{"without token", "/login/not-me", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.NotMePage(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			body := rec.Body.String()
+			hasForm := strings.Contains(body, `<form method="post" action="/login/not-me">`)
+			if hasForm != tt.wantForm {
+				t.Fatalf("form present = %v, want %v:\n%s", hasForm, tt.wantForm, body)
+			}
+			if tt.wantForm && !strings.Contains(body, `value="abc&#34;def"`) {
+				t.Fatalf("token must be escaped into the form:\n%s", body)
+			}
+			if rec.Header().Get("Referrer-Policy") != "no-referrer" {
+				t.Fatal("Referrer-Policy must be no-referrer")
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"authorization_authentication/internal/util"
+	"encoding/json"
+	"net/http"
+)
+
+// magicLinkCookie связывает ссылку из письма с браузером, который ее запросил
+const (
+	magicLinkCookie     = "magic_link_nonce"
+	magicLinkCookiePath = "/login/magic-link"
+)
+
+type MagicLinkHandler struct {
+	magicLinkService *service.MagicLinkService
+}
+
+func NewMagicLinkHandler(magicLinkService *service.MagicLinkService) *MagicLinkHandler {
+	return &MagicLinkHandler{magicLinkService: magicLinkService}
+}
+
+func (h *MagicLinkHandler) Request(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Email string `json:"email"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if req.Email == "" {
+		sendErrorResponse(w, "email is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	nonce, err := h.magicLinkService.RequestMagicLink(r.Context(), req.Email, clientIP(r))
+	if err != nil {
+		if err == model.ErrTooManyCodeRequests {
+			sendErrorResponse(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if err == util.ErrInvalidEmail {
+			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sendErrorResponse(w, "Failed to send login link", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkCookie,
+		Value:    nonce,
+		Path:     magicLinkCookiePath,
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Ответ одинаковый для существующих и несуществующих адресов
+	sendSuccessResponse(w, map[string]string{"message": "If the account exists, a login link has been sent"}, http.StatusAccepted)
+}
+
+// Consume принимает токен из ссылки (GET) или из тела запроса (POST)
+func (h *MagicLinkHandler) Consume(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := r.URL.Query().Get("token")
+	if r.Method == http.MethodPost {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+			return
+		}
+		token = req.Token
+	}
+
+	var nonce string
+	if cookie, err := r.Cookie(magicLinkCookie); err == nil {
+		nonce = cookie.Value
+	}
+
+	result, err := h.magicLinkService.ConsumeMagicLink(r.Context(), token, nonce, r.UserAgent(), clientIP(r))
+	if err != nil {
+		if sendAccountStatusError(w, err) {
+			return
+		}
+		if err == model.ErrMagicLinkInvalid {
+			sendErrorResponse(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		sendErrorResponse(w, "Failed to log in", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     magicLinkCookie,
+		Path:     magicLinkCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if result.Challenge != nil {
+		sendSuccessResponse(w, result.Challenge, http.StatusOK)
+		return
+	}
+
+	sendSuccessResponse(w, result.Tokens, http.StatusOK)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"encoding/json"
+	"net/http"
+)
+
+type MFAHandler struct {
+	mfaService *service.MFAService
+}
+
+func NewMFAHandler(mfaService *service.MFAService) *MFAHandler {
+	return &MFAHandler{mfaService: mfaService}
+}
+
+// mfaCodeRequest тело запросов, подтверждаемых кодом второго фактора.
+// Пароль нужен для отключения фактора и новых кодов восстановления.
+type mfaCodeRequest struct {
+	Method   string `json:"method"`
+	Code     string `json:"code"`
+	Password string `json:"password"`
+}
+
+func (req mfaCodeRequest) stepUp() model.StepUp {
+	return model.StepUp{Password: req.Password, MFAMethod: req.Method, MFACode: req.Code}
+}
+
+func (h *MFAHandler) Status(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	status, err := h.mfaService.Status(r.Context(), principal.UserID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to load two-factor status", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, status, http.StatusOK)
+}
+
+// EnrollTOTP возвращает секрет и otpauth:// URI для приложения-аутентификатора
+func (h *MFAHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	enrollment, err := h.mfaService.EnrollTOTP(r.Context(), principal.UserID)
+	if err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, enrollment, http.StatusOK)
+}
+
+// ConfirmTOTP включает TOTP и единственный раз показывает коды восстановления
+func (h *MFAHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmTOTP(r.Context(), principal.UserID, req.Code)
+	if err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, map[string][]string{"recovery_codes": codes}, http.StatusOK)
+}
+
+func (h *MFAHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if req.Method == "" {
+		req.Method = model.MFAMethodTOTP
+	}
+
+	if err := h.mfaService.DisableTOTP(r.Context(), principal.UserID, req.stepUp()); err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, nil, http.StatusOK)
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(r.Context(), principal.UserID, req.stepUp())
+	if err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, map[string][]string{"recovery_codes": codes}, http.StatusOK)
+}
+
+// EnableSMS включает SMS-код вторым фактором на подтвержденный телефон
+func (h *MFAHandler) EnableSMS(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	if err := h.mfaService.EnableSMS(r.Context(), principal.UserID); err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, nil, http.StatusOK)
+}
+
+// SendSMSCode отправляет код, которым потом подтверждается отключение SMS-фактора
+func (h *MFAHandler) SendSMSCode(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	if err := h.mfaService.SendSMSCode(r.Context(), principal.UserID); err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, nil, http.StatusOK)
+}
+
+func (h *MFAHandler) DisableSMS(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	var req mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if req.Method == "" {
+		req.Method = model.MFAMethodSMS
+	}
+
+	if err := h.mfaService.DisableSMS(r.Context(), principal.UserID, req.stepUp()); err != nil {
+		h.sendMFAError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, nil, http.StatusOK)
+}
+
+func (h *MFAHandler) sendMFAError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch err {
+	case model.ErrInvalidMFACode, model.ErrInvalidCredentials, model.ErrStepUpRequired:
+		status = http.StatusUnauthorized
+	case model.ErrTooManyAttempts:
+		status = http.StatusTooManyRequests
+	case model.ErrMFAAlreadyEnabled:
+		status = http.StatusConflict
+	case model.ErrMFANotEnrolled, model.ErrMFANotEnabled, model.ErrPhoneNotVerified:
+		status = http.StatusBadRequest
+	case model.ErrMFANotConfigured:
+		status = http.StatusServiceUnavailable
+	case model.ErrUserNotFound:
+		status = http.StatusNotFound
+	default:
+		sendErrorResponse(w, "Two-factor operation failed", status)
+		return
+	}
+	sendErrorResponse(w, err.Error(), status)
+}
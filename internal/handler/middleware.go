@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"authorization_authentication/internal/util"
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Principal тот, от чьего имени выполняется запрос
+type Principal struct {
+	UserID   string   // Пусто у машинных клиентов (client_credentials)
+	ClientID string   // OAuth-клиент, которому выдан токен
+	Scopes   []string // У API-ключа пусто — полный доступ, как у токена из /login
+	APIKeyID string   // Заполнен, если запрос подписан API-ключом
+}
+
+// IsAPIKey сообщает, что запрос аутентифицирован персональным API-ключом
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != ""
+}
+
+// IsScoped сообщает, что доступ ограничен списком scope. Токен OAuth-клиента ограничен всегда,
+// даже с пустым scope: он выдан стороннему приложению, а не самому пользователю.
+func (p *Principal) IsScoped() bool {
+	return len(p.Scopes) > 0 || p.ClientID != ""
+}
+
+// HasScope сообщает, разрешен ли scope
+func (p *Principal) HasScope(scope string) bool {
+	return !p.IsScoped() || (scope != "" && slices.Contains(p.Scopes, scope))
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext возвращает аутентифицированного пользователя, положенный Authenticator
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// Authenticator принимает "Authorization: Bearer <jwt>" и "Authorization: ApiKey <key>"
+type Authenticator struct {
+	jwtService    *service.JWTService
+	apiKeyService *service.APIKeyService
+	authService   *service.AuthService
+}
+
+func NewAuthenticator(jwtService *service.JWTService, apiKeyService *service.APIKeyService, authService *service.AuthService) *Authenticator {
+	return &Authenticator{
+		jwtService:    jwtService,
+		apiKeyService: apiKeyService,
+		authService:   authService,
+	}
+}
+
+// Authenticate пропускает дальше только запросы пользователей с действующими учетными данными.
+// Учетные данные, ограниченные scope, сюда не проходят — для них маршрут оборачивается в RequireScope.
+func (a *Authenticator) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return a.RequireScope("", next)
+}
+
+// RequireScope как Authenticate, но пропускает и учетные данные, ограниченные scope, если среди них есть scope
+func (a *Authenticator) RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		principal, err := a.authenticate(r)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if !errors.Is(err, model.ErrInvalidToken) && !errors.Is(err, model.ErrInvalidAPIKey) {
+				status = http.StatusInternalServerError
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="auth", ApiKey realm="auth"`)
+			sendErrorResponse(w, "Authentication required", status)
+			return
+		}
+
+		if principal.UserID == "" {
+			sendErrorResponse(w, "User credentials required", http.StatusForbidden)
+			return
+		}
+
+		if !principal.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="auth", error="insufficient_scope"`)
+			sendErrorResponse(w, "Insufficient scope for this operation", http.StatusForbidden)
+			return
+		}
+
+		// Токен мог быть выдан до того, как аккаунт отключили или удалили
+		if err := a.authService.CheckUserStatus(r.Context(), principal.UserID); err != nil {
+			if sendAccountStatusError(w, err) {
+				return
+			}
+			if errors.Is(err, model.ErrUserNotFound) {
+				sendErrorResponse(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+			sendErrorResponse(w, "Failed to check account status", http.StatusInternalServerError)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	}
+}
+
+// AuthenticateInteractive как Authenticate, но не принимает API-ключи и токены OAuth-клиентов:
+// для управления учетными данными нужен токен, полученный входом в аккаунт
+func (a *Authenticator) AuthenticateInteractive(next http.HandlerFunc) http.HandlerFunc {
+	return a.Authenticate(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := PrincipalFromContext(r.Context())
+		if principal.IsAPIKey() {
+			sendErrorResponse(w, "API keys cannot be used for this operation", http.StatusForbidden)
+			return
+		}
+		// Сторонний клиент с ограниченным scope иначе мог бы, например, добавить свой passkey
+		// и получить вход в аккаунт в обход второго фактора
+		if principal.ClientID != "" {
+			sendErrorResponse(w, "OAuth client tokens cannot be used for this operation", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (a *Authenticator) authenticate(r *http.Request) (*Principal, error) {
+	scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	credentials = strings.TrimSpace(credentials)
+	if credentials == "" {
+		return nil, model.ErrInvalidToken
+	}
+
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		return a.authenticateBearer(credentials)
+	case "apikey":
+		ip, err := util.NormalizeIP(clientIP(r))
+		if err != nil {
+			ip = ""
+		}
+
+		key, err := a.apiKeyService.Authenticate(r.Context(), credentials, ip)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Principal{
+			UserID:   key.UserID,
+			Scopes:   key.Scopes,
+			APIKeyID: key.ID,
+		}, nil
+	default:
+		return nil, model.ErrInvalidToken
+	}
+}
+
+func (a *Authenticator) authenticateBearer(token string) (*Principal, error) {
+	claims, err := a.jwtService.ValidateToken(token)
+	if err != nil {
+		return nil, model.ErrInvalidToken
+	}
+
+	sub, _ := (*claims)["sub"].(string)
+	if sub == "" {
+		return nil, model.ErrInvalidToken
+	}
+
+	principal := &Principal{UserID: sub}
+	principal.ClientID, _ = (*claims)["client_id"].(string)
+	if scope, ok := (*claims)["scope"].(string); ok {
+		principal.Scopes = model.ParseScope(scope)
+	}
+
+	// В токенах client_credentials sub совпадает с client_id — это не пользователь
+	if principal.ClientID != "" && principal.ClientID == sub {
+		principal.UserID = ""
+	}
+
+	return principal, nil
+}
+
+// requestIDPattern ограничивает X-Request-ID от клиента, чтобы он безопасно попадал в журнал
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestContext присваивает запросу идентификатор (или принимает X-Request-ID от клиента)
+// и кладет его вместе с IP и User-Agent в контекст для обработчиков и журнала аудита.
+// IP определяется один раз здесь, с учетом доверенных прокси.
+func RequestContext(clientIPs *util.ClientIPResolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		ctx := util.WithRequestInfo(r.Context(), util.RequestInfo{
+			ID:        requestID,
+			IP:        clientIPs.Resolve(r),
+			UserAgent: r.UserAgent(),
+			Secure:    r.TLS != nil || (clientIPs.FromTrustedProxy(r) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// DenyListed отклоняет запросы из сетей списка запрещенных до любой обработки
+func DenyListed(reputation *service.IPReputationService, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reputation.IsDenied(clientIP(r)) {
+			w.Header().Set("Content-Type", "application/json")
+			sendErrorResponse(w, model.ErrIPDenied.Error(), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
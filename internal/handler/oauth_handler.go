@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type OAuthHandler struct {
+	oauthService *service.OAuthService
+}
+
+func NewOAuthHandler(oauthService *service.OAuthService) *OAuthHandler {
+	return &OAuthHandler{oauthService: oauthService}
+}
+
+// authorizePage данные страницы входа и согласия
+type authorizePage struct {
+	RequestID  string
+	ClientName string
+	Scopes     []string
+	Email      string
+	Captcha    bool   // Показать поле CAPTCHA после captcha_required
+	MFAToken   string // Заполнен на шаге второго фактора
+	MFAMethods []string
+	Error      string
+}
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Sign in</title>
+</head>
+<body>
+  {{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+  {{if .RequestID}}
+  <h1>{{.ClientName}} wants to access your account</h1>
+  {{if .Scopes}}
+  <p>Requested permissions:</p>
+  <ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>
+  {{end}}
+  <form method="post" action="/authorize">
+    <input type="hidden" name="request_id" value="{{.RequestID}}">
+    {{if .MFAToken}}
+    <input type="hidden" name="mfa_token" value="{{.MFAToken}}">
+    {{range .MFAMethods}}<input type="hidden" name="methods" value="{{.}}">{{end}}
+    <p><label>Verification method
+      <select name="method">{{range .MFAMethods}}<option value="{{.}}">{{.}}</option>{{end}}</select>
+    </label></p>
+    <p><label>Code <input type="text" name="code" autocomplete="one-time-code" required autofocus></label></p>
+    {{range .MFAMethods}}{{if eq . "sms"}}<button type="submit" name="action" value="send_sms" formnovalidate>Send SMS code</button>{{end}}{{end}}
+    {{else}}
+    <p><label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required autofocus></label></p>
+    <p><label>Password <input type="password" name="password" autocomplete="current-password" required></label></p>
+    {{if .Captcha}}<p><label>Verification <input type="text" name="captcha_token" required></label></p>{{end}}
+    {{end}}
+    <button type="submit" name="action" value="allow">Allow</button>
+    <button type="submit" name="action" value="deny" formnovalidate>Deny</button>
+  </form>
+  {{end}}
+</body>
+</html>
+`))
+
+// Authorize показывает страницу входа (GET) и обрабатывает ее отправку (POST)
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.showAuthorize(w, r)
+	case http.MethodPost:
+		h.submitAuthorize(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		h.renderAuthorize(w, authorizePage{Error: "Method not allowed"}, http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *OAuthHandler) showAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, client, err := h.oauthService.ValidateAuthorizationRequest(r.Context(), r.URL.Query())
+	if err != nil {
+		var oauthErr *model.OAuthError
+		if req != nil && errors.As(err, &oauthErr) {
+			// redirect_uri уже проверен — ошибку получает клиент
+			http.Redirect(w, r, service.AuthorizationErrorRedirect(req, oauthErr), http.StatusFound)
+			return
+		}
+		h.renderAuthorize(w, authorizePage{Error: authorizeErrorMessage(err)}, http.StatusBadRequest)
+		return
+	}
+
+	requestID, err := h.oauthService.SaveAuthorizationRequest(r.Context(), req)
+	if err != nil {
+		h.renderAuthorize(w, authorizePage{Error: "Internal server error"}, http.StatusInternalServerError)
+		return
+	}
+
+	h.renderAuthorize(w, authorizePage{
+		RequestID:  requestID,
+		ClientName: client.Name,
+		Scopes:     model.ParseScope(req.Scope),
+	}, http.StatusOK)
+}
+
+func (h *OAuthHandler) submitAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.renderAuthorize(w, authorizePage{Error: "Invalid request format"}, http.StatusBadRequest)
+		return
+	}
+
+	requestID := r.PostForm.Get("request_id")
+
+	if r.PostForm.Get("action") == "deny" {
+		redirectURL, err := h.oauthService.Deny(r.Context(), requestID)
+		if err != nil {
+			h.renderAuthorize(w, authorizePage{Error: authorizeErrorMessage(err)}, http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, redirectURL, http.StatusFound)
+		return
+	}
+
+	if mfaToken := r.PostForm.Get("mfa_token"); mfaToken != "" && r.PostForm.Get("action") == "send_sms" {
+		page := authorizePage{MFAToken: mfaToken, MFAMethods: r.PostForm["methods"]}
+		err := h.oauthService.SendMFASMS(r.Context(), mfaToken)
+		if errors.Is(err, model.ErrMFAChallengeFailed) {
+			page = authorizePage{}
+		}
+		h.renderAuthorizeRetry(w, r, requestID, page, err)
+		return
+	}
+
+	if mfaToken := r.PostForm.Get("mfa_token"); mfaToken != "" {
+		method := r.PostForm.Get("method")
+		redirectURL, err := h.oauthService.ApproveMFA(r.Context(), requestID, mfaToken, method, r.PostForm.Get("code"), r.UserAgent(), clientIP(r))
+		if err != nil {
+			page := authorizePage{MFAToken: mfaToken, MFAMethods: r.PostForm["methods"]}
+			if errors.Is(err, model.ErrTooManyMFAAttempts) || errors.Is(err, model.ErrMFAChallengeFailed) {
+				// Челлендж сгорел — начинаем вход заново
+				page = authorizePage{}
+			}
+			h.renderAuthorizeRetry(w, r, requestID, page, err)
+			return
+		}
+		http.Redirect(w, r, redirectURL, http.StatusFound)
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	redirectURL, challenge, err := h.oauthService.Approve(r.Context(), requestID, email, r.PostForm.Get("password"), r.PostForm.Get("captcha_token"), r.UserAgent(), clientIP(r))
+	if err != nil {
+		// Показываем форму повторно, сохранив введенный email
+		captcha := errors.Is(err, model.ErrCaptchaRequired) || errors.Is(err, model.ErrCaptchaInvalid)
+		h.renderAuthorizeRetry(w, r, requestID, authorizePage{Email: email, Captcha: captcha}, err)
+		return
+	}
+
+	if challenge != nil {
+		h.renderAuthorizeRetry(w, r, requestID, authorizePage{
+			MFAToken:   challenge.MFAToken,
+			MFAMethods: challenge.Methods,
+		}, nil)
+		return
+	}
+
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// renderAuthorizeRetry повторно показывает форму того же запроса авторизации
+func (h *OAuthHandler) renderAuthorizeRetry(w http.ResponseWriter, r *http.Request, requestID string, page authorizePage, cause error) {
+	req, client, err := h.oauthService.GetAuthorizationRequest(r.Context(), requestID)
+	if err != nil {
+		h.renderAuthorize(w, authorizePage{Error: authorizeErrorMessage(err)}, http.StatusBadRequest)
+		return
+	}
+
+	page.RequestID = requestID
+	page.ClientName = client.Name
+	page.Scopes = model.ParseScope(req.Scope)
+
+	status := http.StatusOK
+	if cause != nil {
+		page.Error = authorizeErrorMessage(cause)
+		status = http.StatusUnauthorized
+	}
+
+	h.renderAuthorize(w, page, status)
+}
+
+func (h *OAuthHandler) renderAuthorize(w http.ResponseWriter, page authorizePage, statusCode int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(statusCode)
+	authorizeTemplate.Execute(w, page)
+}
+
+// authorizeErrorMessage переводит ошибку в текст для страницы входа
+func authorizeErrorMessage(err error) string {
+	var oauthErr *model.OAuthError
+	switch {
+	case errors.As(err, &oauthErr):
+		return oauthErr.Description
+	case errors.Is(err, model.ErrUserNotFound), errors.Is(err, model.ErrInvalidCredentials):
+		// Не раскрываем, существует ли аккаунт
+		return "Invalid email or password"
+	case errors.Is(err, model.ErrTooManyAttempts), errors.Is(err, model.ErrIPBlocked),
+		errors.Is(err, model.ErrInvalidMFACode), errors.Is(err, model.ErrTooManyMFAAttempts),
+		errors.Is(err, model.ErrMFAChallengeFailed), errors.Is(err, model.ErrTooManyCodeRequests),
+		errors.Is(err, model.ErrCaptchaRequired), errors.Is(err, model.ErrCaptchaInvalid):
+		return err.Error()
+	default:
+		return "Internal server error"
+	}
+}
+
+// Token реализует эндпоинт выдачи токенов (RFC 6749, 3.2)
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		sendJSON(w, model.NewOAuthError(model.OAuthInvalidRequest, "POST method required"), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		sendOAuthError(w, model.NewOAuthError(model.OAuthInvalidRequest, "Invalid request format"))
+		return
+	}
+
+	clientID, clientSecret, ok := clientCredentials(r)
+	if !ok {
+		sendOAuthError(w, model.NewOAuthError(model.OAuthInvalidClient, "Client authentication failed"))
+		return
+	}
+
+	client, err := h.oauthService.AuthenticateClient(r.Context(), clientID, clientSecret)
+	if err != nil {
+		sendOAuthError(w, err)
+		return
+	}
+
+	tokens, err := h.oauthService.Token(r.Context(), client, r.PostForm, r.UserAgent(), clientIP(r))
+	if err != nil {
+		sendOAuthError(w, err)
+		return
+	}
+
+	sendJSON(w, tokens, http.StatusOK)
+}
+
+// Introspect реализует интроспекцию токенов (RFC 7662) для ресурс-серверов
+func (h *OAuthHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		sendJSON(w, model.NewOAuthError(model.OAuthInvalidRequest, "POST method required"), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		sendOAuthError(w, model.NewOAuthError(model.OAuthInvalidRequest, "Invalid request format"))
+		return
+	}
+
+	clientID, clientSecret, ok := clientCredentials(r)
+	if !ok {
+		sendOAuthError(w, model.NewOAuthError(model.OAuthInvalidClient, "Client authentication failed"))
+		return
+	}
+
+	client, err := h.oauthService.AuthenticateClient(r.Context(), clientID, clientSecret)
+	if err != nil {
+		sendOAuthError(w, err)
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		sendOAuthError(w, model.NewOAuthError(model.OAuthInvalidRequest, "Token is required"))
+		return
+	}
+
+	result, err := h.oauthService.Introspect(r.Context(), client, token, r.PostForm.Get("token_type_hint"))
+	if err != nil {
+		sendOAuthError(w, err)
+		return
+	}
+
+	sendJSON(w, result, http.StatusOK)
+}
+
+// RotateSecret выпускает новый секрет для клиента, аутентифицированного текущим секретом.
+// Прежний секрет продолжает работать в течение overlap (в секундах, по умолчанию сутки).
+func (h *OAuthHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		sendJSON(w, model.NewOAuthError(model.OAuthInvalidRequest, "POST method required"), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		sendOAuthError(w, model.NewOAuthError(model.OAuthInvalidRequest, "Invalid request format"))
+		return
+	}
+
+	clientID, clientSecret, ok := clientCredentials(r)
+	if !ok {
+		sendOAuthError(w, model.NewOAuthError(model.OAuthInvalidClient, "Client authentication failed"))
+		return
+	}
+
+	client, err := h.oauthService.AuthenticateClient(r.Context(), clientID, clientSecret)
+	if err != nil {
+		sendOAuthError(w, err)
+		return
+	}
+
+	overlap := service.DefaultSecretRotationOverlap
+	if raw := r.PostForm.Get("overlap"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil {
+			sendOAuthError(w, model.NewOAuthError(model.OAuthInvalidRequest, "overlap must be a number of seconds"))
+			return
+		}
+		overlap = time.Duration(seconds) * time.Second
+	}
+
+	secret, previousExpiresAt, err := h.oauthService.RotateClientSecret(r.Context(), client, overlap)
+	if err != nil {
+		sendOAuthError(w, err)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"client_id":                  client.ID,
+		"client_secret":              secret,
+		"previous_secret_expires_at": previousExpiresAt.Unix(),
+	}, http.StatusOK)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"encoding/json"
+	"net/http"
+)
+
+type PasswordHandler struct {
+	passwordService *service.PasswordService
+}
+
+func NewPasswordHandler(passwordService *service.PasswordService) *PasswordHandler {
+	return &PasswordHandler{passwordService: passwordService}
+}
+
+// Reset устанавливает новый пароль по токену из письма
+func (h *PasswordHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		req.Token = r.URL.Query().Get("token")
+	}
+
+	if req.NewPassword == "" {
+		sendErrorResponse(w, "new_password is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := h.passwordService.ResetPassword(r.Context(), req.Token, req.NewPassword, r.UserAgent(), clientIP(r)); err != nil {
+		if err == model.ErrPasswordResetInvalid {
+			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sendErrorResponse(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, map[string]string{
+		"status":  "success",
+		"message": "Password updated",
+	}, http.StatusOK)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type PrivacyHandler struct {
+	privacyService *service.PrivacyService
+}
+
+func NewPrivacyHandler(privacyService *service.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{privacyService: privacyService}
+}
+
+// DeleteAccount удаляет аккаунт текущего пользователя (с возможностью восстановления)
+func (h *PrivacyHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	var req struct {
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	if req.Password == "" {
+		sendErrorResponse(w, "password is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	purgeAt, err := h.privacyService.DeleteAccount(r.Context(), principal.UserID, req.Password, r.UserAgent(), clientIP(r))
+	if err != nil {
+		switch err {
+		case model.ErrInvalidCredentials:
+			sendErrorResponse(w, err.Error(), http.StatusUnauthorized)
+		case model.ErrUserNotFound:
+			sendErrorResponse(w, err.Error(), http.StatusNotFound)
+		default:
+			sendErrorResponse(w, "Failed to delete account", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	sendSuccessResponse(w, map[string]string{
+		"status":   "scheduled",
+		"purge_at": purgeAt.Format(time.RFC3339),
+	}, http.StatusAccepted)
+}
+
+// RestoreAccount отменяет удаление аккаунта до истечения периода ожидания
+func (h *PrivacyHandler) RestoreAccount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Email        string `json:"email"`
+		Password     string `json:"password"`
+		CaptchaToken string `json:"captcha_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.privacyService.RestoreAccount(r.Context(), req.Email, req.Password, req.CaptchaToken, r.UserAgent(), clientIP(r)); err != nil {
+		if sendLoginGuardError(w, err) {
+			return
+		}
+		status := http.StatusUnauthorized
+		switch err {
+		case model.ErrAccountNotDeleted:
+			status = http.StatusConflict
+		case model.ErrIPBlocked:
+			status = http.StatusForbidden
+		}
+		sendErrorResponse(w, err.Error(), status)
+		return
+	}
+
+	sendSuccessResponse(w, map[string]string{"status": "restored"}, http.StatusOK)
+}
+
+// Export отдает все данные пользователя файлом JSON
+func (h *PrivacyHandler) Export(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	export, err := h.privacyService.ExportAccount(r.Context(), principal.UserID, r.UserAgent(), clientIP(r))
+	if err != nil {
+		if err == model.ErrUserNotFound {
+			sendErrorResponse(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		sendErrorResponse(w, "Failed to export account data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="account-export.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	sendJSON(w, export, http.StatusOK)
+}
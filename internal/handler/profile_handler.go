@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type ProfileHandler struct {
+	profileService *service.ProfileService
+}
+
+func NewProfileHandler(profileService *service.ProfileService) *ProfileHandler {
+	return &ProfileHandler{profileService: profileService}
+}
+
+// Get возвращает текущего пользователя. ETag из ответа передается в If-Match при PATCH /me.
+func (h *ProfileHandler) Get(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	user, err := h.profileService.GetProfile(r.Context(), principal.UserID)
+	if err != nil {
+		h.sendProfileError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", user.ETag())
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if r.Header.Get("If-None-Match") == user.ETag() {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	sendSuccessResponse(w, user, http.StatusOK)
+}
+
+func (h *ProfileHandler) Update(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		sendErrorResponse(w, "If-Match header with the profile ETag is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	var req model.ProfileUpdate
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.profileService.UpdateProfile(r.Context(), principal.UserID, ifMatch, &req, r.UserAgent(), clientIP(r))
+	if err != nil {
+		h.sendProfileError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", user.ETag())
+	sendSuccessResponse(w, user, http.StatusOK)
+}
+
+func (h *ProfileHandler) sendProfileError(w http.ResponseWriter, err error) {
+	var verr *model.ValidationError
+	if errors.As(err, &verr) {
+		sendValidationError(w, verr)
+		return
+	}
+
+	switch err {
+	case model.ErrProfileModified:
+		sendErrorResponse(w, err.Error(), http.StatusPreconditionFailed)
+	case model.ErrUserNotFound:
+		sendErrorResponse(w, err.Error(), http.StatusNotFound)
+	default:
+		sendErrorResponse(w, "Failed to load profile", http.StatusInternalServerError)
+	}
+}
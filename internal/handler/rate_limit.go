@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/ratelimit"
+	"authorization_authentication/internal/service"
+	"authorization_authentication/pkg/logger"
+	"net/http"
+)
+
+// RateLimiter ограничивает частоту запросов к маршрутам по их политикам и сообщает
+// клиенту остаток квоты заголовками RateLimit-*. Разрешенные сети не ограничиваются.
+type RateLimiter struct {
+	limiter    ratelimit.Limiter
+	reputation *service.IPReputationService
+}
+
+func NewRateLimiter(limiter ratelimit.Limiter, reputation *service.IPReputationService) *RateLimiter {
+	return &RateLimiter{limiter: limiter, reputation: reputation}
+}
+
+// ByIP считает запросы по сети клиента, чтобы ротация адресов внутри нее не обходила лимит
+func (rl *RateLimiter) ByIP(policy ratelimit.Policy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rl.reputation.IsAllowed(clientIP(r)) {
+			next(w, r)
+			return
+		}
+		rl.limit(w, r, policy, "ip:"+rl.reputation.Network(clientIP(r)), next)
+	}
+}
+
+// ByUser считает запросы по пользователю; ставится внутри Authenticate
+func (rl *RateLimiter) ByUser(policy ratelimit.Policy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
+		if !ok || principal.UserID == "" {
+			rl.ByIP(policy, next)(w, r)
+			return
+		}
+		rl.limit(w, r, policy, "user:"+principal.UserID, next)
+	}
+}
+
+func (rl *RateLimiter) limit(w http.ResponseWriter, r *http.Request, policy ratelimit.Policy, key string, next http.HandlerFunc) {
+	result, err := rl.limiter.Allow(r.Context(), policy, key)
+	if err != nil {
+		logger.Log.Warn("Rate limiter unavailable for ", policy.Name, ": ", err)
+		if policy.FailClosed {
+			// Без счетчиков перебор паролей ничем не ограничен
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			sendErrorResponse(w, "Service temporarily unavailable, please try again later", http.StatusServiceUnavailable)
+			return
+		}
+		next(w, r)
+		return
+	}
+
+	ratelimit.WriteHeaders(w.Header(), policy, result)
+	if !result.Allowed {
+		w.Header().Set("Content-Type", "application/json")
+		sendErrorResponse(w, "Too many requests, please try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	next(w, r)
+}
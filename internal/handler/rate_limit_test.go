@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/ratelimit"
+	"authorization_authentication/internal/service"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// unavailableLimiter хранилище счетчиков, которое не отвечает
+type unavailableLimiter struct{ ratelimit.Limiter }
+
+func (unavailableLimiter) Allow(context.Context, ratelimit.Policy, string) (*ratelimit.Result, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestRateLimiterStorageUnavailable(t *testing.T) {
+	reputation, err := service.NewIPReputationService(ratelimit.NewMemoryLimiter(nil), 32, 64, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl := NewRateLimiter(unavailableLimiter{}, reputation)
+
+	tests := []struct {
+		name       string
+		failClosed bool
+		wantStatus int
+	}{
+		{"fail open", false, http.StatusOK},
+		{"fail closed", true, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := ratelimit.Policy{Name: "login", Algorithm: ratelimit.SlidingWindow, Limit: 1, Window: time.Minute, FailClosed: tt.failClosed}
+			called := false
+			handler := rl.ByIP(policy, func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called == tt.failClosed {
+				t.Fatalf("handler called = %v with FailClosed = %v", called, tt.failClosed)
+			}
+			if tt.failClosed && rec.Header().Get("Retry-After") == "" {
+				t.Fatal("Retry-After must be set when failing closed")
+			}
+		})
+	}
+}
+
+func TestRateLimiterRejectsOverLimit(t *testing.T) {
+	limiter := ratelimit.NewMemoryLimiter(nil)
+	reputation, err := service.NewIPReputationService(limiter, 32, 64, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := ratelimit.Policy{Name: "login", Algorithm: ratelimit.SlidingWindow, Limit: 2, Window: time.Minute}
+	handler := NewRateLimiter(limiter, reputation).ByIP(policy, func(w http.ResponseWriter, r *http.Request) {})
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "198.51.100.7:5555"
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if rec.Code != want {
+			t.Fatalf("request %d: status = %d, want %d", i, rec.Code, want)
+		}
+		if rec.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("request %d: RateLimit-Limit = %q", i, rec.Header().Get("RateLimit-Limit"))
+		}
+		if want == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "60" {
+			t.Fatalf("Retry-After = %q, want 60", rec.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"net/http"
+)
+
+type SecurityActivityHandler struct {
+	auditService *service.AuditService
+}
+
+func NewSecurityActivityHandler(auditService *service.AuditService) *SecurityActivityHandler {
+	return &SecurityActivityHandler{auditService: auditService}
+}
+
+// adminViewEvents просмотры аккаунта администраторами не показываются пользователю
+var adminViewEvents = []string{
+	model.AuditAdminUsersSearched,
+	model.AuditAdminUserViewed,
+	model.AuditAdminSessionsViewed,
+	model.AuditAdminAuditViewed,
+}
+
+// List GET /me/security-activity?limit=&offset= — входы, выходы, подтверждения и изменения аккаунта
+func (h *SecurityActivityHandler) List(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	limit, offset, err := parsePagination(r.URL.Query())
+	if err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.auditService.Query(r.Context(), model.AuditQuery{
+		UserID:       principal.UserID,
+		ExcludeTypes: adminViewEvents,
+		Limit:        limit,
+		Offset:       offset,
+	})
+	if err != nil {
+		sendErrorResponse(w, "Failed to load security activity", http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, page, http.StatusOK)
+}
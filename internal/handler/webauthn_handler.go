@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/service"
+	"encoding/json"
+	"net/http"
+)
+
+type WebAuthnHandler struct {
+	webAuthnService *service.WebAuthnService
+}
+
+func NewWebAuthnHandler(webAuthnService *service.WebAuthnService) *WebAuthnHandler {
+	return &WebAuthnHandler{webAuthnService: webAuthnService}
+}
+
+// webAuthnFinishRequest ответ аутентификатора как его вернул браузер (PublicKeyCredential в JSON).
+// При регистрации вместе с ним пароль и, если включен, код второго фактора.
+type webAuthnFinishRequest struct {
+	CeremonyID string          `json:"ceremony_id"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential"`
+	model.StepUp
+}
+
+func (h *WebAuthnHandler) RegisterOptions(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	ceremony, err := h.webAuthnService.BeginRegistration(r.Context(), principal.UserID)
+	if err != nil {
+		h.sendWebAuthnError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, ceremony, http.StatusOK)
+}
+
+func (h *WebAuthnHandler) RegisterFinish(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	var req webAuthnFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	credential, err := h.webAuthnService.FinishRegistration(r.Context(), principal.UserID, req.CeremonyID, req.Name, req.StepUp, req.Credential)
+	if err != nil {
+		h.sendWebAuthnError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, credential, http.StatusCreated)
+}
+
+func (h *WebAuthnHandler) LoginOptions(w http.ResponseWriter, r *http.Request) {
+	ceremony, err := h.webAuthnService.BeginLogin(r.Context())
+	if err != nil {
+		h.sendWebAuthnError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, ceremony, http.StatusOK)
+}
+
+// LoginFinish вход по passkey: в ответ те же токены, что и у /login
+func (h *WebAuthnHandler) LoginFinish(w http.ResponseWriter, r *http.Request) {
+	var req webAuthnFinishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.webAuthnService.FinishLogin(r.Context(), req.CeremonyID, req.Credential, r.UserAgent(), clientIP(r))
+	if err != nil {
+		h.sendWebAuthnError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, tokens, http.StatusOK)
+}
+
+func (h *WebAuthnHandler) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	credentials, err := h.webAuthnService.ListCredentials(r.Context(), principal.UserID)
+	if err != nil {
+		sendErrorResponse(w, "Failed to list passkeys", http.StatusInternalServerError)
+		return
+	}
+	if credentials == nil {
+		credentials = []*model.WebAuthnCredential{}
+	}
+
+	sendSuccessResponse(w, credentials, http.StatusOK)
+}
+
+func (h *WebAuthnHandler) DeleteCredential(w http.ResponseWriter, r *http.Request) {
+	principal, _ := PrincipalFromContext(r.Context())
+
+	if err := h.webAuthnService.DeleteCredential(r.Context(), principal.UserID, r.PathValue("id")); err != nil {
+		h.sendWebAuthnError(w, err)
+		return
+	}
+
+	sendSuccessResponse(w, nil, http.StatusOK)
+}
+
+func (h *WebAuthnHandler) sendWebAuthnError(w http.ResponseWriter, err error) {
+	if sendAccountStatusError(w, err) {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	switch err {
+	case model.ErrWebAuthnCeremonyFailed:
+		status = http.StatusBadRequest
+	case model.ErrInvalidCredentials, model.ErrInvalidMFACode, model.ErrStepUpRequired:
+		status = http.StatusUnauthorized
+	case model.ErrTooManyAttempts:
+		status = http.StatusTooManyRequests
+	case model.ErrCredentialAlreadyExists:
+		status = http.StatusConflict
+	case model.ErrCredentialNotFound, model.ErrUserNotFound:
+		status = http.StatusNotFound
+	case model.ErrWebAuthnNotConfigured:
+		status = http.StatusServiceUnavailable
+	default:
+		sendErrorResponse(w, "Passkey operation failed", status)
+		return
+	}
+	sendErrorResponse(w, err.Error(), status)
+}
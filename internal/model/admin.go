@@ -0,0 +1,23 @@
+package model
+
+// UserSearch фильтр GET /admin/users. Email и Phone ищут по началу значения.
+type UserSearch struct {
+	Email  string
+	Phone  string
+	Limit  int
+	Offset int
+}
+
+// UserPage страница результатов поиска пользователей
+type UserPage struct {
+	Users  []*User `json:"users"`
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
+
+// AdminVerify тело POST /admin/users/{id}/verify
+type AdminVerify struct {
+	Email bool `json:"email"`
+	Phone bool `json:"phone"`
+}
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Типы событий журнала аудита
+const (
+	AuditUserRegistered           = "user_registered"
+	AuditLoginSucceeded           = "login_succeeded" // Создана сессия: пароль, второй фактор, ссылка, passkey или OAuth
+	AuditLoginFailed              = "login_failed"
+	AuditMFAChallengeIssued       = "mfa_challenge_issued"
+	AuditTokenRefreshed           = "token_refreshed"
+	AuditTokenRefreshFailed       = "token_refresh_failed"
+	AuditLogout                   = "logout"
+	AuditPhoneVerified            = "phone_verified"
+	AuditPhoneVerificationFailed  = "phone_verification_failed"
+	AuditEmailVerified            = "email_verified"
+	AuditEmailChangeRequested     = "email_change_requested"
+	AuditEmailChanged             = "email_changed"
+	AuditPhoneChangeRequested     = "phone_change_requested"
+	AuditPhoneChanged             = "phone_changed"
+	AuditProfileUpdated           = "profile_updated"
+	AuditAccountDeletionRequested = "account_deletion_requested"
+	AuditAccountRestored          = "account_restored"
+	AuditAccountPurged            = "account_purged"
+	AuditAccountExported          = "account_exported"
+	AuditPasswordReset            = "password_reset"
+	AuditNewDeviceLogin           = "new_device_login"      // Вход с незнакомой пары User-Agent/IP, пользователь уведомлен
+	AuditLoginReportedNotMe       = "login_reported_not_me" // Пользователь отверг вход по ссылке из уведомления
+
+	// Действия администраторов; ActorID — администратор, UserID — затронутый аккаунт
+	AuditAdminUsersSearched     = "admin_users_searched"
+	AuditAdminUserViewed        = "admin_user_viewed"
+	AuditAdminSessionsViewed    = "admin_sessions_viewed"
+	AuditAdminUserVerified      = "admin_user_verified"
+	AuditAdminStatusChanged     = "admin_status_changed"
+	AuditAdminPasswordResetSent = "admin_password_reset_forced"
+	AuditAdminSessionsRevoked   = "admin_sessions_revoked"
+	AuditAdminUserDeleted       = "admin_user_deleted"
+	AuditAdminAuditViewed       = "admin_audit_viewed"
+	AuditAdminIPBlocksViewed    = "admin_ip_blocks_viewed"
+	AuditAdminIPBlockCleared    = "admin_ip_block_cleared"
+)
+
+// Исход события
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+type AuditEvent struct {
+	ID        string            `json:"id" db:"id"`
+	UserID    string            `json:"user_id,omitempty" db:"user_id"`
+	ActorID   string            `json:"actor_id,omitempty" db:"actor_id"`
+	SessionID string            `json:"session_id,omitempty" db:"session_id"`
+	Type      string            `json:"event_type" db:"event_type"`
+	Outcome   string            `json:"outcome" db:"outcome"` // Пусто при записи — success
+	Reason    string            `json:"reason,omitempty" db:"reason"`
+	IP        string            `json:"ip,omitempty" db:"ip"`
+	UserAgent string            `json:"user_agent,omitempty" db:"user_agent"`
+	RequestID string            `json:"request_id,omitempty" db:"request_id"`
+	Metadata  map[string]string `json:"metadata,omitempty" db:"metadata"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+
+	// Цепочка хешей; у событий, записанных до ее появления, пусто
+	Seq      int64  `json:"seq,omitempty" db:"seq"`
+	PrevHash string `json:"prev_hash,omitempty" db:"prev_hash"`
+	Hash     string `json:"hash,omitempty" db:"hash"`
+}
+
+// AuditGenesisHash prev_hash первого события цепочки
+const AuditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// auditHashContent каноническое содержимое события для хеширования. Порядок полей фиксирован,
+// ключи metadata json.Marshal сортирует сам.
+type auditHashContent struct {
+	Seq       int64             `json:"seq"`
+	PrevHash  string            `json:"prev_hash"`
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id"`
+	ActorID   string            `json:"actor_id"`
+	SessionID string            `json:"session_id"`
+	Type      string            `json:"event_type"`
+	Outcome   string            `json:"outcome"`
+	Reason    string            `json:"reason"`
+	IP        string            `json:"ip"`
+	UserAgent string            `json:"user_agent"`
+	RequestID string            `json:"request_id"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt string            `json:"created_at"`
+}
+
+// ChainHash SHA-256 (hex) от содержимого события вместе с Seq и PrevHash.
+// CreatedAt должен быть с точностью до микросекунд — как его хранит Postgres.
+func (e *AuditEvent) ChainHash() (string, error) {
+	metadata := e.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
+	data, err := json.Marshal(auditHashContent{
+		Seq:       e.Seq,
+		PrevHash:  e.PrevHash,
+		ID:        e.ID,
+		UserID:    e.UserID,
+		ActorID:   e.ActorID,
+		SessionID: e.SessionID,
+		Type:      e.Type,
+		Outcome:   e.Outcome,
+		Reason:    e.Reason,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		RequestID: e.RequestID,
+		Metadata:  metadata,
+		CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditCheckpoint подписанная отметка: событие Seq имело хеш Hash
+type AuditCheckpoint struct {
+	Seq       int64     `json:"seq"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditChainReport результат проверки цепочки
+type AuditChainReport struct {
+	EventsChecked      int64            `json:"events_checked"`
+	LastSeq            int64            `json:"last_seq"`
+	CheckpointsChecked int              `json:"checkpoints_checked"`
+	Broken             *AuditChainBreak `json:"broken,omitempty"` // Первое найденное нарушение
+}
+
+type AuditChainBreak struct {
+	Seq     int64  `json:"seq"`
+	EventID string `json:"event_id,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// AuditQuery фильтр журнала для GET /admin/audit и GET /me/security-activity.
+// Пустые поля не ограничивают выборку.
+type AuditQuery struct {
+	UserID       string
+	ActorID      string
+	Type         string
+	Outcome      string
+	IP           string
+	From         *time.Time
+	To           *time.Time
+	ExcludeTypes []string
+	Limit        int
+	Offset       int
+}
+
+// AuditPage страница журнала, события от новых к старым
+type AuditPage struct {
+	Events []*AuditEvent `json:"events"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
@@ -1,19 +1,74 @@
 package model
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
-	ErrInvalidToken       = errors.New("invalid token")
-	ErrInvalidTokenClaims = errors.New("invalid token claims")
-	ErrUserAlreadyExists  = errors.New("user already exists")
-	ErrUserNotFound       = errors.New("user not found")
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrSessionNotFound    = errors.New("session not found")
-	ErrInvalidSession     = errors.New("invalid session")
-	ErrSessionExpired     = errors.New("session expired or revoked")
-	ErrTooManyAttempts    = errors.New("too many login attempts, please try again later")
-	ErrIPBlocked          = errors.New("your IP address has been temporarily blocked")
-	ErrPhoneRequired      = errors.New("phone number is required")
-	ErrNotVerified        = errors.New("account not verified")
-	ErrVerificationFailed = errors.New("verification failed")
+	ErrInvalidToken            = errors.New("invalid token")
+	ErrInvalidTokenClaims      = errors.New("invalid token claims")
+	ErrUserAlreadyExists       = errors.New("user already exists")
+	ErrUserNotFound            = errors.New("user not found")
+	ErrInvalidCredentials      = errors.New("invalid credentials")
+	ErrSessionNotFound         = errors.New("session not found")
+	ErrInvalidSession          = errors.New("invalid session")
+	ErrSessionExpired          = errors.New("session expired or revoked")
+	ErrTooManyAttempts         = errors.New("too many login attempts, please try again later")
+	ErrIPBlocked               = errors.New("your IP address has been temporarily blocked")
+	ErrPhoneRequired           = errors.New("phone number is required")
+	ErrNotVerified             = errors.New("account not verified")
+	ErrVerificationFailed      = errors.New("verification failed")
+	ErrClientNotFound          = errors.New("oauth client not found")
+	ErrAPIKeyNotFound          = errors.New("api key not found")
+	ErrInvalidAPIKey           = errors.New("invalid api key")
+	ErrInvalidScope            = errors.New("invalid scope")
+	ErrMFANotConfigured        = errors.New("two-factor authentication is not configured on the server")
+	ErrMFAAlreadyEnabled       = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled          = errors.New("two-factor authentication enrollment not started")
+	ErrMFANotEnabled           = errors.New("two-factor authentication is not enabled")
+	ErrInvalidMFACode          = errors.New("invalid two-factor authentication code")
+	ErrMFAChallengeFailed      = errors.New("two-factor challenge expired or invalid")
+	ErrTooManyMFAAttempts      = errors.New("too many two-factor attempts, please log in again")
+	ErrTooManyCodeRequests     = errors.New("too many code requests, please try again later")
+	ErrPhoneNotVerified        = errors.New("phone number is not verified")
+	ErrWebAuthnNotConfigured   = errors.New("passkeys are not configured on the server")
+	ErrWebAuthnCeremonyFailed  = errors.New("passkey ceremony expired or invalid")
+	ErrCredentialNotFound      = errors.New("passkey not found")
+	ErrCredentialAlreadyExists = errors.New("passkey is already registered")
+	ErrMagicLinkInvalid        = errors.New("login link expired or invalid")
+	ErrEmailVerificationFailed = errors.New("email verification link expired or invalid")
+	ErrEmailAlreadyVerified    = errors.New("email is already verified")
+	ErrNoPendingChange         = errors.New("no pending change to confirm")
+	ErrSameContact             = errors.New("new value matches the current one")
+	ErrPhoneAlreadyInUse       = errors.New("phone number is already verified by another account")
+	ErrProfileModified         = errors.New("profile was modified by another request, reload and retry")
+	ErrAccountDeleted          = errors.New("account is scheduled for deletion")
+	ErrAccountNotDeleted       = errors.New("account is not scheduled for deletion")
+	ErrAccountSuspended        = errors.New("account is suspended")
+	ErrAccountDisabled         = errors.New("account is disabled")
+	ErrPasswordResetRequired   = errors.New("password reset required, check your email for a reset link")
+	ErrPasswordResetInvalid    = errors.New("password reset link expired or invalid")
+	ErrAdminSelfAction         = errors.New("administrators cannot apply this action to their own account")
+	ErrLoginAlertInvalid       = errors.New("sign-in alert link expired or invalid")
+	ErrCaptchaRequired         = errors.New("captcha verification required")
+	ErrCaptchaInvalid          = errors.New("captcha verification failed")
+	ErrIPDenied                = errors.New("access from your network is not allowed")
+	ErrStepUpRequired          = errors.New("confirm your password and, if enabled, a two-factor code")
+	ErrInvalidNetwork          = errors.New("invalid network, expected an IP address or CIDR")
+	ErrIPBlockNotFound         = errors.New("no failed sign-ins recorded for this network")
 )
+
+// LoginThrottledError следующая попытка входа с этой пары email/сеть возможна через RetryAfter.
+// Через errors.Is сравнивается с ErrTooManyAttempts.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// AccountExport выгрузка всех данных пользователя (GET /me/export).
+// Секреты — хеши паролей, ключей и refresh-токены — в выгрузку не попадают.
+type AccountExport struct {
+	ExportedAt  time.Time             `json:"exported_at"`
+	Profile     *User                 `json:"profile"`
+	Sessions    []SessionInfo         `json:"sessions"`
+	AuditEvents []*AuditEvent         `json:"audit_events"`
+	APIKeys     []*APIKey             `json:"api_keys"`
+	Passkeys    []*WebAuthnCredential `json:"passkeys"`
+	TwoFactor   ExportedTwoFactor     `json:"two_factor"`
+}
+
+// SessionInfo сессия без refresh-токена, для выгрузки и административного просмотра
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	IsRevoked bool      `json:"is_revoked"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Scope     string    `json:"scope,omitempty"`
+}
+
+type ExportedTwoFactor struct {
+	TOTPEnabled     bool       `json:"totp_enabled"`
+	TOTPConfirmedAt *time.Time `json:"totp_confirmed_at,omitempty"`
+	SMSEnabled      bool       `json:"sms_enabled"`
+}
+
+func NewSessionInfo(session *Session) SessionInfo {
+	return SessionInfo{
+		ID:        session.ID,
+		CreatedAt: session.CreatedAt,
+		ExpiresAt: session.ExpiresAt,
+		UserAgent: session.UserAgent,
+		IP:        session.IP,
+		IsRevoked: session.IsRevoked,
+		ClientID:  session.ClientID,
+		Scope:     session.Scope,
+	}
+}
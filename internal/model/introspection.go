@@ -0,0 +1,18 @@
+package model
+
+// Типы токенов, которые понимает интроспекция (совпадают со значениями token_type_hint из RFC 7009)
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// TokenIntrospection ответ эндпоинта интроспекции (RFC 7662)
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	Sub       string `json:"sub,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+}
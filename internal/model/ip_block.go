@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// IPBlock неудачные входы с одной сети. Сеть блокируется, когда Failures достигает Limit.
+type IPBlock struct {
+	Network  string    `json:"network"`
+	Failures int       `json:"failures"`
+	Limit    int       `json:"limit"`
+	Blocked  bool      `json:"blocked"`
+	ResetAt  time.Time `json:"reset_at"` // Когда счетчик обнулится сам
+}
+
+// IPAccessLists действующие списки разрешенных и запрещенных сетей
+type IPAccessLists struct {
+	Allow    []string  `json:"allow"`
+	Deny     []string  `json:"deny"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// IPBlockList ответ GET /admin/ip-blocks
+type IPBlockList struct {
+	Blocks []IPBlock      `json:"blocks"`
+	Lists  *IPAccessLists `json:"lists"`
+}
@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// Способы подтверждения второго фактора
+const (
+	MFAMethodTOTP         = "totp"
+	MFAMethodRecoveryCode = "recovery_code"
+	MFAMethodSMS          = "sms"
+)
+
+// UserMFA настройки второго фактора пользователя
+type UserMFA struct {
+	UserID          string     `json:"user_id" db:"user_id"`
+	TOTPSecret      []byte     `json:"-" db:"totp_secret"` // Зашифрован
+	TOTPConfirmedAt *time.Time `json:"totp_confirmed_at,omitempty" db:"totp_confirmed_at"`
+	TOTPLastStep    int64      `json:"-" db:"totp_last_step"`
+	SMSEnabled      bool       `json:"sms_enabled" db:"sms_enabled"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (m *UserMFA) TOTPEnabled() bool {
+	return m != nil && m.TOTPConfirmedAt != nil
+}
+
+func (m *UserMFA) SMSFactorEnabled() bool {
+	return m != nil && m.SMSEnabled
+}
+
+// TOTPEnrollment данные для добавления аккаунта в приложение-аутентификатор
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"otpauth_uri"`
+}
+
+// MFAChallenge ответ /login, когда после пароля требуется второй фактор.
+// MFAToken нужно обменять на токены в /login/mfa.
+type MFAChallenge struct {
+	MFARequired bool     `json:"mfa_required"`
+	MFAToken    string   `json:"mfa_token"`
+	Methods     []string `json:"methods"`
+	ExpiresIn   int      `json:"expires_in"`
+	SMSSent     bool     `json:"sms_sent,omitempty"` // Код уже отправлен на телефон пользователя
+}
+
+// LoginResult результат входа: либо токены, либо требование второго фактора
+type LoginResult struct {
+	Tokens    *AuthTokens
+	Challenge *MFAChallenge
+}
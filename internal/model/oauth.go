@@ -0,0 +1,127 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Поддерживаемые типы грантов OAuth 2.0
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// Коды ошибок OAuth 2.0 (RFC 6749, 4.1.2.1 и 5.2)
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthAccessDenied            = "access_denied"
+	OAuthServerError             = "server_error"
+)
+
+// PKCEMethodS256 единственный поддерживаемый метод PKCE (plain не принимаем)
+const PKCEMethodS256 = "S256"
+
+type OAuthClient struct {
+	ID             string    `json:"client_id" db:"id"`
+	Name           string    `json:"name" db:"name"`
+	Confidential   bool      `json:"confidential" db:"is_confidential"`
+	ServiceAccount bool      `json:"service_account" db:"is_service_account"` // Машинный клиент без пользователя
+	RedirectURIs   []string  `json:"redirect_uris" db:"redirect_uris"`
+	GrantTypes     []string  `json:"grant_types" db:"grant_types"`
+	Scopes         []string  `json:"scopes" db:"scopes"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// IsConfidential сообщает, может ли клиент хранить секрет
+func (c *OAuthClient) IsConfidential() bool {
+	return c.Confidential
+}
+
+// OAuthClientSecret секрет конфиденциального клиента. Их может быть несколько одновременно:
+// после ротации предыдущий секрет действует до ExpiresAt.
+type OAuthClientSecret struct {
+	ID         string     `json:"id" db:"id"`
+	ClientID   string     `json:"client_id" db:"client_id"`
+	SecretHash string     `json:"-" db:"secret_hash"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+}
+
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+// AllowsRedirectURI сравнивает redirect_uri строго, без нормализации
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+// AllowsScope проверяет, что все запрошенные scope разрешены клиенту
+func (c *OAuthClient) AllowsScope(scopes []string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// AuthorizationRequest проверенные параметры запроса к /authorize
+type AuthorizationRequest struct {
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	RedirectURISent     bool   `json:"redirect_uri_sent"` // false — подставлен единственный зарегистрированный
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// AuthorizationCode данные, привязанные к выданному коду авторизации
+type AuthorizationCode struct {
+	ClientID            string    `json:"client_id"`
+	UserID              string    `json:"user_id"`
+	RedirectURI         string    `json:"redirect_uri"`
+	RedirectURISent     bool      `json:"redirect_uri_sent"` // Тогда /token обязан повторить redirect_uri
+	Scope               string    `json:"scope"`
+	CodeChallenge       string    `json:"code_challenge"`
+	CodeChallengeMethod string    `json:"code_challenge_method"`
+	UserAgent           string    `json:"user_agent"`
+	IP                  string    `json:"ip"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// OAuthError ошибка, которая отдается клиенту в формате RFC 6749
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func NewOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// ParseScope разбирает scope, разделенный пробелами
+func ParseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+func JoinScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
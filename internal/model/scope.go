@@ -0,0 +1,20 @@
+package model
+
+import "slices"
+
+// Scope, которыми ограничиваются API-ключи и токены OAuth-клиентов. Каждый маршрут, доступный
+// таким учетным данным, требует свой scope; маршруты без scope им недоступны.
+const (
+	ScopeProfileRead  = "profile:read"  // GET /me
+	ScopeProfileWrite = "profile:write" // Повторная отправка письма подтверждения
+	ScopeSecurityRead = "security:read" // Журнал входов и изменений учетных данных
+	ScopeAPIKeysRead  = "api_keys:read" // Список своих API-ключей
+)
+
+// KnownScopes все scope, которые проверяют маршруты
+var KnownScopes = []string{ScopeProfileRead, ScopeProfileWrite, ScopeSecurityRead, ScopeAPIKeysRead}
+
+// IsKnownScope сообщает, проверяет ли какой-нибудь маршрут этот scope
+func IsKnownScope(scope string) bool {
+	return slices.Contains(KnownScopes, scope)
+}
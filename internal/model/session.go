@@ -14,6 +14,8 @@ type Session struct {
 	UserAgent    string    `json:"user_agent" db:"user_agent"`
 	IP           string    `json:"ip" db:"ip"`
 	IsRevoked    bool      `json:"is_revoked" db:"is_revoked"`
+	ClientID     string    `json:"client_id,omitempty" db:"client_id"` // Пусто у сессий, созданных через /login
+	Scope        string    `json:"scope,omitempty" db:"scope"`
 }
 
 // SetIP безопасно устанавливает IP с валидацией
@@ -1,13 +1,127 @@
 package model
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
 
 type User struct {
-	ID        string    `json:"id" db:"id"`
-	Email     string    `json:"email" db:"email"`
-	Phone     string    `json:"phone,omitempty" db:"phone"` // Новое поле
-	Verified  bool      `json:"verified" db:"verified"`     // Новое поле
-	Password  string    `json:"-" db:"password_hash"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID            string     `json:"id" db:"id"`
+	Email         string     `json:"email" db:"email"`
+	Phone         string     `json:"phone,omitempty" db:"phone"`                 // E.164
+	PhoneCountry  string     `json:"phone_country,omitempty" db:"phone_country"` // Страна номера, ISO 3166-1 alpha-2
+	EmailVerified bool       `json:"email_verified" db:"email_verified"`
+	PhoneVerified bool       `json:"phone_verified" db:"phone_verified"`
+	PendingEmail  string     `json:"pending_email,omitempty" db:"pending_email"` // Ждет подтверждения, см. AccountService
+	PendingPhone  string     `json:"pending_phone,omitempty" db:"pending_phone"`
+	DisplayName   string     `json:"display_name" db:"display_name"`
+	Locale        string     `json:"locale" db:"locale"`
+	Timezone      string     `json:"timezone" db:"timezone"`
+	AvatarURL     string     `json:"avatar_url" db:"avatar_url"`
+	Password      string     `json:"-" db:"password_hash"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty" db:"deleted_at"` // Аккаунт ждет окончательного удаления
+
+	Role                  string     `json:"role" db:"role"`
+	Status                string     `json:"status" db:"status"`
+	StatusReason          string     `json:"status_reason,omitempty" db:"status_reason"` // Причина, указанная администратором
+	SuspendedUntil        *time.Time `json:"suspended_until,omitempty" db:"suspended_until"`
+	PasswordResetRequired bool       `json:"password_reset_required,omitempty" db:"password_reset_required"`
+}
+
+// Состояния аккаунта
+const (
+	UserStatusActive          = "active"
+	UserStatusSuspended       = "suspended" // До SuspendedUntil, затем снова активен
+	UserStatusDisabled        = "disabled"  // До решения администратора
+	UserStatusPendingDeletion = "pending_deletion"
+)
+
+// StatusError возвращает *AccountStatusError, если состояние аккаунта не позволяет входить
+func (u *User) StatusError(now time.Time) error {
+	switch u.Status {
+	case UserStatusSuspended:
+		if u.SuspendedUntil != nil && !now.Before(*u.SuspendedUntil) {
+			return nil
+		}
+	case UserStatusDisabled, UserStatusPendingDeletion:
+	default:
+		return nil
+	}
+
+	return &AccountStatusError{
+		Status: u.Status,
+		Reason: u.StatusReason,
+		Until:  u.SuspendedUntil,
+	}
+}
+
+// Роли пользователей
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// IsAdmin сообщает, что пользователю доступен /admin
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// ETag версия записи для условных запросов к /me. Меняется при каждом обновлении пользователя.
+func (u *User) ETag() string {
+	return `"` + strconv.FormatInt(u.UpdatedAt.UnixMicro(), 36) + `"`
+}
+
+// RegistrationResult ответ /register. VerificationTicket вместе с кодом из SMS
+// передается в /verify-phone и привязывает подтверждение к этому аккаунту.
+type RegistrationResult struct {
+	User               *User  `json:"user"`
+	VerificationTicket string `json:"verification_ticket"`
+}
+
+// AccountStatusError вход запрещен состоянием аккаунта. Через errors.Is сравнивается
+// с ErrAccountSuspended, ErrAccountDisabled или ErrAccountDeleted.
+type AccountStatusError struct {
+	Status string
+	Reason string
+	Until  *time.Time
+}
+
+func (e *AccountStatusError) Error() string {
+	if e.Status == UserStatusSuspended && e.Until != nil {
+		return e.Unwrap().Error() + " until " + e.Until.UTC().Format(time.RFC3339)
+	}
+	return e.Unwrap().Error()
+}
+
+func (e *AccountStatusError) Unwrap() error {
+	switch e.Status {
+	case UserStatusSuspended:
+		return ErrAccountSuspended
+	case UserStatusPendingDeletion:
+		return ErrAccountDeleted
+	default:
+		return ErrAccountDisabled
+	}
+}
+
+// Code машинно-читаемый код для ответа API
+func (e *AccountStatusError) Code() string {
+	return "account_" + e.Status
+}
+
+// StatusChange тело POST /admin/users/{id}/status
+type StatusChange struct {
+	Status         string     `json:"status"`
+	Reason         string     `json:"reason"`
+	SuspendedUntil *time.Time `json:"suspended_until"`
+}
+
+// ProfileUpdate тело PATCH /me. Поля, которых нет в запросе, не меняются; пустая строка очищает поле.
+type ProfileUpdate struct {
+	DisplayName *string `json:"display_name"`
+	Locale      *string `json:"locale"`
+	Timezone    *string `json:"timezone"`
+	AvatarURL   *string `json:"avatar_url"`
 }
@@ -0,0 +1,28 @@
+package model
+
+// ValidationError ошибки проверки отдельных полей запроса: поле -> описание
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	return "validation failed"
+}
+
+// Add запоминает первую ошибку для поля
+func (e *ValidationError) Add(field, message string) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]string)
+	}
+	if _, exists := e.Fields[field]; !exists {
+		e.Fields[field] = message
+	}
+}
+
+// OrNil возвращает nil, если ошибок не было
+func (e *ValidationError) OrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
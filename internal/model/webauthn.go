@@ -0,0 +1,38 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebAuthnCredential ключ (passkey), зарегистрированный пользователем
+type WebAuthnCredential struct {
+	ID              string     `json:"id" db:"id"`
+	UserID          string     `json:"user_id" db:"user_id"`
+	CredentialID    []byte     `json:"credential_id" db:"credential_id"`
+	PublicKey       []byte     `json:"-" db:"public_key"`
+	AttestationType string     `json:"-" db:"attestation_type"`
+	AAGUID          []byte     `json:"aaguid,omitempty" db:"aaguid"`
+	SignCount       uint32     `json:"-" db:"sign_count"`
+	CloneWarning    bool       `json:"clone_warning" db:"clone_warning"`
+	Transports      []string   `json:"transports" db:"transports"`
+	Flags           uint8      `json:"-" db:"flags"`
+	Name            string     `json:"name" db:"name"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// WebAuthnCeremony параметры для navigator.credentials.create()/get().
+// CeremonyID нужно вернуть вместе с ответом аутентификатора.
+type WebAuthnCeremony struct {
+	CeremonyID string          `json:"ceremony_id"`
+	Options    json.RawMessage `json:"options"`
+	ExpiresIn  int             `json:"expires_in"`
+}
+
+// StepUp повторное подтверждение личности перед добавлением passkey
+type StepUp struct {
+	Password  string `json:"password"`
+	MFAMethod string `json:"mfa_method"` // totp, recovery_code или sms; нужен, если второй фактор включен
+	MFACode   string `json:"mfa_code"`
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// BackoffPolicy экспоненциальная задержка после неудач: первые FreeFailures проходят без задержки,
+// дальше BaseDelay, 2×BaseDelay, 4×BaseDelay... но не больше MaxDelay. Счетчик забывается,
+// если неудач не было Window.
+type BackoffPolicy struct {
+	Name         string
+	FreeFailures int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	Window       time.Duration
+}
+
+// Delay задержка после failures неудач подряд
+func (p BackoffPolicy) Delay(failures int) time.Duration {
+	if failures <= p.FreeFailures {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeFailures + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// BackoffState сколько неудач накоплено и сколько еще ждать до следующей попытки
+type BackoffState struct {
+	Failures   int
+	RetryAfter time.Duration
+}
+
+// FailureTracker считает неудачи для экспоненциальной задержки
+type FailureTracker interface {
+	Failures(ctx context.Context, policy BackoffPolicy, key string) (*BackoffState, error)
+	RecordFailure(ctx context.Context, policy BackoffPolicy, key string) (*BackoffState, error)
+	ClearFailures(ctx context.Context, policy BackoffPolicy, key string) error
+}
+
+// backoffState задержка считается от последней неудачи
+func backoffState(policy BackoffPolicy, failures int, sinceLast time.Duration) *BackoffState {
+	return &BackoffState{
+		Failures:   failures,
+		RetryAfter: max(policy.Delay(failures)-sinceLast, 0),
+	}
+}
+
+func backoffKey(policy BackoffPolicy, key string) string {
+	return "ratelimit:" + policy.Name + ":" + key
+}
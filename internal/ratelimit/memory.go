@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryLimiter те же алгоритмы в памяти процесса. Для тестов и запуска в одном экземпляре:
+// счетчики не делятся между процессами и не очищаются от неактивных ключей.
+type MemoryLimiter struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	windows  map[string][]time.Time
+	buckets  map[string]*memoryBucket
+	failures map[string]*memoryFailures
+}
+
+type memoryFailures struct {
+	count int
+	last  time.Time
+}
+
+type memoryBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// NewMemoryLimiter принимает источник времени, чтобы тесты могли им управлять; nil — time.Now
+func NewMemoryLimiter(now func() time.Time) *MemoryLimiter {
+	if now == nil {
+		now = time.Now
+	}
+	return &MemoryLimiter{
+		now:      now,
+		windows:  make(map[string][]time.Time),
+		buckets:  make(map[string]*memoryBucket),
+		failures: make(map[string]*memoryFailures),
+	}
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, policy Policy, key string) (*Result, error) {
+	return l.run(policy, key, true)
+}
+
+func (l *MemoryLimiter) Peek(_ context.Context, policy Policy, key string) (*Result, error) {
+	return l.run(policy, key, false)
+}
+
+func (l *MemoryLimiter) Reset(_ context.Context, policy Policy, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.windows, storageKey(policy, key))
+	delete(l.buckets, storageKey(policy, key))
+	return nil
+}
+
+func (l *MemoryLimiter) Entries(_ context.Context, policy Policy) ([]Entry, error) {
+	prefix := storagePrefix(policy)
+
+	l.mu.Lock()
+	keys := make([]string, 0)
+	for key := range l.windows {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, strings.TrimPrefix(key, prefix))
+		}
+	}
+	for key := range l.buckets {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, strings.TrimPrefix(key, prefix))
+		}
+	}
+	l.mu.Unlock()
+
+	entries := make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		result, err := l.run(policy, key, false)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Key: key, Result: result})
+	}
+	return entries, nil
+}
+
+func (l *MemoryLimiter) run(policy Policy, key string, consume bool) (*Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch policy.Algorithm {
+	case SlidingWindow:
+		return l.slidingWindow(policy, storageKey(policy, key), consume), nil
+	case TokenBucket:
+		return l.tokenBucket(policy, storageKey(policy, key), consume), nil
+	default:
+		return nil, fmt.Errorf("rate limit %s: unknown algorithm %q", policy.Name, policy.Algorithm)
+	}
+}
+
+func (l *MemoryLimiter) slidingWindow(policy Policy, key string, consume bool) *Result {
+	now := l.now()
+
+	events := l.windows[key]
+	for len(events) > 0 && !events[0].After(now.Add(-policy.Window)) {
+		events = events[1:]
+	}
+
+	result := &Result{Limit: policy.Limit}
+	if len(events) < policy.Limit {
+		result.Allowed = true
+		if consume {
+			events = append(events, now)
+		}
+	}
+
+	if len(events) == 0 {
+		delete(l.windows, key)
+	} else {
+		l.windows[key] = events
+		result.Reset = events[0].Add(policy.Window).Sub(now)
+	}
+	result.Remaining = max(policy.Limit-len(events), 0)
+	if !result.Allowed {
+		result.RetryAfter = result.Reset
+	}
+
+	return result
+}
+
+func (l *MemoryLimiter) tokenBucket(policy Policy, key string, consume bool) *Result {
+	now := l.now()
+	capacity := float64(policy.Limit)
+	rate := capacity / float64(policy.Window) // Токенов за наносекунду
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: capacity, ts: now}
+	}
+	tokens := math.Min(capacity, bucket.tokens+float64(max(now.Sub(bucket.ts), 0))*rate)
+
+	result := &Result{Limit: policy.Limit}
+	if tokens >= 1 {
+		result.Allowed = true
+		if consume {
+			tokens--
+			l.buckets[key] = &memoryBucket{tokens: tokens, ts: now}
+		}
+	} else {
+		result.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rate))
+	}
+
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration(math.Ceil((capacity - tokens) / rate))
+	return result
+}
+
+func (l *MemoryLimiter) Failures(_ context.Context, policy BackoffPolicy, key string) (*BackoffState, error) {
+	return l.backoff(policy, key, false), nil
+}
+
+func (l *MemoryLimiter) RecordFailure(_ context.Context, policy BackoffPolicy, key string) (*BackoffState, error) {
+	return l.backoff(policy, key, true), nil
+}
+
+func (l *MemoryLimiter) ClearFailures(_ context.Context, policy BackoffPolicy, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.failures, backoffKey(policy, key))
+	return nil
+}
+
+func (l *MemoryLimiter) backoff(policy BackoffPolicy, key string, record bool) *BackoffState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	key = backoffKey(policy, key)
+
+	state, ok := l.failures[key]
+	if ok && now.Sub(state.last) >= policy.Window {
+		delete(l.failures, key)
+		state, ok = nil, false
+	}
+
+	if record {
+		if !ok {
+			state = &memoryFailures{}
+			l.failures[key] = state
+		}
+		state.count++
+		state.last = now
+	} else if !ok {
+		return &BackoffState{}
+	}
+
+	return backoffState(policy, state.count, now.Sub(state.last))
+}
@@ -0,0 +1,251 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock время, которое тест двигает вручную
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// limiterStep один вызов лимитера: сдвиг времени перед ним и ожидаемый результат
+type limiterStep struct {
+	advance    time.Duration
+	peek       bool
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
+func runSteps(t *testing.T, policy Policy, steps []limiterStep) {
+	t.Helper()
+
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewMemoryLimiter(clock.now)
+	ctx := context.Background()
+
+	for i, step := range steps {
+		clock.advance(step.advance)
+
+		var (
+			result *Result
+			err    error
+		)
+		if step.peek {
+			result, err = limiter.Peek(ctx, policy, "key")
+		} else {
+			result, err = limiter.Allow(ctx, policy, "key")
+		}
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+
+		if result.Allowed != step.allowed || result.Remaining != step.remaining ||
+			result.RetryAfter != step.retryAfter || result.Reset != step.reset || result.Limit != policy.Limit {
+			t.Fatalf("step %d: got %+v, want allowed=%v remaining=%d retryAfter=%v reset=%v",
+				i, *result, step.allowed, step.remaining, step.retryAfter, step.reset)
+		}
+	}
+}
+
+func TestMemoryLimiterSlidingWindow(t *testing.T) {
+	policy := Policy{Name: "test", Algorithm: SlidingWindow, Limit: 3, Window: time.Minute}
+
+	tests := []struct {
+		name  string
+		steps []limiterStep
+	}{
+		{
+			name: "limit reached",
+			steps: []limiterStep{
+				{allowed: true, remaining: 2, reset: time.Minute},
+				{advance: 10 * time.Second, allowed: true, remaining: 1, reset: 50 * time.Second},
+				{advance: 10 * time.Second, allowed: true, remaining: 0, reset: 40 * time.Second},
+				// Отклоненный запрос не учитывается и не сдвигает окно
+				{advance: 10 * time.Second, allowed: false, remaining: 0, retryAfter: 30 * time.Second, reset: 30 * time.Second},
+				{allowed: false, remaining: 0, retryAfter: 30 * time.Second, reset: 30 * time.Second},
+			},
+		},
+		{
+			name: "event expires exactly at window end",
+			steps: []limiterStep{
+				{allowed: true, remaining: 2, reset: time.Minute},
+				{allowed: true, remaining: 1, reset: time.Minute},
+				{advance: 30 * time.Second, allowed: true, remaining: 0, reset: 30 * time.Second},
+				{advance: 30*time.Second - time.Nanosecond, allowed: false, remaining: 0, retryAfter: time.Nanosecond, reset: time.Nanosecond},
+				// Ровно через Window первые два события выпадают из окна
+				{advance: time.Nanosecond, allowed: true, remaining: 1, reset: 30 * time.Second},
+			},
+		},
+		{
+			name: "window slides instead of resetting",
+			steps: []limiterStep{
+				{allowed: true, remaining: 2, reset: time.Minute},
+				{advance: 40 * time.Second, allowed: true, remaining: 1, reset: 20 * time.Second},
+				{allowed: true, remaining: 0, reset: 20 * time.Second},
+				// Освободилось только одно место — от первого события
+				{advance: 20 * time.Second, allowed: true, remaining: 0, reset: 40 * time.Second},
+				{allowed: false, remaining: 0, retryAfter: 40 * time.Second, reset: 40 * time.Second},
+			},
+		},
+		{
+			name: "peek does not consume",
+			steps: []limiterStep{
+				{peek: true, allowed: true, remaining: 3},
+				{allowed: true, remaining: 2, reset: time.Minute},
+				{peek: true, allowed: true, remaining: 2, reset: time.Minute},
+				{peek: true, allowed: true, remaining: 2, reset: time.Minute},
+				{advance: time.Minute, peek: true, allowed: true, remaining: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSteps(t, policy, tt.steps)
+		})
+	}
+}
+
+func TestMemoryLimiterTokenBucket(t *testing.T) {
+	// Одно место восстанавливается за 10 секунд
+	policy := Policy{Name: "test", Algorithm: TokenBucket, Limit: 6, Window: time.Minute}
+	perToken := 10 * time.Second
+
+	tests := []struct {
+		name  string
+		steps []limiterStep
+	}{
+		{
+			name: "burst up to capacity",
+			steps: []limiterStep{
+				{allowed: true, remaining: 5, reset: perToken},
+				{allowed: true, remaining: 4, reset: 2 * perToken},
+				{allowed: true, remaining: 3, reset: 3 * perToken},
+				{allowed: true, remaining: 2, reset: 4 * perToken},
+				{allowed: true, remaining: 1, reset: 5 * perToken},
+				{allowed: true, remaining: 0, reset: 6 * perToken},
+				{allowed: false, remaining: 0, retryAfter: perToken, reset: 6 * perToken},
+			},
+		},
+		{
+			name: "refill",
+			steps: []limiterStep{
+				{allowed: true, remaining: 5, reset: perToken},
+				{allowed: true, remaining: 4, reset: 2 * perToken},
+				{allowed: true, remaining: 3, reset: 3 * perToken},
+				{allowed: true, remaining: 2, reset: 4 * perToken},
+				{allowed: true, remaining: 1, reset: 5 * perToken},
+				{allowed: true, remaining: 0, reset: 6 * perToken},
+				// Половина токена: еще рано
+				{advance: perToken / 2, allowed: false, remaining: 0, retryAfter: perToken / 2, reset: 5*perToken + perToken/2},
+				{advance: perToken / 2, allowed: true, remaining: 0, reset: 6 * perToken},
+				// Два токена за 20 секунд
+				{advance: 2 * perToken, allowed: true, remaining: 1, reset: 5 * perToken},
+			},
+		},
+		{
+			name: "refill stops at capacity",
+			steps: []limiterStep{
+				{allowed: true, remaining: 5, reset: perToken},
+				{advance: time.Hour, allowed: true, remaining: 5, reset: perToken},
+				{advance: time.Hour, peek: true, allowed: true, remaining: 6},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runSteps(t, policy, tt.steps)
+		})
+	}
+}
+
+func TestMemoryLimiterKeysAndReset(t *testing.T) {
+	policy := Policy{Name: "test", Algorithm: SlidingWindow, Limit: 1, Window: time.Minute}
+	other := Policy{Name: "other", Algorithm: SlidingWindow, Limit: 1, Window: time.Minute}
+	limiter := NewMemoryLimiter(nil)
+	ctx := context.Background()
+
+	for _, call := range []struct {
+		policy  Policy
+		key     string
+		allowed bool
+	}{
+		{policy, "a", true},
+		{policy, "a", false},
+		{policy, "b", true}, // Другой ключ
+		{other, "a", true},  // Другое правило с тем же ключом
+	} {
+		result, err := limiter.Allow(ctx, call.policy, call.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != call.allowed {
+			t.Fatalf("%s/%s: allowed = %v, want %v", call.policy.Name, call.key, result.Allowed, call.allowed)
+		}
+	}
+
+	entries, err := limiter.Entries(ctx, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v, want keys a and b", entries)
+	}
+
+	if err := limiter.Reset(ctx, policy, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if result, _ := limiter.Allow(ctx, policy, "a"); !result.Allowed {
+		t.Fatal("reset key must be allowed again")
+	}
+	if result, _ := limiter.Allow(ctx, other, "a"); result.Allowed {
+		t.Fatal("reset must not affect other policies")
+	}
+}
+
+func TestMemoryLimiterUnknownAlgorithm(t *testing.T) {
+	limiter := NewMemoryLimiter(nil)
+	if _, err := limiter.Allow(context.Background(), Policy{Name: "bad", Algorithm: "fixed", Limit: 1, Window: time.Second}, "key"); err == nil {
+		t.Fatal("expected an error for an unknown algorithm")
+	}
+}
+
+func TestMemoryLimiterBackoff(t *testing.T) {
+	policy := BackoffPolicy{Name: "backoff", FreeFailures: 2, BaseDelay: time.Second, MaxDelay: 4 * time.Second, Window: time.Hour}
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limiter := NewMemoryLimiter(clock.now)
+	ctx := context.Background()
+
+	var delays []time.Duration
+	for i := 0; i < 6; i++ {
+		state, err := limiter.RecordFailure(ctx, policy, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		delays = append(delays, state.RetryAfter)
+	}
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Fatalf("delays = %v, want %v", delays, want)
+		}
+	}
+
+	clock.advance(3 * time.Second)
+	if state, _ := limiter.Failures(ctx, policy, "key"); state.Failures != 6 || state.RetryAfter != time.Second {
+		t.Fatalf("after 3s: %+v", *state)
+	}
+
+	clock.advance(policy.Window)
+	if state, _ := limiter.Failures(ctx, policy, "key"); state.Failures != 0 || state.RetryAfter != 0 {
+		t.Fatalf("failures must be forgotten after the window: %+v", *state)
+	}
+}
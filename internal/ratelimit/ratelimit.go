@@ -0,0 +1,117 @@
+// Package ratelimit ограничение частоты запросов: скользящее окно и token bucket.
+// RedisLimiter для работы нескольких экземпляров сервиса, MemoryLimiter — для тестов и одного процесса.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Algorithm способ подсчета
+type Algorithm string
+
+const (
+	// SlidingWindow не более Limit событий за любые Window подряд
+	SlidingWindow Algorithm = "sliding"
+	// TokenBucket ведро на Limit запросов, которое полностью наполняется за Window; допускает всплески
+	TokenBucket Algorithm = "bucket"
+)
+
+// Policy правило ограничения. Name входит в ключ хранилища, поэтому у разных правил он должен различаться.
+type Policy struct {
+	Name      string
+	Algorithm Algorithm
+	Limit     int
+	Window    time.Duration
+	// FailClosed отклонять запросы, если хранилище счетчиков недоступно. Без него правило
+	// в этом случае не действует — подходит для маршрутов, где отказ хуже перебора.
+	FailClosed bool
+}
+
+// String описание правила для заголовка RateLimit-Policy: "20;w=60"
+func (p Policy) String() string {
+	return fmt.Sprintf("%d;w=%d", p.Limit, int(math.Ceil(p.Window.Seconds())))
+}
+
+// ParsePolicy разбирает правило вида "20/1m" (скользящее окно) или "bucket:30/1m"
+func ParsePolicy(name, spec string) (Policy, error) {
+	policy := Policy{Name: name, Algorithm: SlidingWindow}
+
+	if algorithm, rest, ok := strings.Cut(spec, ":"); ok {
+		policy.Algorithm = Algorithm(algorithm)
+		spec = rest
+	}
+	if policy.Algorithm != SlidingWindow && policy.Algorithm != TokenBucket {
+		return Policy{}, fmt.Errorf("rate limit %s: unknown algorithm %q", name, policy.Algorithm)
+	}
+
+	limit, window, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("rate limit %s: expected <limit>/<window>, got %q", name, spec)
+	}
+
+	var err error
+	if policy.Limit, err = strconv.Atoi(limit); err != nil || policy.Limit <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %s: invalid limit %q", name, limit)
+	}
+	if policy.Window, err = time.ParseDuration(window); err != nil || policy.Window <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %s: invalid window %q", name, window)
+	}
+
+	return policy, nil
+}
+
+// Result решение по запросу
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int           // Сколько еще запросов пройдет сейчас
+	RetryAfter time.Duration // Через сколько повторить, если запрос отклонен
+	Reset      time.Duration // Через сколько квота восстановится полностью
+}
+
+// Limiter хранилище счетчиков
+type Limiter interface {
+	// Allow учитывает запрос, если он укладывается в правило. Отклоненные запросы не учитываются.
+	Allow(ctx context.Context, policy Policy, key string) (*Result, error)
+	// Peek сообщает, прошел бы запрос, ничего не учитывая
+	Peek(ctx context.Context, policy Policy, key string) (*Result, error)
+	// Reset обнуляет счетчик ключа
+	Reset(ctx context.Context, policy Policy, key string) error
+	// Entries состояние всех ключей правила, по которым сейчас есть учтенные запросы
+	Entries(ctx context.Context, policy Policy) ([]Entry, error)
+}
+
+// Entry состояние одного ключа
+type Entry struct {
+	Key    string
+	Result *Result
+}
+
+// WriteHeaders выставляет RateLimit-* (draft-ietf-httpapi-ratelimit-headers) и, при отказе, Retry-After
+func WriteHeaders(h http.Header, policy Policy, result *Result) {
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	h.Set("RateLimit-Policy", policy.String())
+	if !result.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func storageKey(policy Policy, key string) string {
+	return storagePrefix(policy) + key
+}
+
+func storagePrefix(policy Policy) string {
+	return "ratelimit:" + policy.Name + ":"
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Policy
+		wantErr bool
+	}{
+		{spec: "20/1m", want: Policy{Name: "p", Algorithm: SlidingWindow, Limit: 20, Window: time.Minute}},
+		{spec: "sliding:5/1h", want: Policy{Name: "p", Algorithm: SlidingWindow, Limit: 5, Window: time.Hour}},
+		{spec: "bucket:30/1m", want: Policy{Name: "p", Algorithm: TokenBucket, Limit: 30, Window: time.Minute}},
+		{spec: "fixed:30/1m", wantErr: true},
+		{spec: "30", wantErr: true},
+		{spec: "0/1m", wantErr: true},
+		{spec: "-1/1m", wantErr: true},
+		{spec: "x/1m", wantErr: true},
+		{spec: "10/0s", wantErr: true},
+		{spec: "10/minute", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParsePolicy("p", tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	policy := Policy{Name: "login", Algorithm: SlidingWindow, Limit: 20, Window: 90 * time.Second}
+
+	tests := []struct {
+		name   string
+		result Result
+		want   map[string]string // Пустое значение — заголовка быть не должно
+	}{
+		{
+			name:   "allowed",
+			result: Result{Allowed: true, Limit: 20, Remaining: 7, Reset: 42 * time.Second},
+			want: map[string]string{
+				"RateLimit-Limit":     "20",
+				"RateLimit-Remaining": "7",
+				"RateLimit-Reset":     "42",
+				"RateLimit-Policy":    "20;w=90",
+				"Retry-After":         "",
+			},
+		},
+		{
+			name:   "fractional seconds round up",
+			result: Result{Allowed: false, Limit: 20, Remaining: 0, RetryAfter: 1500 * time.Millisecond, Reset: 2100 * time.Millisecond},
+			want: map[string]string{
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "3",
+				"Retry-After":         "2",
+			},
+		},
+		{
+			name:   "retry after is at least one second",
+			result: Result{Allowed: false, Limit: 20, RetryAfter: time.Millisecond, Reset: time.Millisecond},
+			want: map[string]string{
+				"RateLimit-Reset": "1",
+				"Retry-After":     "1",
+			},
+		},
+		{
+			name:   "zero retry after on rejection",
+			result: Result{Allowed: false, Limit: 20},
+			want: map[string]string{
+				"RateLimit-Reset": "0",
+				"Retry-After":     "1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			WriteHeaders(h, policy, &tt.result)
+			for name, value := range tt.want {
+				if value == "" {
+					if _, ok := h[http.CanonicalHeaderKey(name)]; ok {
+						t.Errorf("%s must not be set, got %q", name, h.Get(name))
+					}
+					continue
+				}
+				if got := h.Get(name); got != value {
+					t.Errorf("%s = %q, want %q", name, got, value)
+				}
+			}
+		})
+	}
+}
+
+func TestBackoffPolicyDelay(t *testing.T) {
+	policy := BackoffPolicy{FreeFailures: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 8 * time.Second},
+		{8, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.Delay(tt.failures); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLimiter хранит счетчики в Redis. Каждое решение — один Lua-скрипт, поэтому проверка
+// и учет атомарны, а время берется из Redis и одинаково для всех экземпляров сервиса.
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{client: client}
+}
+
+// slidingWindowScript журнал событий в sorted set со временем в миллисекундах.
+// ARGV: окно (мс), лимит, учитывать ли запрос (1/0), уникальный member.
+// Возвращает {allowed, remaining, retry_after_ms, reset_ms}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local consume = ARGV[3] == '1'
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+
+local allowed = 0
+if count < limit then
+	allowed = 1
+	if consume then
+		redis.call('ZADD', key, now, ARGV[4])
+		redis.call('PEXPIRE', key, window)
+		count = count + 1
+	end
+end
+
+local reset = 0
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+
+local retry = 0
+if allowed == 0 then
+	retry = reset
+end
+
+return {allowed, math.max(limit - count, 0), retry, reset}
+`)
+
+// tokenBucketScript ведро в hash {tokens, ts}; пополняется на limit токенов за окно.
+// ARGV: емкость, окно (мс), учитывать ли запрос (1/0).
+// Возвращает {allowed, remaining, retry_after_ms, reset_ms}.
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local consume = ARGV[3] == '1'
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local rate = capacity / window
+
+local state = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	allowed = 1
+	if consume then
+		tokens = tokens - 1
+		redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', tostring(now))
+		redis.call('PEXPIRE', key, math.ceil((capacity - tokens) / rate) + 1000)
+	end
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) / rate)}
+`)
+
+func (l *RedisLimiter) Allow(ctx context.Context, policy Policy, key string) (*Result, error) {
+	return l.run(ctx, policy, key, true)
+}
+
+func (l *RedisLimiter) Peek(ctx context.Context, policy Policy, key string) (*Result, error) {
+	return l.run(ctx, policy, key, false)
+}
+
+func (l *RedisLimiter) Reset(ctx context.Context, policy Policy, key string) error {
+	return l.client.Del(ctx, storageKey(policy, key)).Err()
+}
+
+func (l *RedisLimiter) Entries(ctx context.Context, policy Policy) ([]Entry, error) {
+	prefix := storagePrefix(policy)
+
+	var entries []Entry
+	iter := l.client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := strings.TrimPrefix(iter.Val(), prefix)
+		result, err := l.Peek(ctx, policy, key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Key: key, Result: result})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (l *RedisLimiter) run(ctx context.Context, policy Policy, key string, consume bool) (*Result, error) {
+	flag := "0"
+	if consume {
+		flag = "1"
+	}
+	keys := []string{storageKey(policy, key)}
+	window := policy.Window.Milliseconds()
+
+	var script *redis.Script
+	var args []interface{}
+	switch policy.Algorithm {
+	case SlidingWindow:
+		script, args = slidingWindowScript, []interface{}{window, policy.Limit, flag, uuid.NewString()}
+	case TokenBucket:
+		script, args = tokenBucketScript, []interface{}{policy.Limit, window, flag}
+	default:
+		return nil, fmt.Errorf("rate limit %s: unknown algorithm %q", policy.Name, policy.Algorithm)
+	}
+
+	ints, err := script.Run(ctx, l.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(ints) != 4 {
+		return nil, fmt.Errorf("rate limit %s: unexpected script reply %v", policy.Name, ints)
+	}
+
+	return &Result{
+		Allowed:    ints[0] == 1,
+		Limit:      policy.Limit,
+		Remaining:  int(ints[1]),
+		RetryAfter: time.Duration(ints[2]) * time.Millisecond,
+		Reset:      time.Duration(ints[3]) * time.Millisecond,
+	}, nil
+}
+
+// backoffScript счетчик неудач в hash {failures, last}. ARGV: окно (мс), учитывать ли неудачу (1/0).
+// Возвращает {failures, мс с последней неудачи}.
+var backoffScript = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local record = ARGV[2] == '1'
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local failures = tonumber(redis.call('HGET', key, 'failures') or '0')
+local last = tonumber(redis.call('HGET', key, 'last') or '0')
+
+if record then
+	failures = failures + 1
+	last = now
+	redis.call('HSET', key, 'failures', failures, 'last', now)
+	redis.call('PEXPIRE', key, window)
+end
+
+if failures == 0 then
+	return {0, 0}
+end
+return {failures, now - last}
+`)
+
+func (l *RedisLimiter) Failures(ctx context.Context, policy BackoffPolicy, key string) (*BackoffState, error) {
+	return l.backoff(ctx, policy, key, false)
+}
+
+func (l *RedisLimiter) RecordFailure(ctx context.Context, policy BackoffPolicy, key string) (*BackoffState, error) {
+	return l.backoff(ctx, policy, key, true)
+}
+
+func (l *RedisLimiter) ClearFailures(ctx context.Context, policy BackoffPolicy, key string) error {
+	return l.client.Del(ctx, backoffKey(policy, key)).Err()
+}
+
+func (l *RedisLimiter) backoff(ctx context.Context, policy BackoffPolicy, key string, record bool) (*BackoffState, error) {
+	flag := "0"
+	if record {
+		flag = "1"
+	}
+
+	values, err := backoffScript.Run(ctx, l.client, []string{backoffKey(policy, key)}, policy.Window.Milliseconds(), flag).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 2 {
+		return nil, fmt.Errorf("backoff %s: unexpected script reply %v", policy.Name, values)
+	}
+
+	return backoffState(policy, int(values[0]), time.Duration(values[1])*time.Millisecond), nil
+}
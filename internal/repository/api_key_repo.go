@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"authorization_authentication/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	query := `INSERT INTO api_keys 
+		(id, user_id, name, prefix, secret_hash, scopes, expires_at, created_at) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		key.ID, key.UserID, key.Name, key.Prefix, key.SecretHash,
+		pq.Array(key.Scopes), nullTime(key.ExpiresAt), key.CreatedAt.UTC())
+	return err
+}
+
+func (r *APIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*model.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at,
+		       last_used_at, COALESCE(last_used_ip, ''), created_at, revoked_at
+		FROM api_keys
+		WHERE prefix = $1
+	`
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, prefix))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListUserAPIKeys возвращает все ключи пользователя, включая отозванные и истекшие
+func (r *APIKeyRepository) ListUserAPIKeys(ctx context.Context, userID string) ([]*model.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, secret_hash, scopes, expires_at,
+		       last_used_at, COALESCE(last_used_ip, ''), created_at, revoked_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*model.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// CountActiveUserAPIKeys число неотозванных и неистекших ключей пользователя
+func (r *APIKeyRepository) CountActiveUserAPIKeys(ctx context.Context, userID string) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM api_keys
+		WHERE user_id = $1
+		  AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > NOW())
+	`
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// RevokeAPIKey отзывает ключ, только если он принадлежит userID
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	query := `
+		UPDATE api_keys 
+		SET revoked_at = NOW() 
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		RETURNING id
+	`
+	var id string
+	err := r.db.QueryRowContext(ctx, query, keyID, userID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.ErrAPIKeyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) RevokeAllUserAPIKeys(ctx context.Context, userID string) error {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, keyID string, usedAt time.Time, ip string) error {
+	query := `UPDATE api_keys SET last_used_at = $2, last_used_ip = $3 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, keyID, usedAt.UTC(), ip)
+	return err
+}
+
+// rowScanner общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	var key model.APIKey
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.SecretHash,
+		pq.Array(&key.Scopes),
+		&expiresAt,
+		&lastUsedAt,
+		&key.LastUsedIP,
+		&key.CreatedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.ExpiresAt = timePtr(expiresAt)
+	key.LastUsedAt = timePtr(lastUsedAt)
+	key.RevokedAt = timePtr(revokedAt)
+	return &key, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"authorization_authentication/internal/model"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// CreateEvent добавляет событие в конец цепочки: присваивает Seq, PrevHash и считает Hash
+func (r *AuditRepository) CreateEvent(ctx context.Context, event *model.AuditEvent) error {
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return err
+	}
+	if event.Metadata == nil {
+		metadata = []byte("{}")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// События встают в цепочку по очереди, даже если запущено несколько экземпляров сервера
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('audit_events'))`); err != nil {
+		return err
+	}
+
+	seq, prevHash, err := chainHead(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	event.Seq = seq + 1
+	event.PrevHash = prevHash
+	event.Hash, err = event.ChainHash()
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_events (
+			id, user_id, actor_id, session_id, event_type, outcome, reason,
+			ip, user_agent, request_id, metadata, created_at,
+			seq, prev_hash, hash
+		) VALUES (
+			$1, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, NULLIF($4, '')::uuid, $5, $6, NULLIF($7, ''),
+			NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11, $12,
+			$13, $14, $15
+		)
+	`
+	_, err = tx.ExecContext(ctx, query,
+		event.ID,
+		event.UserID,
+		event.ActorID,
+		event.SessionID,
+		event.Type,
+		event.Outcome,
+		event.Reason,
+		event.IP,
+		event.UserAgent,
+		event.RequestID,
+		metadata,
+		event.CreatedAt,
+		event.Seq,
+		event.PrevHash,
+		event.Hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ChainHead последнее событие цепочки; для пустой цепочки — 0 и AuditGenesisHash
+func (r *AuditRepository) ChainHead(ctx context.Context) (int64, string, error) {
+	return chainHead(ctx, r.db)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func chainHead(ctx context.Context, q queryRower) (int64, string, error) {
+	var seq int64
+	var hash string
+	err := q.QueryRowContext(ctx, `
+		SELECT seq, hash FROM audit_events
+		WHERE seq IS NOT NULL
+		ORDER BY seq DESC
+		LIMIT 1
+	`).Scan(&seq, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, model.AuditGenesisHash, nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return seq, hash, nil
+}
+
+// ListChain события цепочки с seq > afterSeq по возрастанию
+func (r *AuditRepository) ListChain(ctx context.Context, afterSeq int64, limit int) ([]*model.AuditEvent, error) {
+	query := `SELECT ` + auditColumns + ` FROM audit_events WHERE seq > $1 ORDER BY seq LIMIT $2`
+	rows, err := r.db.QueryContext(ctx, query, afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*model.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// CreateCheckpoint сохраняет подписанную отметку; повтор для того же seq игнорируется
+func (r *AuditRepository) CreateCheckpoint(ctx context.Context, checkpoint *model.AuditCheckpoint) error {
+	query := `
+		INSERT INTO audit_checkpoints (seq, hash, signature, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (seq) DO NOTHING
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		checkpoint.Seq,
+		checkpoint.Hash,
+		checkpoint.Signature,
+		checkpoint.CreatedAt,
+	)
+	return err
+}
+
+// ListCheckpoints все отметки по возрастанию seq
+func (r *AuditRepository) ListCheckpoints(ctx context.Context) ([]*model.AuditCheckpoint, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT seq, hash, signature, created_at FROM audit_checkpoints ORDER BY seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []*model.AuditCheckpoint
+	for rows.Next() {
+		var checkpoint model.AuditCheckpoint
+		if err := rows.Scan(&checkpoint.Seq, &checkpoint.Hash, &checkpoint.Signature, &checkpoint.CreatedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, &checkpoint)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return checkpoints, nil
+}
+
+// auditColumns поля события в порядке scanAuditEvent
+const auditColumns = `id, COALESCE(user_id::text, ''), COALESCE(actor_id::text, ''), COALESCE(session_id::text, ''),
+	event_type, outcome, COALESCE(reason, ''), COALESCE(ip, ''), COALESCE(user_agent, ''),
+	COALESCE(request_id, ''), metadata, created_at,
+	COALESCE(seq, 0), COALESCE(prev_hash, ''), COALESCE(hash, '')`
+
+func (r *AuditRepository) ListUserEvents(ctx context.Context, userID string) ([]*model.AuditEvent, error) {
+	query := `SELECT ` + auditColumns + ` FROM audit_events WHERE user_id = $1 ORDER BY created_at`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*model.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// QueryEvents возвращает страницу журнала по фильтру и общее число подходящих событий
+func (r *AuditRepository) QueryEvents(ctx context.Context, filter model.AuditQuery) ([]*model.AuditEvent, int, error) {
+	query := `
+		SELECT ` + auditColumns + `, COUNT(*) OVER()
+		FROM audit_events
+		WHERE ($1 = '' OR user_id = NULLIF($1, '')::uuid)
+		  AND ($2 = '' OR actor_id = NULLIF($2, '')::uuid)
+		  AND ($3 = '' OR event_type = $3)
+		  AND ($4 = '' OR outcome = $4)
+		  AND ($5 = '' OR ip = $5)
+		  AND ($6::timestamp IS NULL OR created_at >= $6)
+		  AND ($7::timestamp IS NULL OR created_at < $7)
+		  AND NOT (event_type = ANY(COALESCE($8::text[], '{}')))
+		ORDER BY created_at DESC, id
+		LIMIT $9 OFFSET $10
+	`
+	rows, err := r.db.QueryContext(ctx, query,
+		filter.UserID,
+		filter.ActorID,
+		filter.Type,
+		filter.Outcome,
+		filter.IP,
+		nullTime(filter.From),
+		nullTime(filter.To),
+		pq.Array(filter.ExcludeTypes),
+		filter.Limit,
+		filter.Offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []*model.AuditEvent{}
+	total := 0
+	for rows.Next() {
+		event, err := scanAuditEvent(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+// scanAuditEvent читает строку с колонками auditColumns; extra — дополнительные колонки после них
+func scanAuditEvent(row rowScanner, extra ...interface{}) (*model.AuditEvent, error) {
+	var event model.AuditEvent
+	var metadata []byte
+	dest := []interface{}{
+		&event.ID,
+		&event.UserID,
+		&event.ActorID,
+		&event.SessionID,
+		&event.Type,
+		&event.Outcome,
+		&event.Reason,
+		&event.IP,
+		&event.UserAgent,
+		&event.RequestID,
+		&metadata,
+		&event.CreatedAt,
+		&event.Seq,
+		&event.PrevHash,
+		&event.Hash,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
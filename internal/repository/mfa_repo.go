@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"authorization_authentication/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MFARepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// GetUserMFA возвращает nil, если пользователь никогда не настраивал второй фактор
+func (r *MFARepository) GetUserMFA(ctx context.Context, userID string) (*model.UserMFA, error) {
+	query := `
+		SELECT user_id, totp_secret, totp_confirmed_at, totp_last_step, sms_enabled, created_at, updated_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+	row := r.db.QueryRowContext(ctx, query, userID)
+
+	var mfa model.UserMFA
+	var confirmedAt sql.NullTime
+	err := row.Scan(
+		&mfa.UserID,
+		&mfa.TOTPSecret,
+		&confirmedAt,
+		&mfa.TOTPLastStep,
+		&mfa.SMSEnabled,
+		&mfa.CreatedAt,
+		&mfa.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	mfa.TOTPConfirmedAt = timePtr(confirmedAt)
+	return &mfa, nil
+}
+
+// SaveTOTPSecret сохраняет неподтвержденный секрет. Подтвержденный TOTP не перезаписывается.
+func (r *MFARepository) SaveTOTPSecret(ctx context.Context, userID string, encryptedSecret []byte) error {
+	query := `
+		INSERT INTO user_mfa (user_id, totp_secret, totp_confirmed_at, totp_last_step, created_at, updated_at)
+		VALUES ($1, $2, NULL, 0, NOW(), NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET totp_secret = EXCLUDED.totp_secret, totp_last_step = 0, updated_at = NOW()
+		WHERE user_mfa.totp_confirmed_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, encryptedSecret)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+// ConfirmTOTP включает TOTP и заменяет коды восстановления одной транзакцией
+func (r *MFARepository) ConfirmTOTP(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE user_mfa
+		SET totp_confirmed_at = NOW(), totp_last_step = $2, updated_at = NOW()
+		WHERE user_id = $1 AND totp_confirmed_at IS NULL
+	`
+	result, err := tx.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return model.ErrMFAAlreadyEnabled
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AdvanceTOTPStep атомарно сдвигает последний принятый шаг.
+// Возвращает false, если код с этим шагом уже использовался.
+func (r *MFARepository) AdvanceTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	query := `
+		UPDATE user_mfa
+		SET totp_last_step = $2, updated_at = NOW()
+		WHERE user_id = $1 AND totp_last_step < $2
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// DisableTOTP удаляет настройки TOTP вместе с кодами восстановления. SMS-фактор не трогает.
+func (r *MFARepository) DisableTOTP(ctx context.Context, userID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE user_mfa
+		SET totp_secret = NULL, totp_confirmed_at = NULL, totp_last_step = 0, updated_at = NOW()
+		WHERE user_id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *MFARepository) SetSMSEnabled(ctx context.Context, userID string, enabled bool) error {
+	query := `
+		INSERT INTO user_mfa (user_id, sms_enabled, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET sms_enabled = EXCLUDED.sms_enabled, updated_at = NOW()
+	`
+	_, err := r.db.ExecContext(ctx, query, userID, enabled)
+	return err
+}
+
+// UseRecoveryCode помечает код использованным. Возвращает false, если такого неиспользованного кода нет.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *MFARepository) CountUnusedRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+	var count int
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, $4)`
+	now := time.Now().UTC()
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, query, uuid.NewString(), userID, hash, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"authorization_authentication/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type OAuthClientRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthClientRepository(db *sql.DB) *OAuthClientRepository {
+	return &OAuthClientRepository{db: db}
+}
+
+// CreateClient сохраняет клиента и, если передан, его первый секрет в одной транзакции
+func (r *OAuthClientRepository) CreateClient(ctx context.Context, client *model.OAuthClient, secret *model.OAuthClientSecret) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO oauth_clients 
+		(id, name, is_confidential, is_service_account, redirect_uris, grant_types, scopes, created_at, updated_at) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err = tx.ExecContext(ctx, query,
+		client.ID, client.Name, client.Confidential, client.ServiceAccount,
+		pq.Array(client.RedirectURIs), pq.Array(client.GrantTypes), pq.Array(client.Scopes),
+		client.CreatedAt, client.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if secret != nil {
+		if err := insertClientSecret(ctx, tx, secret); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *OAuthClientRepository) GetClientByID(ctx context.Context, clientID string) (*model.OAuthClient, error) {
+	query := `
+		SELECT id, name, is_confidential, is_service_account, redirect_uris, 
+		       grant_types, scopes, created_at, updated_at
+		FROM oauth_clients
+		WHERE id = $1
+	`
+	row := r.db.QueryRowContext(ctx, query, clientID)
+
+	client := &model.OAuthClient{}
+	err := row.Scan(
+		&client.ID,
+		&client.Name,
+		&client.Confidential,
+		&client.ServiceAccount,
+		pq.Array(&client.RedirectURIs),
+		pq.Array(&client.GrantTypes),
+		pq.Array(&client.Scopes),
+		&client.CreatedAt,
+		&client.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrClientNotFound
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// GetActiveSecrets возвращает секреты клиента, которые еще действуют на момент now
+func (r *OAuthClientRepository) GetActiveSecrets(ctx context.Context, clientID string, now time.Time) ([]*model.OAuthClientSecret, error) {
+	query := `
+		SELECT id, client_id, secret_hash, created_at, expires_at
+		FROM oauth_client_secrets
+		WHERE client_id = $1 AND (expires_at IS NULL OR expires_at > $2)
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, clientID, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []*model.OAuthClientSecret
+	for rows.Next() {
+		var secret model.OAuthClientSecret
+		var expiresAt sql.NullTime
+		if err := rows.Scan(
+			&secret.ID,
+			&secret.ClientID,
+			&secret.SecretHash,
+			&secret.CreatedAt,
+			&expiresAt,
+		); err != nil {
+			return nil, err
+		}
+		secret.ExpiresAt = timePtr(expiresAt)
+		secrets = append(secrets, &secret)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+// RotateSecret добавляет новый секрет, а действующие сейчас секреты ограничивает сроком oldExpiresAt.
+// Уже истекающие раньше этого срока секреты не продлеваются.
+func (r *OAuthClientRepository) RotateSecret(ctx context.Context, secret *model.OAuthClientSecret, oldExpiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE oauth_client_secrets
+		SET expires_at = $2
+		WHERE client_id = $1 AND (expires_at IS NULL OR expires_at > $2)
+	`
+	if _, err := tx.ExecContext(ctx, query, secret.ClientID, oldExpiresAt.UTC()); err != nil {
+		return err
+	}
+
+	if err := insertClientSecret(ctx, tx, secret); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *OAuthClientRepository) DeleteExpiredSecrets(ctx context.Context, now time.Time) (int64, error) {
+	query := `DELETE FROM oauth_client_secrets WHERE expires_at < $1`
+	result, err := r.db.ExecContext(ctx, query, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func insertClientSecret(ctx context.Context, tx *sql.Tx, secret *model.OAuthClientSecret) error {
+	query := `INSERT INTO oauth_client_secrets 
+		(id, client_id, secret_hash, created_at, expires_at) 
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := tx.ExecContext(ctx, query,
+		secret.ID, secret.ClientID, secret.SecretHash, secret.CreatedAt.UTC(), nullTime(secret.ExpiresAt))
+	return err
+}
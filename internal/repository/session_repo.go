@@ -20,8 +20,9 @@ func (r *SessionRepository) CreateSession(ctx context.Context, session *model.Se
 	query := `
 		INSERT INTO sessions (
 			id, user_id, refresh_token, expires_at, 
-			user_agent, ip, is_revoked, created_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			user_agent, ip, is_revoked, created_at,
+			client_id, scope
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''))
 		ON CONFLICT (refresh_token) DO NOTHING
 	`
 	_, err := r.db.ExecContext(ctx, query,
@@ -33,6 +34,8 @@ func (r *SessionRepository) CreateSession(ctx context.Context, session *model.Se
 		session.IP,
 		session.IsRevoked,
 		session.CreatedAt.UTC(),
+		session.ClientID,
+		session.Scope,
 	)
 	if err != nil {
 		return err
@@ -43,7 +46,8 @@ func (r *SessionRepository) CreateSession(ctx context.Context, session *model.Se
 func (r *SessionRepository) GetSessionByToken(ctx context.Context, refreshTokenHash string) (*model.Session, error) {
 	query := `
 		SELECT id, user_id, refresh_token, expires_at, 
-		       created_at, user_agent, ip, is_revoked
+		       created_at, user_agent, ip, is_revoked,
+		       COALESCE(client_id, ''), COALESCE(scope, '')
 		FROM sessions
 		WHERE refresh_token = $1 AND is_revoked = false
 		LIMIT 1
@@ -60,6 +64,8 @@ func (r *SessionRepository) GetSessionByToken(ctx context.Context, refreshTokenH
 		&session.UserAgent,
 		&session.IP,
 		&session.IsRevoked,
+		&session.ClientID,
+		&session.Scope,
 	)
 
 	if err != nil {
@@ -116,7 +122,8 @@ func (r *SessionRepository) DeleteExpiredSessions(ctx context.Context) (int64, e
 func (r *SessionRepository) GetUserActiveSessions(ctx context.Context, userID string) ([]*model.Session, error) {
 	query := `
 		SELECT id, user_id, refresh_token, expires_at, 
-		       created_at, user_agent, ip, is_revoked
+		       created_at, user_agent, ip, is_revoked,
+		       COALESCE(client_id, ''), COALESCE(scope, '')
 		FROM sessions
 		WHERE user_id = $1 AND is_revoked = false AND expires_at > NOW()
 		ORDER BY created_at DESC
@@ -139,6 +146,8 @@ func (r *SessionRepository) GetUserActiveSessions(ctx context.Context, userID st
 			&session.UserAgent,
 			&session.IP,
 			&session.IsRevoked,
+			&session.ClientID,
+			&session.Scope,
 		)
 		if err != nil {
 			return nil, err
@@ -152,3 +161,60 @@ func (r *SessionRepository) GetUserActiveSessions(ctx context.Context, userID st
 
 	return sessions, nil
 }
+
+// ListUserSessions все сессии пользователя, включая отозванные и истекшие (для выгрузки данных)
+func (r *SessionRepository) ListUserSessions(ctx context.Context, userID string) ([]*model.Session, error) {
+	query := `
+		SELECT id, user_id, expires_at, created_at, COALESCE(user_agent, ''), COALESCE(ip, ''), is_revoked,
+		       COALESCE(client_id, ''), COALESCE(scope, '')
+		FROM sessions
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*model.Session
+	for rows.Next() {
+		var session model.Session
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.ExpiresAt,
+			&session.CreatedAt,
+			&session.UserAgent,
+			&session.IP,
+			&session.IsRevoked,
+			&session.ClientID,
+			&session.Scope,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// CheckKnownDevice сообщает, были ли у пользователя сессии с той же парой User-Agent и IP,
+// и были ли сессии вообще (при первом входе устройство сравнивать не с чем)
+func (r *SessionRepository) CheckKnownDevice(ctx context.Context, userID, userAgent, ip string) (bool, bool, error) {
+	query := `
+		SELECT COALESCE(bool_or(user_agent = $2 AND ip = $3), false), COUNT(*) > 0
+		FROM sessions
+		WHERE user_id = $1
+	`
+	var known, hasSessions bool
+	if err := r.db.QueryRowContext(ctx, query, userID, userAgent, ip).Scan(&known, &hasSessions); err != nil {
+		return false, false, err
+	}
+	return known, hasSessions, nil
+}
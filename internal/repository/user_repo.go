@@ -5,6 +5,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type UserRepository struct {
@@ -17,27 +21,63 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 
 func (r *UserRepository) CreateUser(ctx context.Context, user *model.User) error {
 	query := `INSERT INTO users 
-		(id, email, phone, verified, password_hash, created_at, updated_at) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		(id, email, phone, phone_country, email_verified, phone_verified, password_hash, created_at, updated_at) 
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9)`
 
 	_, err := r.db.ExecContext(ctx, query,
-		user.ID, user.Email, user.Phone, user.Verified,
+		user.ID, user.Email, user.Phone, user.PhoneCountry, user.EmailVerified, user.PhoneVerified,
 		user.Password, user.CreatedAt, user.UpdatedAt)
 	return err
 }
 
-func (r *UserRepository) UpdateVerificationStatus(ctx context.Context, phone string, verified bool) error {
-	query := `UPDATE users SET verified = $1, updated_at = NOW() WHERE phone = $2`
-	_, err := r.db.ExecContext(ctx, query, verified, phone)
-	return err
+// MarkPhoneVerified подтверждает телефон конкретного пользователя, если номер с тех пор не менялся.
+// Если номер уже подтвержден другим аккаунтом, возвращает ErrPhoneAlreadyInUse.
+func (r *UserRepository) MarkPhoneVerified(ctx context.Context, userID, phone string) error {
+	query := `
+		UPDATE users SET phone_verified = TRUE, updated_at = NOW()
+		WHERE id = $1 AND phone = $2 AND NOT phone_verified
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, phone)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return model.ErrPhoneAlreadyInUse
+		}
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrUserNotFound
+	}
+	return nil
 }
 
-func (r *UserRepository) GetUserByPhone(ctx context.Context, phone string) (*model.User, error) {
-	query := `SELECT id, email, phone, verified FROM users WHERE phone = $1`
+// MarkEmailVerified подтверждает email, только если он не менялся с момента отправки письма.
+// Возвращает false, если адрес у пользователя уже другой.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, userID, email string) (bool, error) {
+	query := `UPDATE users SET email_verified = TRUE, updated_at = NOW() WHERE id = $1 AND email = $2`
+	result, err := r.db.ExecContext(ctx, query, userID, email)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetUserByVerifiedPhone ищет аккаунт, за которым номер закреплен подтверждением
+func (r *UserRepository) GetUserByVerifiedPhone(ctx context.Context, phone string) (*model.User, error) {
+	query := `SELECT id, email, phone, email_verified, phone_verified FROM users WHERE phone = $1 AND phone_verified`
 	row := r.db.QueryRowContext(ctx, query, phone)
 
 	user := &model.User{}
-	err := row.Scan(&user.ID, &user.Email, &user.Phone, &user.Verified)
+	err := row.Scan(&user.ID, &user.Email, &user.Phone, &user.EmailVerified, &user.PhoneVerified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -48,16 +88,393 @@ func (r *UserRepository) GetUserByPhone(ctx context.Context, phone string) (*mod
 }
 
 func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
-	query := `SELECT id, email, password_hash FROM users WHERE email = $1`
+	// Адрес приходит уже нормализованным; lower() здесь, чтобы запрос шел по уникальному индексу
+	query := `SELECT id, email, password_hash, deleted_at, status, COALESCE(status_reason, ''), suspended_until,
+		password_reset_required
+		FROM users WHERE lower(email) = lower($1)`
 	row := r.db.QueryRowContext(ctx, query, email)
 
 	user := &model.User{}
-	err := row.Scan(&user.ID, &user.Email, &user.Password)
+	var deletedAt, suspendedUntil sql.NullTime
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.Password,
+		&deletedAt,
+		&user.Status,
+		&user.StatusReason,
+		&suspendedUntil,
+		&user.PasswordResetRequired,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	user.DeletedAt = timePtr(deletedAt)
+	user.SuspendedUntil = timePtr(suspendedUntil)
+	return user, nil
+}
+
+// GetAccountStatus читает только поля, от которых зависит, можно ли пользоваться аккаунтом.
+// Вызывается на каждый аутентифицированный запрос.
+func (r *UserRepository) GetAccountStatus(ctx context.Context, userID string) (*model.User, error) {
+	query := `SELECT id, status, COALESCE(status_reason, ''), suspended_until, password_reset_required
+		FROM users WHERE id = $1`
+	row := r.db.QueryRowContext(ctx, query, userID)
+
+	user := &model.User{}
+	var suspendedUntil sql.NullTime
+	err := row.Scan(&user.ID, &user.Status, &user.StatusReason, &suspendedUntil, &user.PasswordResetRequired)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
+	user.SuspendedUntil = timePtr(suspendedUntil)
 	return user, nil
 }
+
+// userColumns полный набор полей пользователя в порядке scanUser
+const userColumns = `id, email, COALESCE(phone, ''), COALESCE(phone_country, ''), email_verified, phone_verified,
+	COALESCE(pending_email, ''), COALESCE(pending_phone, ''), display_name, locale, timezone, avatar_url,
+	password_hash, created_at, updated_at, deleted_at, role, status, COALESCE(status_reason, ''), suspended_until,
+	password_reset_required`
+
+func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// SearchUsers ищет пользователей по началу email и/или телефона. Возвращает страницу и общее число совпадений.
+func (r *UserRepository) SearchUsers(ctx context.Context, filter model.UserSearch) ([]*model.User, int, error) {
+	query := `
+		SELECT ` + userColumns + `, COUNT(*) OVER()
+		FROM users
+		WHERE ($1 = '' OR lower(email) LIKE $1 || '%')
+		  AND ($2 = '' OR phone LIKE $2 || '%')
+		ORDER BY created_at DESC, id
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := r.db.QueryContext(ctx, query,
+		escapeLike(strings.ToLower(filter.Email)),
+		escapeLike(filter.Phone),
+		filter.Limit,
+		filter.Offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []*model.User{}
+	total := 0
+	for rows.Next() {
+		user, err := scanUser(rows, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// scanUser читает строку с колонками userColumns; extra — дополнительные колонки после них
+func scanUser(row rowScanner, extra ...interface{}) (*model.User, error) {
+	user := &model.User{}
+	var deletedAt, suspendedUntil sql.NullTime
+	dest := []interface{}{
+		&user.ID,
+		&user.Email,
+		&user.Phone,
+		&user.PhoneCountry,
+		&user.EmailVerified,
+		&user.PhoneVerified,
+		&user.PendingEmail,
+		&user.PendingPhone,
+		&user.DisplayName,
+		&user.Locale,
+		&user.Timezone,
+		&user.AvatarURL,
+		&user.Password,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&deletedAt,
+		&user.Role,
+		&user.Status,
+		&user.StatusReason,
+		&suspendedUntil,
+		&user.PasswordResetRequired,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	user.DeletedAt = timePtr(deletedAt)
+	user.SuspendedUntil = timePtr(suspendedUntil)
+	return user, nil
+}
+
+func (r *UserRepository) SetPendingEmail(ctx context.Context, userID, email string) error {
+	query := `UPDATE users SET pending_email = $2, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID, email)
+	return err
+}
+
+func (r *UserRepository) SetPendingPhone(ctx context.Context, userID, phone string) error {
+	query := `UPDATE users SET pending_phone = $2, updated_at = NOW() WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, userID, phone)
+	return err
+}
+
+// ConfirmEmailChange делает ожидающий адрес основным, если он все еще совпадает с email.
+// Возвращает прежний адрес.
+func (r *UserRepository) ConfirmEmailChange(ctx context.Context, userID, email string) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var oldEmail string
+	err = tx.QueryRowContext(ctx,
+		`SELECT email FROM users WHERE id = $1 AND pending_email = $2 FOR UPDATE`,
+		userID, email,
+	).Scan(&oldEmail)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", model.ErrNoPendingChange
+		}
+		return "", err
+	}
+
+	query := `
+		UPDATE users
+		SET email = pending_email, pending_email = NULL, email_verified = TRUE, updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		if isUniqueViolation(err) {
+			return "", model.ErrUserAlreadyExists
+		}
+		return "", err
+	}
+
+	return oldEmail, tx.Commit()
+}
+
+// ConfirmPhoneChange делает ожидающий телефон основным и подтвержденным. Возвращает прежний номер.
+func (r *UserRepository) ConfirmPhoneChange(ctx context.Context, userID, phone, country string) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var oldPhone string
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(phone, '') FROM users WHERE id = $1 AND pending_phone = $2 FOR UPDATE`,
+		userID, phone,
+	).Scan(&oldPhone)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", model.ErrNoPendingChange
+		}
+		return "", err
+	}
+
+	query := `
+		UPDATE users
+		SET phone = pending_phone, phone_country = NULLIF($2, ''), pending_phone = NULL,
+		    phone_verified = TRUE, updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, userID, country); err != nil {
+		if isUniqueViolation(err) {
+			return "", model.ErrPhoneAlreadyInUse
+		}
+		return "", err
+	}
+
+	return oldPhone, tx.Commit()
+}
+
+// UpdateProfile сохраняет профиль, только если запись не менялась с момента expectedUpdatedAt.
+// Иначе возвращает ErrProfileModified. Возвращает новое значение updated_at.
+func (r *UserRepository) UpdateProfile(ctx context.Context, user *model.User, expectedUpdatedAt time.Time) (time.Time, error) {
+	query := `
+		UPDATE users
+		SET display_name = $3, locale = $4, timezone = $5, avatar_url = $6, updated_at = NOW()
+		WHERE id = $1 AND updated_at = $2
+		RETURNING updated_at
+	`
+	var updatedAt time.Time
+	err := r.db.QueryRowContext(ctx, query,
+		user.ID,
+		expectedUpdatedAt,
+		user.DisplayName,
+		user.Locale,
+		user.Timezone,
+		user.AvatarURL,
+	).Scan(&updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, model.ErrProfileModified
+		}
+		return time.Time{}, err
+	}
+	return updatedAt, nil
+}
+
+// MarkDeleted помечает аккаунт удаленным. Повторная пометка не сдвигает дату.
+func (r *UserRepository) MarkDeleted(ctx context.Context, userID string) error {
+	query := `
+		UPDATE users
+		SET status = 'pending_deletion', suspended_until = NULL, deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status <> 'pending_deletion'
+	`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// Restore снимает пометку об удалении, если аккаунт еще не удален окончательно
+func (r *UserRepository) Restore(ctx context.Context, userID string) error {
+	query := `
+		UPDATE users
+		SET status = 'active', status_reason = NULL, deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'pending_deletion'
+	`
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrAccountNotDeleted
+	}
+	return nil
+}
+
+// PurgeDeletedUsers окончательно удаляет аккаунты, помеченные раньше before.
+// Сессии, ключи и настройки второго фактора удаляются каскадом.
+func (r *UserRepository) PurgeDeletedUsers(ctx context.Context, before time.Time) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `DELETE FROM users WHERE status = 'pending_deletion' AND deleted_at < $1 RETURNING id`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// ForceVerify отмечает email и/или телефон подтвержденными без проверки кода.
+// Телефон отмечается, только если он указан; занятый другим аккаунтом номер дает ErrPhoneAlreadyInUse.
+func (r *UserRepository) ForceVerify(ctx context.Context, userID string, email, phone bool) error {
+	query := `
+		UPDATE users
+		SET email_verified = email_verified OR $2,
+		    phone_verified = phone_verified OR ($3 AND phone IS NOT NULL),
+		    updated_at = NOW()
+		WHERE id = $1
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, email, phone)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return model.ErrPhoneAlreadyInUse
+		}
+		return err
+	}
+	return requireAffected(result)
+}
+
+// SetStatus меняет состояние аккаунта. pending_deletion ставится только через MarkDeleted,
+// поэтому любое другое состояние снимает и пометку об удалении.
+func (r *UserRepository) SetStatus(ctx context.Context, userID, status, reason string, suspendedUntil *time.Time) error {
+	query := `
+		UPDATE users
+		SET status = $2, status_reason = NULLIF($3, ''), suspended_until = $4, deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, status, reason, nullTime(suspendedUntil))
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// RequirePasswordReset запрещает вход по текущему паролю до его смены
+func (r *UserRepository) RequirePasswordReset(ctx context.Context, userID string) error {
+	query := `UPDATE users SET password_reset_required = TRUE, updated_at = NOW() WHERE id = $1`
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// UpdatePassword сохраняет новый хеш пароля и снимает требование смены пароля
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	query := `
+		UPDATE users SET password_hash = $2, password_reset_required = FALSE, updated_at = NOW()
+		WHERE id = $1
+	`
+	result, err := r.db.ExecContext(ctx, query, userID, passwordHash)
+	if err != nil {
+		return err
+	}
+	return requireAffected(result)
+}
+
+// requireAffected возвращает ErrUserNotFound, если запрос не затронул ни одной строки
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrUserNotFound
+	}
+	return nil
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE во вводе пользователя
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// isUniqueViolation сообщает, что запрос нарушил уникальный индекс
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
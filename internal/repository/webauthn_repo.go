@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"authorization_authentication/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type WebAuthnRepository struct {
+	db *sql.DB
+}
+
+func NewWebAuthnRepository(db *sql.DB) *WebAuthnRepository {
+	return &WebAuthnRepository{db: db}
+}
+
+// CreateCredential сохраняет новый ключ. Один credential_id не может принадлежать двум пользователям.
+func (r *WebAuthnRepository) CreateCredential(ctx context.Context, cred *model.WebAuthnCredential) error {
+	query := `
+		INSERT INTO webauthn_credentials (id, user_id, credential_id, public_key, attestation_type, aaguid,
+		                                  sign_count, clone_warning, transports, flags, name, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (credential_id) DO NOTHING
+	`
+	result, err := r.db.ExecContext(ctx, query,
+		cred.ID,
+		cred.UserID,
+		cred.CredentialID,
+		cred.PublicKey,
+		cred.AttestationType,
+		cred.AAGUID,
+		int64(cred.SignCount),
+		cred.CloneWarning,
+		pq.Array(cred.Transports),
+		int16(cred.Flags),
+		cred.Name,
+		cred.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrCredentialAlreadyExists
+	}
+	return nil
+}
+
+// GetCredentialByCredentialID ищет ключ по rawId. Возвращает nil, если ключ не найден.
+func (r *WebAuthnRepository) GetCredentialByCredentialID(ctx context.Context, credentialID []byte) (*model.WebAuthnCredential, error) {
+	query := `
+		SELECT id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count,
+		       clone_warning, transports, flags, name, created_at, last_used_at
+		FROM webauthn_credentials
+		WHERE credential_id = $1
+	`
+	cred, err := scanWebAuthnCredential(r.db.QueryRowContext(ctx, query, credentialID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cred, nil
+}
+
+func (r *WebAuthnRepository) ListUserCredentials(ctx context.Context, userID string) ([]*model.WebAuthnCredential, error) {
+	query := `
+		SELECT id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count,
+		       clone_warning, transports, flags, name, created_at, last_used_at
+		FROM webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var creds []*model.WebAuthnCredential
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, cred)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// UpdateCredentialUsage сохраняет новый счетчик подписей и флаги после успешного входа
+func (r *WebAuthnRepository) UpdateCredentialUsage(ctx context.Context, cred *model.WebAuthnCredential) error {
+	query := `
+		UPDATE webauthn_credentials
+		SET sign_count = $2, clone_warning = $3, flags = $4, last_used_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, cred.ID, int64(cred.SignCount), cred.CloneWarning, int16(cred.Flags))
+	return err
+}
+
+func (r *WebAuthnRepository) DeleteCredential(ctx context.Context, userID, id string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrCredentialNotFound
+	}
+	return nil
+}
+
+func scanWebAuthnCredential(row rowScanner) (*model.WebAuthnCredential, error) {
+	var cred model.WebAuthnCredential
+	var signCount int64
+	var flags int16
+	var lastUsedAt sql.NullTime
+	err := row.Scan(
+		&cred.ID,
+		&cred.UserID,
+		&cred.CredentialID,
+		&cred.PublicKey,
+		&cred.AttestationType,
+		&cred.AAGUID,
+		&signCount,
+		&cred.CloneWarning,
+		pq.Array(&cred.Transports),
+		&flags,
+		&cred.Name,
+		&cred.CreatedAt,
+		&lastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cred.SignCount = uint32(signCount)
+	cred.Flags = uint8(flags)
+	cred.LastUsedAt = timePtr(lastUsedAt)
+	return &cred, nil
+}
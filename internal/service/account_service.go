@@ -0,0 +1,315 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AccountService изменение контактов пользователя. Новый адрес или телефон сначала
+// сохраняется как ожидающий и становится основным только после подтверждения.
+type AccountService struct {
+	userRepo     repository.UserRepository
+	auditService *AuditService
+	mailer       Mailer
+	smsSender    SMSSender
+	verifier     VerificationProvider
+	redisClient  *redis.Client
+	phones       *util.PhoneNormalizer
+	blocklist    *util.DomainBlocklist
+	baseURL      string
+}
+
+func NewAccountService(
+	userRepo repository.UserRepository,
+	auditService *AuditService,
+	mailer Mailer,
+	smsSender SMSSender,
+	verifier VerificationProvider,
+	redisClient *redis.Client,
+	phones *util.PhoneNormalizer,
+	blocklist *util.DomainBlocklist,
+	baseURL string,
+) *AccountService {
+	return &AccountService{
+		userRepo:     userRepo,
+		auditService: auditService,
+		mailer:       mailer,
+		smsSender:    smsSender,
+		verifier:     verifier,
+		redisClient:  redisClient,
+		phones:       phones,
+		blocklist:    blocklist,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+	}
+}
+
+const (
+	emailChangeTTL           = 24 * time.Hour
+	contactChangeWindow      = time.Hour
+	maxContactChangeRequests = 3 // Запросов на смену одного вида контакта за contactChangeWindow
+	emailChangeConfirmPath   = "/account/email/confirm"
+)
+
+// emailChangeState хранится под хешем токена из письма на новый адрес
+type emailChangeState struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// RequestEmailChange отправляет ссылку подтверждения на новый адрес и уведомление на текущий
+func (s *AccountService) RequestEmailChange(ctx context.Context, userID, password, newEmail, userAgent, ip string) error {
+	newEmail, err := util.NormalizeEmail(newEmail)
+	if err != nil {
+		return err
+	}
+	if err := s.blocklist.Check(newEmail); err != nil {
+		return err
+	}
+
+	user, err := s.authorizeChange(ctx, userID, password, "email_change_requests:")
+	if err != nil {
+		return err
+	}
+	if newEmail == user.Email {
+		return model.ErrSameContact
+	}
+
+	existing, err := s.userRepo.GetUserByEmail(ctx, newEmail)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return model.ErrUserAlreadyExists
+	}
+
+	if err := s.userRepo.SetPendingEmail(ctx, userID, newEmail); err != nil {
+		return err
+	}
+
+	token, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(emailChangeState{UserID: userID, Email: newEmail})
+	if err != nil {
+		return err
+	}
+
+	if err := s.redisClient.Set(ctx, emailChangeKey(token), data, emailChangeTTL).Err(); err != nil {
+		return err
+	}
+
+	link := s.baseURL + emailChangeConfirmPath + "?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(ctx, EmailMessage{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: "Open the link below to make this address the email for your account.\n" +
+			"The link is valid for 24 hours.\n\n" +
+			link + "\n",
+	})
+	if err != nil {
+		return err
+	}
+
+	s.notifyEmail(ctx, user.Email, "Your email address is being changed",
+		"Someone requested to change the email address on your account to "+newEmail+".\n"+
+			"The change takes effect only after the new address is confirmed.\n"+
+			"If this was not you, change your password right away.\n")
+
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    userID,
+		ActorID:   userID,
+		Type:      model.AuditEmailChangeRequested,
+		IP:        ip,
+		UserAgent: userAgent,
+		Metadata:  map[string]string{"new_email": newEmail},
+	})
+
+	return nil
+}
+
+// ConfirmEmailChange меняет адрес по токену из письма. Токен одноразовый.
+func (s *AccountService) ConfirmEmailChange(ctx context.Context, token, userAgent, ip string) error {
+	if token == "" {
+		return model.ErrEmailVerificationFailed
+	}
+
+	data, err := s.redisClient.GetDel(ctx, emailChangeKey(token)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return model.ErrEmailVerificationFailed
+		}
+		return err
+	}
+
+	var state emailChangeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	oldEmail, err := s.userRepo.ConfirmEmailChange(ctx, state.UserID, state.Email)
+	if err != nil {
+		if err == model.ErrNoPendingChange {
+			// Пользователь успел запросить смену на другой адрес: эта ссылка устарела
+			return model.ErrEmailVerificationFailed
+		}
+		return err
+	}
+
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    state.UserID,
+		ActorID:   state.UserID,
+		Type:      model.AuditEmailChanged,
+		IP:        ip,
+		UserAgent: userAgent,
+		Metadata:  map[string]string{"old_email": oldEmail, "new_email": state.Email},
+	})
+
+	return nil
+}
+
+// RequestPhoneChange отправляет код на новый номер и уведомление на текущий
+func (s *AccountService) RequestPhoneChange(ctx context.Context, userID, password, newPhone, userAgent, ip string) error {
+	parsed, err := s.phones.Parse(newPhone)
+	if err != nil {
+		return err
+	}
+	newPhone = parsed.E164
+
+	user, err := s.authorizeChange(ctx, userID, password, "phone_change_requests:")
+	if err != nil {
+		return err
+	}
+	if newPhone == user.Phone {
+		return model.ErrSameContact
+	}
+
+	existing, err := s.userRepo.GetUserByVerifiedPhone(ctx, newPhone)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != userID {
+		return model.ErrPhoneAlreadyInUse
+	}
+
+	if err := s.userRepo.SetPendingPhone(ctx, userID, newPhone); err != nil {
+		return err
+	}
+
+	if err := s.verifier.SendCode(ctx, newPhone, "sms"); err != nil {
+		return err
+	}
+
+	if user.Phone != "" {
+		if err := s.smsSender.SendSMS(ctx, user.Phone,
+			"A request was made to change the phone number on your account. If this was not you, change your password."); err != nil {
+			logger.Log.Warn("Failed to send phone change notification: ", err)
+		}
+	}
+	s.notifyEmail(ctx, user.Email, "Your phone number is being changed",
+		"Someone requested to change the phone number on your account.\n"+
+			"The change takes effect only after the new number is confirmed.\n"+
+			"If this was not you, change your password right away.\n")
+
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    userID,
+		ActorID:   userID,
+		Type:      model.AuditPhoneChangeRequested,
+		IP:        ip,
+		UserAgent: userAgent,
+		Metadata:  map[string]string{"new_phone": newPhone},
+	})
+
+	return nil
+}
+
+// ConfirmPhoneChange проверяет код с нового номера и делает его основным
+func (s *AccountService) ConfirmPhoneChange(ctx context.Context, userID, code, userAgent, ip string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return model.ErrUserNotFound
+	}
+	if user.PendingPhone == "" {
+		return model.ErrNoPendingChange
+	}
+
+	approved, err := s.verifier.CheckCode(ctx, user.PendingPhone, code)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return model.ErrVerificationFailed
+	}
+
+	// Номер уже проверен при запросе смены, здесь только определяем страну
+	parsed, err := s.phones.Parse(user.PendingPhone)
+	if err != nil {
+		return err
+	}
+
+	oldPhone, err := s.userRepo.ConfirmPhoneChange(ctx, userID, user.PendingPhone, parsed.Country)
+	if err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    userID,
+		ActorID:   userID,
+		Type:      model.AuditPhoneChanged,
+		IP:        ip,
+		UserAgent: userAgent,
+		Metadata:  map[string]string{"old_phone": oldPhone, "new_phone": user.PendingPhone},
+	})
+
+	return nil
+}
+
+// authorizeChange перепроверяет пароль и ограничивает частоту запросов на смену контакта
+func (s *AccountService) authorizeChange(ctx context.Context, userID, password, counterPrefix string) (*model.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, model.ErrUserNotFound
+	}
+
+	if !util.CheckPasswordHash(password, user.Password) {
+		return nil, model.ErrInvalidCredentials
+	}
+
+	requests, err := incrementWithTTL(ctx, s.redisClient, counterPrefix+userID, contactChangeWindow)
+	if err != nil {
+		return nil, err
+	}
+	if requests > maxContactChangeRequests {
+		return nil, model.ErrTooManyCodeRequests
+	}
+
+	return user, nil
+}
+
+// notifyEmail уведомление на текущий адрес; его сбой не отменяет смену
+func (s *AccountService) notifyEmail(ctx context.Context, to, subject, body string) {
+	if err := s.mailer.Send(ctx, EmailMessage{To: to, Subject: subject, Body: body}); err != nil {
+		logger.Log.Warn("Failed to send account notification: ", err)
+	}
+}
+
+func emailChangeKey(token string) string {
+	return "email_change:" + util.HashToken(token)
+}
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AdminService операции администраторов над чужими аккаунтами. Каждое действие, включая
+// просмотр, пишется в журнал аудита с ActorID администратора.
+type AdminService struct {
+	userRepo        repository.UserRepository
+	sessionRepo     repository.SessionRepository
+	passwordService *PasswordService
+	privacyService  *PrivacyService
+	auditService    *AuditService
+	reputation      *IPReputationService
+	phones          *util.PhoneNormalizer
+}
+
+func NewAdminService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	passwordService *PasswordService,
+	privacyService *PrivacyService,
+	auditService *AuditService,
+	reputation *IPReputationService,
+	phones *util.PhoneNormalizer,
+) *AdminService {
+	return &AdminService{
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		passwordService: passwordService,
+		privacyService:  privacyService,
+		auditService:    auditService,
+		reputation:      reputation,
+		phones:          phones,
+	}
+}
+
+// AdminActor администратор, выполняющий действие, и откуда пришел запрос
+type AdminActor struct {
+	UserID    string
+	UserAgent string
+	IP        string
+}
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+// IsAdmin проверяет роль по базе, а не по токену: снятие роли или блокировка действуют сразу
+func (s *AdminService) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user != nil && user.IsAdmin() && checkAccountStatus(user) == nil, nil
+}
+
+func (s *AdminService) SearchUsers(ctx context.Context, actor AdminActor, filter model.UserSearch) (*model.UserPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultUserPageSize
+	}
+	if filter.Limit > maxUserPageSize {
+		filter.Limit = maxUserPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	filter.Email = strings.TrimSpace(filter.Email)
+	filter.Phone = strings.TrimSpace(filter.Phone)
+	// Полный номер приводим к E.164, начало номера ищем как есть
+	if phone, err := s.phones.Parse(filter.Phone); err == nil {
+		filter.Phone = phone.E164
+	}
+
+	users, total, err := s.userRepo.SearchUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, actor, "", model.AuditAdminUsersSearched, map[string]string{
+		"email":  filter.Email,
+		"phone":  filter.Phone,
+		"offset": strconv.Itoa(filter.Offset),
+	})
+
+	return &model.UserPage{
+		Users:  users,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}, nil
+}
+
+// QueryAudit поиск по журналу аудита всех пользователей
+func (s *AdminService) QueryAudit(ctx context.Context, actor AdminActor, filter model.AuditQuery) (*model.AuditPage, error) {
+	verr := &model.ValidationError{}
+	for field, id := range map[string]string{"user_id": filter.UserID, "actor_id": filter.ActorID} {
+		if _, err := uuid.Parse(id); id != "" && err != nil {
+			verr.Add(field, "must be a UUID")
+		}
+	}
+	switch filter.Outcome {
+	case "", model.AuditOutcomeSuccess, model.AuditOutcomeFailure:
+	default:
+		verr.Add("outcome", "must be success or failure")
+	}
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+
+	page, err := s.auditService.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, actor, filter.UserID, model.AuditAdminAuditViewed, map[string]string{
+		"type":    filter.Type,
+		"outcome": filter.Outcome,
+	})
+	return page, nil
+}
+
+func (s *AdminService) GetUser(ctx context.Context, actor AdminActor, userID string) (*model.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, actor, userID, model.AuditAdminUserViewed, nil)
+	return user, nil
+}
+
+// ListSessions все сессии пользователя, без refresh-токенов
+func (s *AdminService) ListSessions(ctx context.Context, actor AdminActor, userID string) ([]model.SessionInfo, error) {
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessionRepo.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]model.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, model.NewSessionInfo(session))
+	}
+
+	s.record(ctx, actor, userID, model.AuditAdminSessionsViewed, nil)
+	return infos, nil
+}
+
+// ForceVerify отмечает email и/или телефон подтвержденными без кода
+func (s *AdminService) ForceVerify(ctx context.Context, actor AdminActor, userID string, req model.AdminVerify) (*model.User, error) {
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.ForceVerify(ctx, userID, req.Email, req.Phone); err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, actor, userID, model.AuditAdminUserVerified, map[string]string{
+		"email": strconv.FormatBool(req.Email),
+		"phone": strconv.FormatBool(req.Phone),
+	})
+	return s.getUser(ctx, userID)
+}
+
+// SetStatus меняет состояние аккаунта. Для приостановки нужен срок в будущем;
+// pending_deletion ставится только удалением. Сессии неактивного аккаунта завершаются.
+func (s *AdminService) SetStatus(ctx context.Context, actor AdminActor, userID string, change model.StatusChange) (*model.User, error) {
+	verr := &model.ValidationError{}
+	switch change.Status {
+	case model.UserStatusActive, model.UserStatusDisabled:
+		change.SuspendedUntil = nil
+	case model.UserStatusSuspended:
+		if change.SuspendedUntil == nil {
+			verr.Add("suspended_until", "required when status is suspended")
+		} else if !change.SuspendedUntil.After(time.Now()) {
+			verr.Add("suspended_until", "must be in the future")
+		}
+	default:
+		verr.Add("status", "must be one of active, suspended, disabled")
+	}
+	if err := verr.OrNil(); err != nil {
+		return nil, err
+	}
+
+	if userID == actor.UserID && change.Status != model.UserStatusActive {
+		return nil, model.ErrAdminSelfAction
+	}
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	change.Reason = strings.TrimSpace(change.Reason)
+	if err := s.userRepo.SetStatus(ctx, userID, change.Status, change.Reason, change.SuspendedUntil); err != nil {
+		return nil, err
+	}
+	if change.Status != model.UserStatusActive {
+		if err := s.sessionRepo.RevokeAllUserSessions(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	metadata := map[string]string{"status": change.Status, "reason": change.Reason}
+	if change.SuspendedUntil != nil {
+		metadata["suspended_until"] = change.SuspendedUntil.UTC().Format(time.RFC3339)
+	}
+	s.record(ctx, actor, userID, model.AuditAdminStatusChanged, metadata)
+
+	return s.getUser(ctx, userID)
+}
+
+// ForcePasswordReset запрещает вход по текущему паролю, завершает сессии и
+// отправляет пользователю ссылку для установки нового пароля
+func (s *AdminService) ForcePasswordReset(ctx context.Context, actor AdminActor, userID string) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.RequirePasswordReset(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.RevokeAllUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	s.record(ctx, actor, userID, model.AuditAdminPasswordResetSent, nil)
+
+	// Флаг уже стоит: при сбое отправки действие можно безопасно повторить
+	return s.passwordService.SendResetLink(ctx, user)
+}
+
+func (s *AdminService) RevokeSessions(ctx context.Context, actor AdminActor, userID string) error {
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.sessionRepo.RevokeAllUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	s.record(ctx, actor, userID, model.AuditAdminSessionsRevoked, nil)
+	return nil
+}
+
+// DeleteUser удаляет аккаунт так же, как DELETE /me: с периодом ожидания перед окончательным удалением
+func (s *AdminService) DeleteUser(ctx context.Context, actor AdminActor, userID string) (time.Time, error) {
+	if userID == actor.UserID {
+		return time.Time{}, model.ErrAdminSelfAction
+	}
+
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return time.Time{}, err
+	}
+
+	return s.privacyService.ScheduleDeletion(ctx, userID, actor.UserID, model.AuditAdminUserDeleted, actor.UserAgent, actor.IP)
+}
+
+// ListIPBlocks сети с неудачными входами и действующие списки разрешенных и запрещенных сетей
+func (s *AdminService) ListIPBlocks(ctx context.Context, actor AdminActor) (*model.IPBlockList, error) {
+	blocks, err := s.reputation.Blocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.record(ctx, actor, "", model.AuditAdminIPBlocksViewed, nil)
+	return &model.IPBlockList{Blocks: blocks, Lists: s.reputation.Lists()}, nil
+}
+
+// ClearIPBlock снимает блокировку сети до истечения ее окна
+func (s *AdminService) ClearIPBlock(ctx context.Context, actor AdminActor, network string) error {
+	network, err := s.reputation.ClearBlock(ctx, network)
+	if err != nil {
+		return err
+	}
+
+	s.record(ctx, actor, "", model.AuditAdminIPBlockCleared, map[string]string{"network": network})
+	return nil
+}
+
+func (s *AdminService) getUser(ctx context.Context, userID string) (*model.User, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, model.ErrUserNotFound
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, model.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *AdminService) record(ctx context.Context, actor AdminActor, userID, eventType string, metadata map[string]string) {
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    userID,
+		ActorID:   actor.UserID,
+		Type:      eventType,
+		IP:        actor.IP,
+		UserAgent: actor.UserAgent,
+		Metadata:  metadata,
+	})
+}
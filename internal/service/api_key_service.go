@@ -0,0 +1,182 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type APIKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+	lastUsed   chan apiKeyUsage
+}
+
+// apiKeyUsage событие использования ключа для асинхронной записи в базу
+type apiKeyUsage struct {
+	keyID  string
+	usedAt time.Time
+	ip     string
+}
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		lastUsed:   make(chan apiKeyUsage, lastUsedQueueSize),
+	}
+}
+
+const (
+	apiKeyPrefixBytes    = 6
+	apiKeySecretBytes    = 32
+	maxAPIKeysPerUser    = 50
+	maxAPIKeyNameLength  = 100
+	lastUsedQueueSize    = 1024
+	lastUsedResolution   = 1 * time.Minute // Чаще этого last_used_at не обновляем
+	lastUsedWriteTimeout = 5 * time.Second
+)
+
+// CreateAPIKey выпускает новый ключ. Полный ключ возвращается один раз, в базе хранится только хеш секрета.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, userID, name string, scopes []string, expiresAt *time.Time) (*model.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAPIKeyNameLength {
+		return nil, "", errors.New("api key name is required and must be at most 100 characters")
+	}
+	// Неизвестный scope не открывает ни одного маршрута: такой ключ был бы бесполезен
+	for _, scope := range scopes {
+		if !model.IsKnownScope(scope) {
+			return nil, "", model.ErrInvalidScope
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", errors.New("expiration must be in the future")
+	}
+
+	// Отозванные и истекшие ключи не удаляются, поэтому в лимит не входят
+	active, err := s.apiKeyRepo.CountActiveUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if active >= maxAPIKeysPerUser {
+		return nil, "", errors.New("api key limit reached, revoke unused keys first")
+	}
+
+	prefixBytes := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return nil, "", err
+	}
+	prefix := hex.EncodeToString(prefixBytes)
+
+	secret, err := util.GenerateRandomToken(apiKeySecretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	key := &model.APIKey{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: util.HashToken(secret),
+		Scopes:     scopes,
+		ExpiresAt:  expiresAt,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	if err := s.apiKeyRepo.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+
+	return key, model.APIKeyPrefix + prefix + "_" + secret, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, userID string) ([]*model.APIKey, error) {
+	return s.apiKeyRepo.ListUserAPIKeys(ctx, userID)
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID, keyID string) error {
+	if _, err := uuid.Parse(keyID); err != nil {
+		return model.ErrAPIKeyNotFound
+	}
+	return s.apiKeyRepo.RevokeAPIKey(ctx, userID, keyID)
+}
+
+// Authenticate проверяет предъявленный ключ и ставит в очередь обновление last_used_at
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey, ip string) (*model.APIKey, error) {
+	prefix, secret, ok := parseAPIKey(rawKey)
+	if !ok {
+		return nil, model.ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeyRepo.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, model.ErrAPIKeyNotFound) {
+			return nil, model.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(util.HashToken(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, model.ErrInvalidAPIKey
+	}
+
+	now := time.Now().UTC()
+	if !key.IsActive(now) {
+		return nil, model.ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution || key.LastUsedIP != ip {
+		s.recordUsage(apiKeyUsage{keyID: key.ID, usedAt: now, ip: ip})
+	}
+
+	return key, nil
+}
+
+// recordUsage не блокирует запрос: если очередь переполнена, отметка теряется
+func (s *APIKeyService) recordUsage(usage apiKeyUsage) {
+	select {
+	case s.lastUsed <- usage:
+	default:
+		logger.Log.Warn("API key usage queue is full, dropping last-used update")
+	}
+}
+
+// StartLastUsedWorker фоново записывает отметки последнего использования ключей
+func (s *APIKeyService) StartLastUsedWorker(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case usage := <-s.lastUsed:
+				writeCtx, cancel := context.WithTimeout(context.Background(), lastUsedWriteTimeout)
+				if err := s.apiKeyRepo.UpdateLastUsed(writeCtx, usage.keyID, usage.usedAt, usage.ip); err != nil {
+					logger.Log.Warn("Failed to update API key last-used: ", err)
+				}
+				cancel()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// parseAPIKey разбирает ключ вида ak_<prefix>_<secret>
+func parseAPIKey(rawKey string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(rawKey, model.APIKeyPrefix)
+	if !ok || len(rest) <= apiKeyPrefixBytes*2+1 || rest[apiKeyPrefixBytes*2] != '_' {
+		return "", "", false
+	}
+	return rest[:apiKeyPrefixBytes*2], rest[apiKeyPrefixBytes*2+1:], true
+}
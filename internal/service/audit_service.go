@@ -0,0 +1,300 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// auditStore журнал событий; в работе — repository.AuditRepository
+type auditStore interface {
+	CreateEvent(ctx context.Context, event *model.AuditEvent) error
+	QueryEvents(ctx context.Context, filter model.AuditQuery) ([]*model.AuditEvent, int, error)
+	ChainHead(ctx context.Context) (int64, string, error)
+	ListChain(ctx context.Context, afterSeq int64, limit int) ([]*model.AuditEvent, error)
+	CreateCheckpoint(ctx context.Context, checkpoint *model.AuditCheckpoint) error
+	ListCheckpoints(ctx context.Context) ([]*model.AuditCheckpoint, error)
+}
+
+type AuditService struct {
+	auditRepo  auditStore
+	jwtService *JWTService    // Подписывает отметки цепочки
+	exporter   *EventExporter // Внешние приемники событий; nil — не экспортировать
+}
+
+func NewAuditService(auditRepo repository.AuditRepository, jwtService *JWTService, exporter *EventExporter) *AuditService {
+	return &AuditService{
+		auditRepo:  &auditRepo,
+		jwtService: jwtService,
+		exporter:   exporter,
+	}
+}
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+
+	auditChainBatchSize = 1000
+)
+
+// Record пишет событие в журнал. Сбой записи не прерывает действие пользователя, только логируется.
+// Идентификатор запроса, IP и User-Agent, если не заданы, берутся из контекста запроса.
+func (s *AuditService) Record(ctx context.Context, event *model.AuditEvent) {
+	event.ID = uuid.NewString()
+	// Postgres хранит время с точностью до микросекунд — хеш должен сходиться после чтения
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	if event.Outcome == "" {
+		event.Outcome = model.AuditOutcomeSuccess
+	}
+	// Postgres возвращает uuid в каноническом виде (строчные, с дефисами). Хеш считается
+	// от того же вида, иначе id в верхнем регистре или {в скобках} сломает проверку цепочки.
+	for _, id := range []*string{&event.UserID, &event.ActorID, &event.SessionID} {
+		if parsed, err := uuid.Parse(*id); err == nil {
+			*id = parsed.String()
+		}
+	}
+
+	info := util.RequestInfoFromContext(ctx)
+	if event.RequestID == "" {
+		event.RequestID = info.ID
+	}
+	if event.IP == "" {
+		event.IP = info.IP
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = info.UserAgent
+	}
+
+	// Событие пишется, даже если клиент уже разорвал соединение
+	if err := s.auditRepo.CreateEvent(context.WithoutCancel(ctx), event); err != nil {
+		logger.Log.Error("Failed to write audit event ", event.Type, ": ", err)
+	}
+
+	// В SIEM событие уходит, даже если в базу его записать не удалось
+	if s.exporter != nil {
+		s.exporter.Publish(event)
+	}
+}
+
+// RecordFailure пишет неудачную попытку; причина выводится из ошибки
+func (s *AuditService) RecordFailure(ctx context.Context, event *model.AuditEvent, err error) {
+	event.Outcome = model.AuditOutcomeFailure
+	event.Reason = auditReason(err)
+	s.Record(ctx, event)
+}
+
+// Query возвращает страницу журнала от новых событий к старым
+func (s *AuditService) Query(ctx context.Context, filter model.AuditQuery) (*model.AuditPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	events, total, err := s.auditRepo.QueryEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AuditPage{
+		Events: events,
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}, nil
+}
+
+// StartCheckpointRoutine периодически подписывает текущую голову цепочки
+func (s *AuditService) StartCheckpointRoutine(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.CreateCheckpoint(ctx); err != nil {
+					logger.Log.Warn("Failed to create audit checkpoint: ", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// CreateCheckpoint подписывает последнее событие цепочки. Пустая цепочка не отмечается.
+func (s *AuditService) CreateCheckpoint(ctx context.Context) error {
+	seq, hash, err := s.auditRepo.ChainHead(ctx)
+	if err != nil {
+		return err
+	}
+	if seq == 0 {
+		return nil
+	}
+
+	signature, err := s.jwtService.SignAuditCheckpoint(seq, hash)
+	if err != nil {
+		return err
+	}
+
+	return s.auditRepo.CreateCheckpoint(ctx, &model.AuditCheckpoint{
+		Seq:       seq,
+		Hash:      hash,
+		Signature: signature,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// VerifyChain проходит цепочку от начала и сообщает о первом нарушении: измененном
+// или пропавшем событии, неверной подписи отметки, отметке за концом цепочки (удален хвост)
+func (s *AuditService) VerifyChain(ctx context.Context) (*model.AuditChainReport, error) {
+	checkpoints, err := s.auditRepo.ListCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.AuditChainReport{}
+	prevHash := model.AuditGenesisHash
+	next := 0 // Следующая отметка для сверки
+
+	for {
+		events, err := s.auditRepo.ListChain(ctx, report.LastSeq, auditChainBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range events {
+			if brk := verifyChainLink(event, report.LastSeq+1, prevHash); brk != nil {
+				report.Broken = brk
+				return report, nil
+			}
+
+			for next < len(checkpoints) && checkpoints[next].Seq <= event.Seq {
+				if brk := s.verifyCheckpoint(checkpoints[next], event); brk != nil {
+					report.Broken = brk
+					return report, nil
+				}
+				report.CheckpointsChecked++
+				next++
+			}
+
+			prevHash = event.Hash
+			report.LastSeq = event.Seq
+			report.EventsChecked++
+		}
+
+		if len(events) < auditChainBatchSize {
+			break
+		}
+	}
+
+	// Отметка дальше последнего события — конец цепочки удален
+	if next < len(checkpoints) {
+		report.Broken = &model.AuditChainBreak{
+			Seq:    checkpoints[next].Seq,
+			Reason: "chain ends before signed checkpoint",
+		}
+	}
+
+	return report, nil
+}
+
+// verifyChainLink сверяет событие с ожидаемым номером и хешем предыдущего
+func verifyChainLink(event *model.AuditEvent, expectedSeq int64, prevHash string) *model.AuditChainBreak {
+	brk := &model.AuditChainBreak{Seq: event.Seq, EventID: event.ID}
+
+	switch {
+	case event.Seq != expectedSeq:
+		brk.Seq = expectedSeq
+		brk.EventID = ""
+		brk.Reason = "event missing"
+	case event.PrevHash != prevHash:
+		brk.Reason = "prev_hash does not match previous event"
+	default:
+		hash, err := event.ChainHash()
+		if err != nil || hash != event.Hash {
+			brk.Reason = "content hash mismatch"
+			break
+		}
+		return nil
+	}
+
+	return brk
+}
+
+// verifyCheckpoint проверяет подпись отметки и что она совпадает с событием цепочки
+func (s *AuditService) verifyCheckpoint(checkpoint *model.AuditCheckpoint, event *model.AuditEvent) *model.AuditChainBreak {
+	brk := &model.AuditChainBreak{Seq: checkpoint.Seq}
+
+	seq, hash, err := s.jwtService.VerifyAuditCheckpoint(checkpoint.Signature)
+	switch {
+	case err != nil, seq != checkpoint.Seq, hash != checkpoint.Hash:
+		brk.Reason = "checkpoint signature invalid"
+	case checkpoint.Seq != event.Seq:
+		// Между событиями нет пропусков, поэтому такое возможно только при подделке отметки
+		brk.Reason = "checkpoint refers to missing event"
+	case checkpoint.Hash != event.Hash:
+		brk.EventID = event.ID
+		brk.Reason = "event hash differs from signed checkpoint"
+	default:
+		return nil
+	}
+
+	return brk
+}
+
+// auditReason короткий код причины отказа для поля reason
+func auditReason(err error) string {
+	var statusErr *model.AccountStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code()
+	}
+	var throttled *model.LoginThrottledError
+	if errors.As(err, &throttled) {
+		return "login_throttled"
+	}
+
+	switch err {
+	case model.ErrInvalidCredentials:
+		return "invalid_credentials"
+	case model.ErrUserNotFound:
+		return "user_not_found"
+	case model.ErrTooManyAttempts:
+		return "too_many_attempts"
+	case model.ErrIPBlocked:
+		return "ip_blocked"
+	case model.ErrCaptchaRequired:
+		return "captcha_required"
+	case model.ErrCaptchaInvalid:
+		return "captcha_invalid"
+	case model.ErrPasswordResetRequired:
+		return "password_reset_required"
+	case model.ErrInvalidSession:
+		return "invalid_session"
+	case model.ErrSessionExpired:
+		return "session_expired"
+	case model.ErrInvalidMFACode:
+		return "invalid_mfa_code"
+	case model.ErrMFAChallengeFailed:
+		return "mfa_challenge_failed"
+	case model.ErrTooManyMFAAttempts:
+		return "too_many_mfa_attempts"
+	case model.ErrVerificationFailed:
+		return "verification_failed"
+	case model.ErrPhoneAlreadyInUse:
+		return "phone_already_in_use"
+	default:
+		return "internal_error"
+	}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryAuditStore журнал в памяти. Строки хранятся в том виде, в каком их вернет Postgres:
+// uuid канонические, время в UTC с точностью до микросекунд, metadata прошла через JSONB.
+type memoryAuditStore struct {
+	rows        []*model.AuditEvent
+	checkpoints []*model.AuditCheckpoint
+}
+
+func (m *memoryAuditStore) CreateEvent(_ context.Context, event *model.AuditEvent) error {
+	seq, prevHash, _ := m.ChainHead(context.Background())
+	event.Seq = seq + 1
+	event.PrevHash = prevHash
+
+	var err error
+	event.Hash, err = event.ChainHash()
+	if err != nil {
+		return err
+	}
+
+	row := *event
+	for _, id := range []*string{&row.ID, &row.UserID, &row.ActorID, &row.SessionID} {
+		if *id == "" {
+			continue
+		}
+		parsed, err := uuid.Parse(*id)
+		if err != nil {
+			return err // invalid input syntax for type uuid
+		}
+		*id = parsed.String()
+	}
+	row.CreatedAt = row.CreatedAt.UTC().Truncate(time.Microsecond)
+
+	metadata, err := json.Marshal(row.Metadata)
+	if err != nil {
+		return err
+	}
+	row.Metadata = nil
+	if err := json.Unmarshal(metadata, &row.Metadata); err != nil {
+		return err
+	}
+
+	m.rows = append(m.rows, &row)
+	return nil
+}
+
+func (m *memoryAuditStore) QueryEvents(context.Context, model.AuditQuery) ([]*model.AuditEvent, int, error) {
+	return m.rows, len(m.rows), nil
+}
+
+func (m *memoryAuditStore) ChainHead(context.Context) (int64, string, error) {
+	if len(m.rows) == 0 {
+		return 0, model.AuditGenesisHash, nil
+	}
+	last := m.rows[len(m.rows)-1]
+	return last.Seq, last.Hash, nil
+}
+
+func (m *memoryAuditStore) ListChain(_ context.Context, afterSeq int64, limit int) ([]*model.AuditEvent, error) {
+	var events []*model.AuditEvent
+	for _, row := range m.rows {
+		if row.Seq > afterSeq && len(events) < limit {
+			event := *row
+			events = append(events, &event)
+		}
+	}
+	return events, nil
+}
+
+func (m *memoryAuditStore) CreateCheckpoint(_ context.Context, checkpoint *model.AuditCheckpoint) error {
+	m.checkpoints = append(m.checkpoints, checkpoint)
+	return nil
+}
+
+func (m *memoryAuditStore) ListCheckpoints(context.Context) ([]*model.AuditCheckpoint, error) {
+	return m.checkpoints, nil
+}
+
+func newTestAuditService(t *testing.T) (*AuditService, *memoryAuditStore) {
+	t.Helper()
+
+	jwtService, _ := newTestJWTService(t)
+	store := &memoryAuditStore{}
+	return &AuditService{auditRepo: store, jwtService: jwtService}, store
+}
+
+func TestAuditChainRoundTrip(t *testing.T) {
+	s, store := newTestAuditService(t)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	actorID := uuid.New()
+	ids := []string{
+		userID.String(),
+		strings.ToUpper(userID.String()),
+		"{" + userID.String() + "}",
+		"urn:uuid:" + userID.String(),
+		strings.ReplaceAll(userID.String(), "-", ""),
+	}
+
+	for _, id := range ids {
+		s.Record(ctx, &model.AuditEvent{
+			UserID:    id,
+			ActorID:   strings.ToUpper(actorID.String()),
+			SessionID: "{" + strings.ToUpper(uuid.NewString()) + "}",
+			Type:      model.AuditLoginFailed,
+			IP:        "192.0.2.1",
+			Metadata:  map[string]string{"path_id": id},
+		})
+	}
+	s.Record(ctx, &model.AuditEvent{Type: model.AuditLogout})
+
+	if err := s.CreateCheckpoint(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, row := range store.rows {
+		if row.UserID != "" && row.UserID != userID.String() {
+			t.Fatalf("stored user id %q, want %q", row.UserID, userID.String())
+		}
+		hash, err := row.ChainHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != row.Hash {
+			t.Fatalf("event %d: hash after read %s, stored %s", row.Seq, hash, row.Hash)
+		}
+	}
+
+	report, err := s.VerifyChain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Broken != nil {
+		t.Fatalf("chain reported broken: %+v", *report.Broken)
+	}
+	if report.EventsChecked != int64(len(ids)+1) || report.CheckpointsChecked != 1 {
+		t.Fatalf("unexpected report %+v", *report)
+	}
+}
+
+func TestAuditChainDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(store *memoryAuditStore)
+		seq    int64
+		reason string
+	}{
+		{
+			name:   "changed content",
+			tamper: func(store *memoryAuditStore) { store.rows[1].IP = "198.51.100.1" },
+			seq:    2,
+			reason: "content hash mismatch",
+		},
+		{
+			name:   "deleted event",
+			tamper: func(store *memoryAuditStore) { store.rows = append(store.rows[:1], store.rows[2:]...) },
+			seq:    2,
+			reason: "event missing",
+		},
+		{
+			name: "rehashed event",
+			tamper: func(store *memoryAuditStore) {
+				store.rows[2].Outcome = model.AuditOutcomeSuccess
+				store.rows[2].Hash, _ = store.rows[2].ChainHash()
+			},
+			seq:    3,
+			reason: "event hash differs from signed checkpoint",
+		},
+		{
+			name:   "truncated tail",
+			tamper: func(store *memoryAuditStore) { store.rows = store.rows[:2] },
+			seq:    3,
+			reason: "chain ends before signed checkpoint",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := newTestAuditService(t)
+			ctx := context.Background()
+
+			for i := 0; i < 3; i++ {
+				s.RecordFailure(ctx, &model.AuditEvent{UserID: uuid.NewString(), Type: model.AuditLoginFailed}, model.ErrInvalidCredentials)
+			}
+			if err := s.CreateCheckpoint(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.tamper(store)
+
+			report, err := s.VerifyChain(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Broken == nil {
+				t.Fatal("tampering was not detected")
+			}
+			if report.Broken.Seq != tt.seq || report.Broken.Reason != tt.reason {
+				t.Fatalf("broken = %+v, want seq %d %q", *report.Broken, tt.seq, tt.reason)
+			}
+		})
+	}
+}
@@ -2,54 +2,129 @@ package service
 
 import (
 	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/ratelimit"
 	"authorization_authentication/internal/repository"
 	"authorization_authentication/internal/util"
+	"authorization_authentication/pkg/logger"
 	"context"
+	"encoding/json"
+	"errors"
 	"github.com/redis/go-redis/v9"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/twilio/twilio-go"
-	verify "github.com/twilio/twilio-go/rest/verify/v2"
 )
 
 type AuthService struct {
-	userRepo     repository.UserRepository
-	sessionRepo  repository.SessionRepository
-	jwtService   *JWTService
-	redisClient  *redis.Client
-	twilioClient *twilio.RestClient
-	serviceSID   string
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	jwtService  *JWTService
+	mfaService  *MFAService
+	emailVerify *EmailVerificationService
+	redisClient *redis.Client
+	limiter     ratelimit.Limiter        // Счетчики неудачных входов
+	failures    ratelimit.FailureTracker // Задержки после неудачных входов
+	captcha     CaptchaVerifier
+	reputation  *IPReputationService // Блокировка сетей и списки разрешенных/запрещенных
+	verifier    VerificationProvider
+	phones      *util.PhoneNormalizer
+	blocklist   *util.DomainBlocklist // Домены, с которых нельзя регистрироваться
+	audit       *AuditService
+	loginAlerts *LoginAlertService // Уведомления о входе с нового устройства
 }
 
 func NewAuthService(
 	userRepo repository.UserRepository,
 	sessionRepo repository.SessionRepository,
 	jwtService *JWTService,
+	mfaService *MFAService,
+	emailVerify *EmailVerificationService,
 	redisClient *redis.Client,
-	twilioClient *twilio.RestClient,
-	serviceSID string,
+	limiter ratelimit.Limiter,
+	failures ratelimit.FailureTracker,
+	captcha CaptchaVerifier,
+	reputation *IPReputationService,
+	verifier VerificationProvider,
+	phones *util.PhoneNormalizer,
+	blocklist *util.DomainBlocklist,
+	audit *AuditService,
+	loginAlerts *LoginAlertService,
 ) *AuthService {
 	return &AuthService{
-		userRepo:     userRepo,
-		sessionRepo:  sessionRepo,
-		jwtService:   jwtService,
-		redisClient:  redisClient,
-		twilioClient: twilioClient,
-		serviceSID:   serviceSID,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		jwtService:  jwtService,
+		mfaService:  mfaService,
+		emailVerify: emailVerify,
+		redisClient: redisClient,
+		limiter:     limiter,
+		failures:    failures,
+		captcha:     captcha,
+		reputation:  reputation,
+		verifier:    verifier,
+		phones:      phones,
+		blocklist:   blocklist,
+		audit:       audit,
+		loginAlerts: loginAlerts,
 	}
 }
 
 const (
-	maxLoginAttempts = 5
-	blockDuration    = 5 * time.Minute
-	ipBlockDuration  = 30 * time.Minute // Более долгая блокировка по IP
-	maxIPAttempts    = 15               // Больше попыток для IP
-	cleanupInterval  = 1 * time.Hour    // Интервал очистки старых записей
+	cleanupInterval = 1 * time.Hour // Интервал очистки старых записей
+
+	// Неудачи с одной сети по одному адресу, после которых нужна CAPTCHA
+	captchaAfterFailures = 3
+	// Неудачи по адресу со всех сетей за emailFailureWindow, после которых CAPTCHA нужна везде
+	captchaAfterEmailFailures = 10
+	emailFailureWindow        = 15 * time.Minute
+
+	refreshTokenTTL = 7 * 24 * time.Hour
+	mfaChallengeTTL = 5 * time.Minute
+	maxMFAAttempts  = 5
+	maxMFASMSSends  = 3 // Сколько раз можно отправить SMS-код в рамках одного челленджа
+
+	phoneVerificationPurpose   = "phone_verification"
+	phoneVerificationTicketTTL = 30 * time.Minute
+)
+
+// Жесткая блокировка только по сети (IPReputationService). Неудачи по адресу не блокируют аккаунт — иначе злоумышленник
+// может запереть любой известный ему email, — а замедляют вход с той же сети (loginBackoff)
+// и требуют CAPTCHA. Владелец со своего обычного устройства войти по-прежнему может.
+var (
+	loginBackoff = ratelimit.BackoffPolicy{
+		Name:         "login_backoff",
+		FreeFailures: 2,
+		BaseDelay:    time.Second,
+		MaxDelay:     15 * time.Minute,
+		Window:       time.Hour,
+	}
+	loginFailuresByEmail = ratelimit.Policy{
+		Name:      "login_failures_email",
+		Algorithm: ratelimit.SlidingWindow,
+		Limit:     captchaAfterEmailFailures,
+		Window:    emailFailureWindow,
+	}
 )
 
-func (s *AuthService) Register(ctx context.Context, email, password, phone string) (*model.User, error) {
+// mfaChallengeState то, что помним о входе между паролем и вторым фактором
+type mfaChallengeState struct {
+	UserID    string   `json:"user_id"`
+	UserAgent string   `json:"user_agent"`
+	IP        string   `json:"ip"`
+	Methods   []string `json:"methods"`
+}
+
+func (s *AuthService) Register(ctx context.Context, email, password, phone string) (*model.RegistrationResult, error) {
+	email, err := util.NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.blocklist.Check(email); err != nil {
+		return nil, err
+	}
+
 	existingUser, err := s.userRepo.GetUserByEmail(ctx, email)
 	if err != nil {
 		return nil, err
@@ -58,28 +133,39 @@ func (s *AuthService) Register(ctx context.Context, email, password, phone strin
 		return nil, model.ErrUserAlreadyExists
 	}
 
-	hashedPassword, err := util.HashPassword(password)
+	if phone == "" {
+		return nil, model.ErrPhoneRequired
+	}
+	parsedPhone, err := s.phones.Parse(phone)
 	if err != nil {
 		return nil, err
 	}
 
-	if phone == "" {
-		return nil, model.ErrPhoneRequired
+	hashedPassword, err := util.HashPassword(password)
+	if err != nil {
+		return nil, err
 	}
 
-	// Создаем пользователя (пока не верифицирован)
+	// Создаем пользователя (пока ни email, ни телефон не подтверждены)
 	user := &model.User{
-		ID:        uuid.NewString(),
-		Email:     email,
-		Phone:     phone,
-		Verified:  false, // По умолчанию не верифицирован
-		Password:  hashedPassword,
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
+		ID:           uuid.NewString(),
+		Email:        email,
+		Phone:        parsedPhone.E164,
+		PhoneCountry: parsedPhone.Country,
+		Password:     hashedPassword,
+		Role:         model.RoleUser,
+		Status:       model.UserStatusActive,
+		CreatedAt:    time.Now().UTC(),
+		UpdatedAt:    time.Now().UTC(),
+	}
+
+	ticket, err := s.jwtService.GenerateTicket(user.ID, phoneVerificationPurpose, map[string]string{"phone": user.Phone}, phoneVerificationTicketTTL)
+	if err != nil {
+		return nil, err
 	}
 
 	// Отправляем SMS с кодом верификации
-	if err := s.sendVerificationCode(user.Phone); err != nil {
+	if err := s.verifier.SendCode(ctx, user.Phone, "sms"); err != nil {
 		return nil, err
 	}
 
@@ -88,257 +174,739 @@ func (s *AuthService) Register(ctx context.Context, email, password, phone strin
 		return nil, err
 	}
 
-	return user, nil
+	s.audit.Record(ctx, &model.AuditEvent{
+		UserID:  user.ID,
+		ActorID: user.ID,
+		Type:    model.AuditUserRegistered,
+	})
+
+	// Аккаунт уже создан, поэтому сбой почты не отменяет регистрацию: письмо можно запросить повторно
+	if err := s.emailVerify.SendVerificationEmail(ctx, user); err != nil {
+		logger.Log.Warn("Failed to send verification email: ", err)
+	}
+
+	return &model.RegistrationResult{User: user, VerificationTicket: ticket}, nil
 }
 
-func (s *AuthService) sendVerificationCode(phone string) error {
-	params := &verify.CreateVerificationParams{}
-	params.SetTo(phone)
-	params.SetChannel("sms")
+// VerifyPhone подтверждает телефон аккаунта, указанного в тикете из /register.
+// Номер берется из тикета, поэтому подтвердить чужой аккаунт с тем же номером нельзя.
+func (s *AuthService) VerifyPhone(ctx context.Context, ticket, code string) error {
+	userID, err := s.verifyPhone(ctx, ticket, code)
+	if err != nil {
+		s.audit.RecordFailure(ctx, &model.AuditEvent{
+			UserID: userID,
+			Type:   model.AuditPhoneVerificationFailed,
+		}, err)
+		return err
+	}
 
-	_, err := s.twilioClient.VerifyV2.CreateVerification(s.serviceSID, params)
-	return err
+	s.audit.Record(ctx, &model.AuditEvent{
+		UserID:  userID,
+		ActorID: userID,
+		Type:    model.AuditPhoneVerified,
+	})
+	return nil
 }
 
-func (s *AuthService) VerifyPhone(ctx context.Context, phone, code string) error {
-	params := &verify.CreateVerificationCheckParams{}
-	params.SetTo(phone)
-	params.SetCode(code)
+// verifyPhone возвращает ID пользователя из тикета, если его удалось прочитать, даже при ошибке
+func (s *AuthService) verifyPhone(ctx context.Context, ticket, code string) (string, error) {
+	claims, err := s.jwtService.ValidateTicket(ticket, phoneVerificationPurpose)
+	if err != nil {
+		return "", model.ErrVerificationFailed
+	}
+	userID, _ := (*claims)["sub"].(string)
+	phone, _ := (*claims)["phone"].(string)
+	if userID == "" || phone == "" {
+		return "", model.ErrVerificationFailed
+	}
 
-	resp, err := s.twilioClient.VerifyV2.CreateVerificationCheck(s.serviceSID, params)
+	approved, err := s.verifier.CheckCode(ctx, phone, code)
 	if err != nil {
-		return err
+		return userID, err
 	}
 
-	if *resp.Status != "approved" {
-		return model.ErrVerificationFailed
+	if !approved {
+		return userID, model.ErrVerificationFailed
 	}
 
 	// Обновляем статус верификации пользователя
-	return s.userRepo.UpdateVerificationStatus(ctx, phone, true)
+	return userID, s.userRepo.MarkPhoneVerified(ctx, userID, phone)
+}
+
+// Login проверяет пароль и выдает токены. Если у пользователя включен второй фактор,
+// вместо токенов возвращается MFAChallenge, который завершается через CompleteMFALogin.
+// captchaToken нужен, только если предыдущая попытка вернула ErrCaptchaRequired.
+func (s *AuthService) Login(ctx context.Context, email, password, captchaToken, userAgent, ip string) (*model.LoginResult, error) {
+	user, err := s.AuthenticateUser(ctx, email, password, captchaToken, ip)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAccountStatus(user); err != nil {
+		s.audit.RecordFailure(ctx, &model.AuditEvent{
+			UserID:    user.ID,
+			Type:      model.AuditLoginFailed,
+			IP:        ip,
+			UserAgent: userAgent,
+		}, err)
+		return nil, err
+	}
+
+	return s.CompleteLogin(ctx, user.ID, userAgent, ip)
+}
+
+// CompleteLogin завершает вход пользователя, чья личность уже подтверждена первым фактором:
+// запрашивает второй фактор, если он включен, иначе создает сессию.
+func (s *AuthService) CompleteLogin(ctx context.Context, userID, userAgent, ip string) (*model.LoginResult, error) {
+	challenge, err := s.BeginMFAChallenge(ctx, userID, userAgent, ip)
+	if err != nil {
+		return nil, err
+	}
+	if challenge != nil {
+		s.audit.Record(ctx, &model.AuditEvent{
+			UserID:    userID,
+			Type:      model.AuditMFAChallengeIssued,
+			IP:        ip,
+			UserAgent: userAgent,
+			Metadata:  map[string]string{"methods": strings.Join(challenge.Methods, ",")},
+		})
+		return &model.LoginResult{Challenge: challenge}, nil
+	}
+
+	tokens, err := s.IssueTokens(ctx, &model.Session{
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        ip,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.LoginResult{Tokens: tokens}, nil
+}
+
+// BeginMFAChallenge создает одноразовый токен для второго шага входа.
+// Возвращает nil, если второй фактор у пользователя не включен.
+func (s *AuthService) BeginMFAChallenge(ctx context.Context, userID, userAgent, ip string) (*model.MFAChallenge, error) {
+	methods, err := s.mfaService.Methods(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+
+	token, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(mfaChallengeState{
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        ip,
+		Methods:   methods,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redisClient.Set(ctx, mfaChallengeKey(token), data, mfaChallengeTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	challenge := &model.MFAChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+		Methods:     methods,
+		ExpiresIn:   int(mfaChallengeTTL.Seconds()),
+	}
+
+	// Если SMS — единственный фактор, код отправляем сразу. При включенном TOTP
+	// SMS отправляется только по запросу через SendMFASMS, чтобы не тратить сообщения зря.
+	if slices.Contains(methods, model.MFAMethodSMS) && !slices.Contains(methods, model.MFAMethodTOTP) {
+		if err := s.SendMFASMS(ctx, token); err != nil {
+			return nil, err
+		}
+		challenge.SMSSent = true
+	}
+
+	return challenge, nil
+}
+
+// SendMFASMS отправляет SMS-код для челленджа. Число отправок на челлендж ограничено.
+func (s *AuthService) SendMFASMS(ctx context.Context, mfaToken string) error {
+	state, err := s.loadMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(state.Methods, model.MFAMethodSMS) {
+		return model.ErrMFANotEnabled
+	}
+
+	sends, err := incrementWithTTL(ctx, s.redisClient, mfaSMSSendsKey(mfaToken), mfaChallengeTTL)
+	if err != nil {
+		return err
+	}
+	if sends > maxMFASMSSends {
+		return model.ErrTooManyCodeRequests
+	}
+
+	return s.mfaService.SendSMSCode(ctx, state.UserID)
+}
+
+// CompleteMFALogin проверяет второй фактор и выдает токены для сессии, начатой в Login
+func (s *AuthService) CompleteMFALogin(ctx context.Context, mfaToken, method, code string) (*model.AuthTokens, error) {
+	state, err := s.verifyMFAChallenge(ctx, mfaToken, method, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.IssueTokens(ctx, &model.Session{
+		UserID:    state.UserID,
+		UserAgent: state.UserAgent,
+		IP:        state.IP,
+	})
+}
+
+// VerifyMFAChallenge проверяет второй фактор и возвращает ID пользователя, не создавая сессию.
+// Нужен OAuth, который после входа выдает код авторизации, а не токены.
+func (s *AuthService) VerifyMFAChallenge(ctx context.Context, mfaToken, method, code string) (string, error) {
+	state, err := s.verifyMFAChallenge(ctx, mfaToken, method, code)
+	if err != nil {
+		return "", err
+	}
+	return state.UserID, nil
+}
+
+func (s *AuthService) verifyMFAChallenge(ctx context.Context, mfaToken, method, code string) (*mfaChallengeState, error) {
+	state, err := s.checkMFAChallenge(ctx, mfaToken, method, code)
+	if err != nil {
+		event := &model.AuditEvent{
+			Type:     model.AuditLoginFailed,
+			Metadata: map[string]string{"mfa_method": method},
+		}
+		if state != nil {
+			event.UserID = state.UserID
+			event.IP = state.IP
+			event.UserAgent = state.UserAgent
+		}
+		s.audit.RecordFailure(ctx, event, err)
+		return nil, err
+	}
+	return state, nil
+}
+
+// checkMFAChallenge при ошибке возвращает и состояние челленджа, если его удалось прочитать
+func (s *AuthService) checkMFAChallenge(ctx context.Context, mfaToken, method, code string) (*mfaChallengeState, error) {
+	key := mfaChallengeKey(mfaToken)
+	attemptsKey := mfaAttemptsKey(mfaToken)
+
+	state, err := s.loadMFAChallenge(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(state.Methods, method) {
+		return state, model.ErrInvalidMFACode
+	}
+
+	// Лимит попыток общий для всех способов: перебор SMS-кода не легче перебора TOTP
+	attempts, err := incrementWithTTL(ctx, s.redisClient, attemptsKey, mfaChallengeTTL)
+	if err != nil {
+		return state, err
+	}
+	if attempts > maxMFAAttempts {
+		s.redisClient.Del(ctx, key, attemptsKey, mfaSMSSendsKey(mfaToken))
+		return state, model.ErrTooManyMFAAttempts
+	}
+
+	if err := s.mfaService.Verify(ctx, state.UserID, method, code); err != nil {
+		return state, err
+	}
+
+	// Del вернет 0, если параллельный запрос уже использовал этот челлендж
+	deleted, err := s.redisClient.Del(ctx, key).Result()
+	if err != nil {
+		return state, err
+	}
+	if deleted == 0 {
+		return state, model.ErrMFAChallengeFailed
+	}
+	s.redisClient.Del(ctx, attemptsKey, mfaSMSSendsKey(mfaToken))
+
+	return state, nil
+}
+
+func (s *AuthService) loadMFAChallenge(ctx context.Context, mfaToken string) (*mfaChallengeState, error) {
+	if mfaToken == "" {
+		return nil, model.ErrMFAChallengeFailed
+	}
+
+	data, err := s.redisClient.Get(ctx, mfaChallengeKey(mfaToken)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, model.ErrMFAChallengeFailed
+		}
+		return nil, err
+	}
+
+	var state mfaChallengeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// incrementWithTTL увеличивает счетчик; TTL ставится только при создании ключа и не продлевается
+func incrementWithTTL(ctx context.Context, redisClient *redis.Client, key string, ttl time.Duration) (int64, error) {
+	pipe := redisClient.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
 }
 
-func (s *AuthService) Login(ctx context.Context, email, password, userAgent, ip string) (*model.AuthTokens, error) {
-	// Нормализация IP (если используется прокси, нужно учитывать X-Forwarded-For)
+// AuthenticateUser проверяет пароль с учетом задержек, CAPTCHA и блокировки по IP.
+// Используется как в /login, так и на странице входа OAuth.
+func (s *AuthService) AuthenticateUser(ctx context.Context, email, password, captchaToken, ip string) (*model.User, error) {
+	user, err := s.checkPassword(ctx, email, password, captchaToken, ip)
+	if err != nil {
+		event := &model.AuditEvent{
+			Type:     model.AuditLoginFailed,
+			IP:       ip,
+			Metadata: map[string]string{"email": email},
+		}
+		if user != nil {
+			event.UserID = user.ID
+		}
+		s.audit.RecordFailure(ctx, event, err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkPassword при неверном пароле возвращает и найденного пользователя, чтобы отказ попал в его журнал
+func (s *AuthService) checkPassword(ctx context.Context, email, password, captchaToken, ip string) (*model.User, error) {
+	// Обработчики передают уже нормализованный адрес; проверка на случай других вызывающих
 	normalizedIP, err := util.NormalizeIP(ip)
 	if err != nil {
 		return nil, err
 	}
 
-	// Проверка блокировки по IP
-	if err := s.checkIPAttempts(ctx, normalizedIP); err != nil {
+	// Проверка блокировки сети и списка запрещенных
+	if err := s.reputation.CheckLogin(ctx, normalizedIP); err != nil {
+		return nil, err
+	}
+
+	// Один и тот же адрес в разном регистре — один аккаунт и один счетчик попыток
+	email, err = util.NormalizeEmail(email)
+	if err != nil {
+		return nil, s.recordLoginFailure(ctx, model.ErrInvalidCredentials, normalizedIP, "")
+	}
+
+	// Задержка считается для пары адрес/сеть: перебор с одной сети замедляется,
+	// а вход владельца из его обычной сети — нет
+	pairKey := s.loginPairKey(email, normalizedIP)
+	backoff, err := s.failures.Failures(ctx, loginBackoff, pairKey)
+	if err != nil {
 		return nil, err
 	}
+	if backoff.RetryAfter > 0 {
+		return nil, &model.LoginThrottledError{RetryAfter: backoff.RetryAfter}
+	}
 
-	// Проверка блокировки по email
-	if err := s.checkLoginAttempts(ctx, email); err != nil {
+	if err := s.checkCaptcha(ctx, email, backoff.Failures, captchaToken, normalizedIP); err != nil {
 		return nil, err
 	}
 
 	user, err := s.userRepo.GetUserByEmail(ctx, email)
 	if err != nil {
-		s.incrementIPAttempts(ctx, normalizedIP) // Увеличиваем счетчик для IP при любой ошибке
 		return nil, err
 	}
 
 	if user == nil {
-		s.incrementLoginAttempts(ctx, email)
-		s.incrementIPAttempts(ctx, normalizedIP)
-		return nil, model.ErrUserNotFound
+		return nil, s.recordLoginFailure(ctx, model.ErrUserNotFound, normalizedIP, email)
 	}
 
 	if !util.CheckPasswordHash(password, user.Password) {
-		s.incrementLoginAttempts(ctx, email)
-		s.incrementIPAttempts(ctx, normalizedIP)
-		return nil, model.ErrInvalidCredentials
+		return user, s.recordLoginFailure(ctx, model.ErrInvalidCredentials, normalizedIP, email)
+	}
+
+	// Сброс задержки при успешном входе. Счетчик по адресу не сбрасывается: вход владельца
+	// не должен снимать CAPTCHA для перебора с других сетей. Счетчик сети тоже: одну сеть делят
+	// многие пользователи, и вход в собственный аккаунт не должен разблокировать перебор чужих.
+	if err := s.failures.ClearFailures(ctx, loginBackoff, pairKey); err != nil {
+		logger.Log.Warn("Failed to reset login failures: ", err)
+	}
+
+	return user, nil
+}
+
+// checkCaptcha требует CAPTCHA после captchaAfterFailures неудач с этой сети
+// или captchaAfterEmailFailures неудач по адресу со всех сетей
+func (s *AuthService) checkCaptcha(ctx context.Context, email string, pairFailures int, captchaToken, ip string) error {
+	required := pairFailures >= captchaAfterFailures
+	if !required {
+		result, err := s.limiter.Peek(ctx, loginFailuresByEmail, email)
+		if err != nil {
+			return err
+		}
+		required = !result.Allowed
+	}
+	if !required {
+		return nil
+	}
+
+	if captchaToken == "" {
+		return model.ErrCaptchaRequired
+	}
+	ok, err := s.captcha.Verify(ctx, captchaToken, ip)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// Неверные ответы тоже попытки: иначе CAPTCHA можно перебирать бесплатно
+		if err := s.reputation.RecordLoginFailure(ctx, ip); err != nil {
+			return err
+		}
+		return model.ErrCaptchaInvalid
+	}
+	return nil
+}
+
+// recordLoginFailure учитывает неудачный вход по сети и, если задан адрес, по адресу и паре адрес/сеть.
+// Возвращает failure; если счетчик записать не удалось — эту ошибку: без учета попыток перебор не ограничен.
+func (s *AuthService) recordLoginFailure(ctx context.Context, failure error, ip, email string) error {
+	if err := s.reputation.RecordLoginFailure(ctx, ip); err != nil {
+		return err
+	}
+	if email == "" {
+		return failure
+	}
+
+	if _, err := s.limiter.Allow(ctx, loginFailuresByEmail, email); err != nil {
+		return err
+	}
+	if _, err := s.failures.RecordFailure(ctx, loginBackoff, s.loginPairKey(email, ip)); err != nil {
+		return err
+	}
+	return failure
+}
+
+// loginPairKey ключ задержки для пары адрес/сеть. Сеть та же, по которой IPReputationService
+// считает неудачи, чтобы размер сети задавался в одном месте.
+func (s *AuthService) loginPairKey(email, ip string) string {
+	return email + "|" + s.reputation.Network(ip)
+}
+
+// checkAccountStatus не дает войти в приостановленный, отключенный, удаленный
+// или ожидающий смены пароля аккаунт
+func checkAccountStatus(user *model.User) error {
+	// Удаленный аккаунт можно только восстановить через /account/restore
+	if err := user.StatusError(time.Now().UTC()); err != nil {
+		return err
+	}
+	if user.PasswordResetRequired {
+		return model.ErrPasswordResetRequired
+	}
+	return nil
+}
+
+// CheckUserStatus проверяет, что аккаунт все еще можно использовать. Вызывается для
+// каждого запроса с токеном или API-ключом: отключение аккаунта действует сразу,
+// не дожидаясь истечения выданных access-токенов.
+func (s *AuthService) CheckUserStatus(ctx context.Context, userID string) error {
+	user, err := s.userRepo.GetAccountStatus(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return model.ErrUserNotFound
 	}
+	return checkAccountStatus(user)
+}
 
-	// Сброс счетчиков при успешном входе
-	s.resetLoginAttempts(ctx, email)
-	s.resetIPAttempts(ctx, normalizedIP)
+// IssueTokens создает новую сессию и выдает пару токенов — это завершение любого способа входа.
+// Вызывающий заполняет UserID, UserAgent, IP и, для OAuth, ClientID и Scope.
+func (s *AuthService) IssueTokens(ctx context.Context, session *model.Session) (*model.AuthTokens, error) {
+	tokens, err := s.issueTokens(ctx, session)
 
-	accessToken, expiresAtUnix, err := s.jwtService.GenerateToken(user.ID)
+	event := &model.AuditEvent{
+		UserID:    session.UserID,
+		ActorID:   session.UserID,
+		SessionID: session.ID,
+		Type:      model.AuditLoginSucceeded,
+		IP:        session.IP,
+		UserAgent: session.UserAgent,
+		Metadata:  sessionMetadata(session),
+	}
 	if err != nil {
+		event.Type = model.AuditLoginFailed
+		s.audit.RecordFailure(ctx, event, err)
 		return nil, err
 	}
+	s.audit.Record(ctx, event)
 
-	refreshToken := uuid.NewString()
-	refreshExpiresAt := time.Now().UTC().Add(7 * 24 * time.Hour)
+	return tokens, nil
+}
 
-	session := &model.Session{
-		ID:           uuid.NewString(),
-		UserID:       user.ID,
-		RefreshToken: refreshToken,
-		ExpiresAt:    refreshExpiresAt,
-		UserAgent:    userAgent,
-		IP:           ip,
-		CreatedAt:    time.Now().UTC(),
+func (s *AuthService) issueTokens(ctx context.Context, session *model.Session) (*model.AuthTokens, error) {
+	user, err := s.userRepo.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, model.ErrUserNotFound
+	}
+	if err := checkAccountStatus(user); err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresAtUnix, err := s.jwtService.GenerateToken(session.UserID, TokenOptions{
+		ClientID: session.ClientID,
+		Scope:    session.Scope,
+		User:     user,
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	// Проверяем до создания сессии, иначе она сама окажется "знакомым" устройством
+	knownDevice, hasSessions, err := s.sessionRepo.CheckKnownDevice(ctx, user.ID, session.UserAgent, session.IP)
+	if err != nil {
+		logger.Log.Warn("Failed to check login device: ", err)
+		knownDevice = true
+	}
+
+	now := time.Now().UTC()
+	session.ID = uuid.NewString()
+	session.RefreshToken = uuid.NewString()
+	session.ExpiresAt = now.Add(refreshTokenTTL)
+	session.CreatedAt = now
+	session.IsRevoked = false
+
 	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
 		return nil, err
 	}
 
+	// О первом входе в аккаунт не уведомляем: устройство сравнивать не с чем
+	if !knownDevice && hasSessions {
+		s.loginAlerts.NotifyNewDevice(ctx, user, session)
+	}
+
 	return &model.AuthTokens{
 		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		RefreshToken: session.RefreshToken,
 		ExpiresIn:    int(time.Unix(expiresAtUnix, 0).Sub(time.Now()).Seconds()),
+		Scope:        session.Scope,
 	}, nil
 }
 
-func (s *AuthService) RefreshTokens(ctx context.Context, refreshToken string) (*model.AuthTokens, error) {
+// RefreshTokens ротирует refresh-токен. clientID — клиент, предъявивший токен
+// (пусто для /refresh): токен OAuth-клиента нельзя обновить от имени другого клиента.
+func (s *AuthService) RefreshTokens(ctx context.Context, refreshToken, clientID string) (*model.AuthTokens, error) {
 	session, err := s.sessionRepo.GetSessionByToken(ctx, refreshToken)
 	if err != nil {
+		s.audit.RecordFailure(ctx, &model.AuditEvent{
+			Type:     model.AuditTokenRefreshFailed,
+			Metadata: map[string]string{"client_id": clientID},
+		}, model.ErrInvalidSession)
 		return nil, model.ErrInvalidSession
 	}
 
-	if session.IsRevoked || session.ExpiresAt.Before(time.Now().UTC()) {
-		return nil, model.ErrSessionExpired
+	refreshed := &model.Session{
+		UserID:    session.UserID,
+		UserAgent: session.UserAgent,
+		IP:        session.IP,
+		ClientID:  session.ClientID,
+		Scope:     session.Scope,
 	}
+	tokens, err := s.rotateSession(ctx, session, refreshed, clientID)
 
-	if err := s.sessionRepo.RevokeSession(ctx, session.ID); err != nil {
-		return nil, err
+	event := &model.AuditEvent{
+		UserID:    session.UserID,
+		ActorID:   session.UserID,
+		SessionID: refreshed.ID,
+		Type:      model.AuditTokenRefreshed,
+		Metadata:  sessionMetadata(session),
 	}
-
-	accessToken, expiresAtUnix, err := s.jwtService.GenerateToken(session.UserID)
+	event.Metadata["previous_session_id"] = session.ID
 	if err != nil {
+		event.Type = model.AuditTokenRefreshFailed
+		event.SessionID = session.ID
+		s.audit.RecordFailure(ctx, event, err)
 		return nil, err
 	}
+	s.audit.Record(ctx, event)
 
-	newRefreshToken := uuid.NewString()
-	newExpiresAt := time.Now().UTC().Add(7 * 24 * time.Hour)
+	return tokens, nil
+}
 
-	newSession := &model.Session{
-		ID:           uuid.NewString(),
-		UserID:       session.UserID,
-		RefreshToken: newRefreshToken,
-		ExpiresAt:    newExpiresAt,
-		CreatedAt:    time.Now().UTC(),
+// rotateSession отзывает предъявленную сессию и создает на ее месте новую
+func (s *AuthService) rotateSession(ctx context.Context, session, refreshed *model.Session, clientID string) (*model.AuthTokens, error) {
+	if session.ClientID != clientID {
+		return nil, model.ErrInvalidSession
 	}
 
-	if err := s.sessionRepo.CreateSession(ctx, newSession); err != nil {
+	if session.IsRevoked || session.ExpiresAt.Before(time.Now().UTC()) {
+		return nil, model.ErrSessionExpired
+	}
+
+	// Проверяем до ротации: сессия приостановленного аккаунта снова заработает после приостановки
+	if err := s.CheckUserStatus(ctx, session.UserID); err != nil {
 		return nil, err
 	}
 
-	return &model.AuthTokens{
-		AccessToken:  accessToken,
-		RefreshToken: newRefreshToken,
-		ExpiresIn:    int(time.Unix(expiresAtUnix, 0).Sub(time.Now()).Seconds()),
-	}, nil
-}
+	if err := s.sessionRepo.RevokeSession(ctx, session.ID); err != nil {
+		return nil, err
+	}
 
-func (s *AuthService) VerifyToken(token string) (*jwt.MapClaims, error) {
-	return s.jwtService.ValidateToken(token)
+	return s.issueTokens(ctx, refreshed)
 }
 
-func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
-	session, err := s.sessionRepo.GetSessionByToken(ctx, refreshToken)
-	if err != nil {
-		return model.ErrInvalidSession
+// sessionMetadata поля сессии OAuth-клиента для журнала; у сессий /login пусто
+func sessionMetadata(session *model.Session) map[string]string {
+	metadata := map[string]string{}
+	if session.ClientID != "" {
+		metadata["client_id"] = session.ClientID
 	}
-
-	return s.sessionRepo.RevokeSession(ctx, session.ID)
+	if session.Scope != "" {
+		metadata["scope"] = session.Scope
+	}
+	return metadata
 }
 
-func (s *AuthService) checkLoginAttempts(ctx context.Context, email string) error {
-	key := "login_attempts:" + email
+// IntrospectToken возвращает состояние access- или refresh-токена (RFC 7662).
+// Недействительный, истекший или отозванный токен — это не ошибка, а ответ с active=false.
+func (s *AuthService) IntrospectToken(ctx context.Context, token, tokenTypeHint string) (*model.TokenIntrospection, error) {
+	inactive := &model.TokenIntrospection{Active: false}
+	if token == "" {
+		return inactive, nil
+	}
 
-	// Получаем текущее количество попыток
-	attempts, err := s.redisClient.Get(ctx, key).Int()
-	if err != nil && err != redis.Nil {
-		return err
+	// Подсказка только меняет порядок проверок, как и допускает RFC
+	lookups := []func(context.Context, string) (*model.TokenIntrospection, error){
+		s.introspectAccessToken,
+		s.introspectRefreshToken,
+	}
+	if tokenTypeHint == model.TokenTypeRefresh {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
 	}
 
-	if attempts >= maxLoginAttempts {
-		return model.ErrTooManyAttempts
+	for _, lookup := range lookups {
+		result, err := lookup(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			return result, nil
+		}
 	}
 
-	return nil
+	return inactive, nil
 }
 
-func (s *AuthService) incrementLoginAttempts(ctx context.Context, email string) error {
-	key := "login_attempts:" + email
-
-	// Увеличиваем счетчик попыток
-	_, err := s.redisClient.Incr(ctx, key).Result()
+func (s *AuthService) introspectAccessToken(ctx context.Context, token string) (*model.TokenIntrospection, error) {
+	claims, err := s.jwtService.ValidateToken(token)
 	if err != nil {
-		return err
+		return nil, nil
 	}
 
-	// Устанавливаем TTL для ключа
-	if _, err := s.redisClient.Expire(ctx, key, blockDuration).Result(); err != nil {
-		return err
+	result := &model.TokenIntrospection{
+		Active:    true,
+		TokenType: model.TokenTypeAccess,
 	}
+	result.Sub, _ = (*claims)["sub"].(string)
+	result.Scope, _ = (*claims)["scope"].(string)
+	result.ClientID, _ = (*claims)["client_id"].(string)
 
-	return nil
-}
+	// У токенов client_credentials sub — это клиент, а не пользователь
+	if result.Sub != "" && result.Sub != result.ClientID {
+		if err := s.CheckUserStatus(ctx, result.Sub); err != nil {
+			return nil, ignoreStatusError(err)
+		}
+	}
 
-func (s *AuthService) resetLoginAttempts(ctx context.Context, email string) error {
-	key := "login_attempts:" + email
-	return s.redisClient.Del(ctx, key).Err()
-}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		result.Exp = exp.Unix()
+	}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		result.Iat = iat.Unix()
+	}
 
-// Методы для работы с блокировкой по IP
-func (s *AuthService) checkIPAttempts(ctx context.Context, ip string) error {
-	key := "ip_attempts:" + ip
+	return result, nil
+}
 
-	attempts, err := s.redisClient.Get(ctx, key).Int()
-	if err != nil && err != redis.Nil {
-		return err
+func (s *AuthService) introspectRefreshToken(ctx context.Context, token string) (*model.TokenIntrospection, error) {
+	// Отозванные сессии репозиторий не возвращает
+	session, err := s.sessionRepo.GetSessionByToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, model.ErrSessionNotFound) {
+			return nil, nil
+		}
+		return nil, err
 	}
 
-	if attempts >= maxIPAttempts {
-		return model.ErrIPBlocked
+	if session.ExpiresAt.Before(time.Now().UTC()) {
+		return nil, nil
+	}
+	if err := s.CheckUserStatus(ctx, session.UserID); err != nil {
+		return nil, ignoreStatusError(err)
 	}
 
-	return nil
+	return &model.TokenIntrospection{
+		Active:    true,
+		Sub:       session.UserID,
+		Exp:       session.ExpiresAt.Unix(),
+		Iat:       session.CreatedAt.Unix(),
+		Scope:     session.Scope,
+		ClientID:  session.ClientID,
+		TokenType: model.TokenTypeRefresh,
+	}, nil
 }
 
-func (s *AuthService) incrementIPAttempts(ctx context.Context, ip string) error {
-	key := "ip_attempts:" + ip
+// ignoreStatusError отбрасывает ошибки, означающие, что аккаунт нельзя использовать:
+// для интроспекции это ответ active=false, а не сбой
+func ignoreStatusError(err error) error {
+	var statusErr *model.AccountStatusError
+	if errors.As(err, &statusErr) || err == model.ErrUserNotFound || err == model.ErrPasswordResetRequired {
+		return nil
+	}
+	return err
+}
 
-	_, err := s.redisClient.Incr(ctx, key).Result()
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	session, err := s.sessionRepo.GetSessionByToken(ctx, refreshToken)
 	if err != nil {
-		return err
+		s.audit.RecordFailure(ctx, &model.AuditEvent{Type: model.AuditLogout}, model.ErrInvalidSession)
+		return model.ErrInvalidSession
 	}
 
-	if _, err := s.redisClient.Expire(ctx, key, ipBlockDuration).Result(); err != nil {
+	if err := s.sessionRepo.RevokeSession(ctx, session.ID); err != nil {
 		return err
 	}
 
+	s.audit.Record(ctx, &model.AuditEvent{
+		UserID:    session.UserID,
+		ActorID:   session.UserID,
+		SessionID: session.ID,
+		Type:      model.AuditLogout,
+	})
 	return nil
 }
 
-func (s *AuthService) resetIPAttempts(ctx context.Context, ip string) error {
-	key := "ip_attempts:" + ip
-	return s.redisClient.Del(ctx, key).Err()
+func mfaChallengeKey(token string) string {
+	return "mfa_challenge:" + util.HashToken(token)
 }
 
-// Фоновая очистка старых записей
-func (s *AuthService) StartCleanupRoutine(ctx context.Context) {
-	go func() {
-		ticker := time.NewTicker(cleanupInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				s.cleanupOldAttempts(ctx)
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
+func mfaAttemptsKey(token string) string {
+	return "mfa_attempts:" + util.HashToken(token)
 }
 
-func (s *AuthService) cleanupOldAttempts(ctx context.Context) {
-	// В реальной реализации нужно использовать SCAN для больших БД
-	// Здесь упрощенный вариант для демонстрации
-	keys, _ := s.redisClient.Keys(ctx, "*attempts*").Result()
-	for _, key := range keys {
-		ttl, err := s.redisClient.TTL(ctx, key).Result()
-		if err == nil && ttl < 0 {
-			s.redisClient.Del(ctx, key)
-		}
-	}
+func mfaSMSSendsKey(token string) string {
+	return "mfa_sms_sends:" + util.HashToken(token)
 }
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CaptchaVerifier проверяет ответ пользователя на CAPTCHA
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token, ip string) (bool, error)
+}
+
+// StubCaptchaVerifier локальная заглушка: принимает только заранее заданный токен.
+// Без токена отклоняет все ответы. Для разработки и тестов.
+type StubCaptchaVerifier struct {
+	token string
+}
+
+func NewStubCaptchaVerifier(token string) *StubCaptchaVerifier {
+	return &StubCaptchaVerifier{token: token}
+}
+
+func (v *StubCaptchaVerifier) Verify(_ context.Context, token, _ string) (bool, error) {
+	if v.token == "" {
+		return false, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(v.token)) == 1, nil
+}
+
+const siteVerifyTimeout = 10 * time.Second
+
+// SiteVerifyCaptcha проверка через siteverify-API, общий для reCAPTCHA, hCaptcha и Turnstile
+type SiteVerifyCaptcha struct {
+	verifyURL string
+	secret    string
+	client    *http.Client
+}
+
+func NewSiteVerifyCaptcha(verifyURL, secret string) *SiteVerifyCaptcha {
+	return &SiteVerifyCaptcha{
+		verifyURL: verifyURL,
+		secret:    secret,
+		client:    &http.Client{Timeout: siteVerifyTimeout},
+	}
+}
+
+func (v *SiteVerifyCaptcha) Verify(ctx context.Context, token, ip string) (bool, error) {
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {token},
+	}
+	if ip != "" {
+		form.Set("remoteip", ip)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	return result.Success, nil
+}
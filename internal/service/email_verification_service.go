@@ -0,0 +1,142 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type EmailVerificationService struct {
+	userRepo    repository.UserRepository
+	mailer      Mailer
+	redisClient *redis.Client
+	audit       *AuditService
+	baseURL     string
+}
+
+func NewEmailVerificationService(
+	userRepo repository.UserRepository,
+	mailer Mailer,
+	redisClient *redis.Client,
+	audit *AuditService,
+	baseURL string,
+) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:    userRepo,
+		mailer:      mailer,
+		redisClient: redisClient,
+		audit:       audit,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+	}
+}
+
+const (
+	emailVerificationTTL     = 24 * time.Hour
+	emailResendWindow        = time.Hour
+	maxEmailResendsPerWindow = 3
+	emailVerificationPath    = "/verify-email"
+)
+
+// emailVerificationState хранится под хешем токена из письма
+type emailVerificationState struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"` // Адрес, на который ушло письмо
+}
+
+// SendVerificationEmail отправляет письмо со ссылкой подтверждения на текущий адрес пользователя
+func (s *EmailVerificationService) SendVerificationEmail(ctx context.Context, user *model.User) error {
+	token, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(emailVerificationState{UserID: user.ID, Email: user.Email})
+	if err != nil {
+		return err
+	}
+
+	if err := s.redisClient.Set(ctx, emailVerificationKey(token), data, emailVerificationTTL).Err(); err != nil {
+		return err
+	}
+
+	link := s.baseURL + emailVerificationPath + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, EmailMessage{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: "Please confirm your email address by opening the link below.\n" +
+			"The link is valid for 24 hours.\n\n" +
+			link + "\n",
+	})
+}
+
+// ResendVerificationEmail повторно отправляет письмо. Число писем за час ограничено.
+func (s *EmailVerificationService) ResendVerificationEmail(ctx context.Context, userID string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return model.ErrUserNotFound
+	}
+	if user.EmailVerified {
+		return model.ErrEmailAlreadyVerified
+	}
+
+	sends, err := incrementWithTTL(ctx, s.redisClient, "email_verification_sends:"+userID, emailResendWindow)
+	if err != nil {
+		return err
+	}
+	if sends > maxEmailResendsPerWindow {
+		return model.ErrTooManyCodeRequests
+	}
+
+	return s.SendVerificationEmail(ctx, user)
+}
+
+// ConfirmEmail подтверждает адрес по токену из письма. Токен одноразовый.
+func (s *EmailVerificationService) ConfirmEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return model.ErrEmailVerificationFailed
+	}
+
+	data, err := s.redisClient.GetDel(ctx, emailVerificationKey(token)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return model.ErrEmailVerificationFailed
+		}
+		return err
+	}
+
+	var state emailVerificationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	ok, err := s.userRepo.MarkEmailVerified(ctx, state.UserID, state.Email)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return model.ErrEmailVerificationFailed
+	}
+
+	s.audit.Record(ctx, &model.AuditEvent{
+		UserID:   state.UserID,
+		ActorID:  state.UserID,
+		Type:     model.AuditEmailVerified,
+		Metadata: map[string]string{"email": state.Email},
+	})
+	return nil
+}
+
+func emailVerificationKey(token string) string {
+	return "email_verification:" + util.HashToken(token)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"time"
+)
+
+// EventSink внешний получатель событий журнала аудита (SIEM, файл, webhook)
+type EventSink interface {
+	Send(ctx context.Context, event *model.AuditEvent) error
+	Close() error
+}
+
+const (
+	eventSinkQueueSize   = 1024
+	eventSinkSendTimeout = 2 * time.Minute // С учетом повторов webhook
+)
+
+// EventExporter рассылает события журнала по приемникам. У каждого приемника своя очередь,
+// поэтому медленный или недоступный приемник не задерживает остальных и сам журнал.
+type EventExporter struct {
+	routes []*sinkRoute
+}
+
+type sinkRoute struct {
+	name  string
+	sink  EventSink
+	types map[string]bool // Пусто — все типы событий
+	queue chan *model.AuditEvent
+}
+
+func NewEventExporter() *EventExporter {
+	return &EventExporter{}
+}
+
+// AddSink подключает приемник. eventTypes ограничивает, какие события ему отправлять; пустой список — все.
+func (e *EventExporter) AddSink(name string, sink EventSink, eventTypes []string) {
+	route := &sinkRoute{
+		name:  name,
+		sink:  sink,
+		queue: make(chan *model.AuditEvent, eventSinkQueueSize),
+	}
+	if len(eventTypes) > 0 {
+		route.types = make(map[string]bool, len(eventTypes))
+		for _, eventType := range eventTypes {
+			route.types[eventType] = true
+		}
+	}
+	e.routes = append(e.routes, route)
+}
+
+// Publish ставит событие в очереди подходящих приемников, не блокируясь
+func (e *EventExporter) Publish(event *model.AuditEvent) {
+	for _, route := range e.routes {
+		if route.types != nil && !route.types[event.Type] {
+			continue
+		}
+
+		copied := *event
+		select {
+		case route.queue <- &copied:
+		default:
+			logger.Log.Warn("Audit sink ", route.name, " queue is full, dropping event ", event.ID)
+		}
+	}
+}
+
+// Start запускает отправку в каждый приемник. При отмене ctx приемники закрываются.
+func (e *EventExporter) Start(ctx context.Context) {
+	for _, route := range e.routes {
+		go route.run(ctx)
+	}
+}
+
+func (r *sinkRoute) run(ctx context.Context) {
+	defer func() {
+		if err := r.sink.Close(); err != nil {
+			logger.Log.Warn("Failed to close audit sink ", r.name, ": ", err)
+		}
+	}()
+
+	for {
+		select {
+		case event := <-r.queue:
+			sendCtx, cancel := context.WithTimeout(ctx, eventSinkSendTimeout)
+			if err := r.sink.Send(sendCtx, event); err != nil {
+				logger.Log.Warn("Failed to export audit event ", event.ID, " to ", r.name, ": ", err)
+			}
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
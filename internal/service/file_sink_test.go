@@ -0,0 +1,124 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readEventIDs идентификаторы событий из файла по строкам
+func readEventIDs(t *testing.T, path string) []string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event model.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("%s: invalid line %q: %v", path, scanner.Text(), err)
+		}
+		ids = append(ids, event.ID)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "events.log")
+
+	line, err := json.Marshal(testAuditEvent("evt-0", model.AuditOutcomeSuccess))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ровно два события в файле
+	sink, err := NewFileSink(path, int64(2*(len(line)+1)), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 7; i++ {
+		if err := sink.Send(context.Background(), testAuditEvent(fmt.Sprintf("evt-%d", i), model.AuditOutcomeSuccess)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		path:        {"evt-6"},
+		path + ".1": {"evt-4", "evt-5"},
+		path + ".2": {"evt-2", "evt-3"},
+	}
+	for file, ids := range want {
+		got := readEventIDs(t, file)
+		if fmt.Sprint(got) != fmt.Sprint(ids) {
+			t.Errorf("%s = %v, want %v", filepath.Base(file), got, ids)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected no third backup, got %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestFileSinkWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	sink, err := NewFileSink(path, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := sink.Send(context.Background(), testAuditEvent(fmt.Sprintf("evt-%d", i), model.AuditOutcomeSuccess)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.Close()
+
+	if got := readEventIDs(t, path); fmt.Sprint(got) != "[evt-2]" {
+		t.Errorf("events.log = %v, want [evt-2]", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("expected no backups, got %v", err)
+	}
+}
+
+func TestFileSinkAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+
+	for i := 0; i < 2; i++ {
+		sink, err := NewFileSink(path, 1<<20, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Send(context.Background(), testAuditEvent(fmt.Sprintf("evt-%d", i), model.AuditOutcomeSuccess)); err != nil {
+			t.Fatal(err)
+		}
+		sink.Close()
+	}
+
+	if got := readEventIDs(t, path); fmt.Sprint(got) != "[evt-0 evt-1]" {
+		t.Errorf("events.log = %v, want [evt-0 evt-1]", got)
+	}
+}
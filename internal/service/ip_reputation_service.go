@@ -0,0 +1,274 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/ratelimit"
+	"authorization_authentication/internal/util"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ipBlockDuration = 30 * time.Minute // Более долгая блокировка по IP
+	maxIPAttempts   = 15               // Больше попыток для IP
+)
+
+// Неудачные входы считаются по сети, а не по отдельному адресу: у абонента обычно целая
+// IPv6 /64, и перебор с ротацией адресов внутри нее иначе никогда не блокируется
+var loginFailuresByIP = ratelimit.Policy{
+	Name:      "login_failures_ip",
+	Algorithm: ratelimit.SlidingWindow,
+	Limit:     maxIPAttempts,
+	Window:    ipBlockDuration,
+}
+
+// IPReputationService блокировка сетей после серии неудачных входов и статические списки:
+// разрешенные сети (офис, проверки доступности) не блокируются и не ограничиваются по частоте,
+// запрещенные не получают доступа вовсе. Списки перечитываются при изменении файлов.
+type IPReputationService struct {
+	limiter  ratelimit.Limiter
+	ipv4Bits int
+	ipv6Bits int
+
+	allowPath string
+	denyPath  string
+
+	mu    sync.RWMutex
+	lists ipAccessLists
+}
+
+// ipAccessLists списки вместе с временем изменения файлов, из которых они прочитаны
+type ipAccessLists struct {
+	allow    *util.CIDRList
+	deny     *util.CIDRList
+	allowMod time.Time
+	denyMod  time.Time
+	loadedAt time.Time
+}
+
+// NewIPReputationService ipv4Bits и ipv6Bits — длина префикса сети, по которой считаются неудачи.
+// Пустой путь — пустой список. Ошибка чтения списков при запуске фатальна, при перезагрузке — нет.
+func NewIPReputationService(limiter ratelimit.Limiter, ipv4Bits, ipv6Bits int, allowPath, denyPath string) (*IPReputationService, error) {
+	if ipv4Bits < 1 || ipv4Bits > 32 {
+		return nil, fmt.Errorf("ip reputation: invalid IPv4 prefix length %d", ipv4Bits)
+	}
+	if ipv6Bits < 1 || ipv6Bits > 128 {
+		return nil, fmt.Errorf("ip reputation: invalid IPv6 prefix length %d", ipv6Bits)
+	}
+
+	s := &IPReputationService{
+		limiter:   limiter,
+		ipv4Bits:  ipv4Bits,
+		ipv6Bits:  ipv6Bits,
+		allowPath: allowPath,
+		denyPath:  denyPath,
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Network сеть адреса, по которой считаются неудачи и частота запросов
+func (s *IPReputationService) Network(ip string) string {
+	return util.IPPrefix(ip, s.ipv4Bits, s.ipv6Bits)
+}
+
+// IsAllowed адрес в списке разрешенных
+func (s *IPReputationService) IsAllowed(ip string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lists.allow.Contains(ip)
+}
+
+// IsDenied адрес в списке запрещенных. Разрешенный список важнее.
+func (s *IPReputationService) IsDenied(ip string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !s.lists.allow.Contains(ip) && s.lists.deny.Contains(ip)
+}
+
+// CheckLogin возвращает ErrIPBlocked, если сеть адреса заблокирована или в списке запрещенных
+func (s *IPReputationService) CheckLogin(ctx context.Context, ip string) error {
+	if s.IsAllowed(ip) {
+		return nil
+	}
+	if s.IsDenied(ip) {
+		return model.ErrIPBlocked
+	}
+
+	result, err := s.limiter.Peek(ctx, loginFailuresByIP, s.Network(ip))
+	if err != nil {
+		return err
+	}
+	if !result.Allowed {
+		return model.ErrIPBlocked
+	}
+	return nil
+}
+
+// RecordLoginFailure учитывает неудачный вход с сети адреса; разрешенные сети не учитываются
+func (s *IPReputationService) RecordLoginFailure(ctx context.Context, ip string) error {
+	if s.IsAllowed(ip) {
+		return nil
+	}
+	_, err := s.limiter.Allow(ctx, loginFailuresByIP, s.Network(ip))
+	return err
+}
+
+// Blocks сети с неудачными входами, сначала заблокированные
+func (s *IPReputationService) Blocks(ctx context.Context) ([]model.IPBlock, error) {
+	entries, err := s.limiter.Entries(ctx, loginFailuresByIP)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	blocks := make([]model.IPBlock, 0, len(entries))
+	for _, entry := range entries {
+		failures := entry.Result.Limit - entry.Result.Remaining
+		if failures <= 0 {
+			continue
+		}
+		blocks = append(blocks, model.IPBlock{
+			Network:  entry.Key,
+			Failures: failures,
+			Limit:    entry.Result.Limit,
+			Blocked:  !entry.Result.Allowed,
+			ResetAt:  now.Add(entry.Result.Reset).Truncate(time.Second),
+		})
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].Blocked != blocks[j].Blocked {
+			return blocks[i].Blocked
+		}
+		if blocks[i].Failures != blocks[j].Failures {
+			return blocks[i].Failures > blocks[j].Failures
+		}
+		return blocks[i].Network < blocks[j].Network
+	})
+	return blocks, nil
+}
+
+// ClearBlock обнуляет счетчик неудач сети. Принимает сеть в виде, возвращаемом Blocks,
+// или адрес — тогда очищается сеть, в которую он входит.
+func (s *IPReputationService) ClearBlock(ctx context.Context, network string) (string, error) {
+	normalized, err := util.ParseNetwork(network)
+	if err != nil {
+		return "", model.ErrInvalidNetwork
+	}
+	if _, err := util.NormalizeIP(network); err == nil {
+		normalized = s.Network(network)
+	}
+
+	result, err := s.limiter.Peek(ctx, loginFailuresByIP, normalized)
+	if err != nil {
+		return "", err
+	}
+	if result.Remaining == result.Limit {
+		return "", model.ErrIPBlockNotFound
+	}
+
+	if err := s.limiter.Reset(ctx, loginFailuresByIP, normalized); err != nil {
+		return "", err
+	}
+	return normalized, nil
+}
+
+// Lists действующие списки разрешенных и запрещенных сетей
+func (s *IPReputationService) Lists() *model.IPAccessLists {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &model.IPAccessLists{
+		Allow:    s.lists.allow.Strings(),
+		Deny:     s.lists.deny.Strings(),
+		LoadedAt: s.lists.loadedAt,
+	}
+}
+
+// Reload перечитывает файлы списков, изменившиеся с прошлой загрузки. При ошибке
+// действующие списки остаются прежними. Сообщает, были ли изменения.
+func (s *IPReputationService) Reload() (bool, error) {
+	s.mu.RLock()
+	lists := s.lists
+	s.mu.RUnlock()
+
+	allow, allowMod, allowChanged, err := reloadCIDRList(s.allowPath, lists.allow, lists.allowMod)
+	if err != nil {
+		return false, err
+	}
+	deny, denyMod, denyChanged, err := reloadCIDRList(s.denyPath, lists.deny, lists.denyMod)
+	if err != nil {
+		return false, err
+	}
+	if !allowChanged && !denyChanged && !lists.loadedAt.IsZero() {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	s.lists = ipAccessLists{
+		allow:    allow,
+		deny:     deny,
+		allowMod: allowMod,
+		denyMod:  denyMod,
+		loadedAt: time.Now().UTC(),
+	}
+	s.mu.Unlock()
+	return true, nil
+}
+
+// StartReloadRoutine проверяет файлы списков каждые interval; ноль отключает перезагрузку
+func (s *IPReputationService) StartReloadRoutine(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || (s.allowPath == "" && s.denyPath == "") {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				changed, err := s.Reload()
+				if err != nil {
+					logger.Log.Warn("Failed to reload IP access lists, keeping previous ones: ", err)
+					continue
+				}
+				if changed {
+					lists := s.Lists()
+					logger.Log.Infof("Reloaded IP access lists: %d allowed, %d denied networks", len(lists.Allow), len(lists.Deny))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// reloadCIDRList читает список, только если файл изменился с modTime
+func reloadCIDRList(path string, current *util.CIDRList, modTime time.Time) (*util.CIDRList, time.Time, bool, error) {
+	if path == "" {
+		return nil, time.Time{}, false, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	if current != nil && info.ModTime().Equal(modTime) {
+		return current, modTime, false, nil
+	}
+
+	list, err := util.LoadCIDRList(path)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	return list, info.ModTime(), true, nil
+}
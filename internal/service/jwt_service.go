@@ -3,7 +3,9 @@ package service
 import (
 	"authorization_authentication/config"
 	"authorization_authentication/internal/model"
+	"fmt"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -31,19 +33,44 @@ func NewJWTService(cfg *config.Config) (*JWTService, error) {
 	}, nil
 }
 
-func (s *JWTService) GenerateToken(userID string) (string, int64, error) {
+const defaultAccessTokenTTL = 15 * time.Minute
+
+// TokenOptions дополнительные параметры access-токена
+type TokenOptions struct {
+	ClientID string        // OAuth-клиент, которому выдан токен
+	Scope    string        // Разрешения через пробел
+	TTL      time.Duration // По умолчанию defaultAccessTokenTTL
+	User     *model.User   // Для токенов пользователя: в claims попадают флаги подтверждения контактов
+}
+
+func (s *JWTService) GenerateToken(subject string, opts TokenOptions) (string, int64, error) {
 	key, err := jwt.ParseRSAPrivateKeyFromPEM(s.privateKey)
 	if err != nil {
 		return "", 0, err
 	}
 
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = defaultAccessTokenTTL
+	}
+
 	now := time.Now()
-	expiresAt := now.Add(15 * time.Minute)
+	expiresAt := now.Add(ttl)
 	claims := jwt.MapClaims{
-		"sub": userID,
+		"sub": subject,
 		"exp": expiresAt.Unix(),
 		"iat": now.Unix(),
 	}
+	if opts.ClientID != "" {
+		claims["client_id"] = opts.ClientID
+	}
+	if opts.Scope != "" {
+		claims["scope"] = opts.Scope
+	}
+	if opts.User != nil {
+		claims["email_verified"] = opts.User.EmailVerified
+		claims["phone_verified"] = opts.User.PhoneVerified
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 	tokenString, err := token.SignedString(key)
@@ -54,7 +81,104 @@ func (s *JWTService) GenerateToken(userID string) (string, int64, error) {
 	return tokenString, expiresAt.Unix(), nil
 }
 
+// ValidateToken проверяет access-токен. Одноразовые тикеты (см. GenerateTicket) здесь не принимаются.
 func (s *JWTService) ValidateToken(tokenString string) (*jwt.MapClaims, error) {
+	claims, err := s.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, isTicket := (*claims)["purpose"]; isTicket {
+		return nil, model.ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// ticketReservedClaims claims, которые GenerateTicket выставляет сам; extra не может их подменить
+var ticketReservedClaims = []string{"sub", "purpose", "exp", "iat", "nbf", "iss", "aud", "jti"}
+
+// GenerateTicket подписывает короткоживущий тикет для одного конкретного действия.
+// Claim purpose отличает тикет от access-токена.
+func (s *JWTService) GenerateTicket(subject, purpose string, extra map[string]string, ttl time.Duration) (string, error) {
+	for k := range extra {
+		if slices.Contains(ticketReservedClaims, k) {
+			return "", fmt.Errorf("ticket claim %q is reserved", k)
+		}
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(s.privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":     subject,
+		"purpose": purpose,
+		"exp":     now.Add(ttl).Unix(),
+		"iat":     now.Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+}
+
+// ValidateTicket проверяет подпись, срок и назначение тикета
+func (s *JWTService) ValidateTicket(tokenString, purpose string) (*jwt.MapClaims, error) {
+	claims, err := s.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, _ := (*claims)["purpose"].(string); p != purpose {
+		return nil, model.ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+const auditCheckpointPurpose = "audit_checkpoint"
+
+// SignAuditCheckpoint подписывает отметку "событие seq журнала имело хеш hash". Срока действия у нее нет.
+func (s *JWTService) SignAuditCheckpoint(seq int64, hash string) (string, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(s.privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"purpose": auditCheckpointPurpose,
+		"seq":     seq,
+		"hash":    hash,
+		"iat":     time.Now().Unix(),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+}
+
+// VerifyAuditCheckpoint проверяет подпись отметки и возвращает подписанные seq и hash
+func (s *JWTService) VerifyAuditCheckpoint(signature string) (int64, string, error) {
+	claims, err := s.parse(signature)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if p, _ := (*claims)["purpose"].(string); p != auditCheckpointPurpose {
+		return 0, "", model.ErrInvalidToken
+	}
+	seq, ok := (*claims)["seq"].(float64)
+	if !ok {
+		return 0, "", model.ErrInvalidToken
+	}
+	hash, _ := (*claims)["hash"].(string)
+
+	return int64(seq), hash, nil
+}
+
+func (s *JWTService) parse(tokenString string) (*jwt.MapClaims, error) {
 	key, err := jwt.ParseRSAPublicKeyFromPEM(s.publicKey)
 	if err != nil {
 		return nil, err
@@ -63,7 +187,7 @@ func (s *JWTService) ValidateToken(tokenString string) (*jwt.MapClaims, error) {
 	claims := jwt.MapClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
 		return key, nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
 	if err != nil {
 		return nil, model.ErrInvalidToken
 	}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestJWTService(t *testing.T) (*JWTService, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &JWTService{
+		privateKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		publicKey:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+	}, key
+}
+
+func TestGenerateTicketReservedClaims(t *testing.T) {
+	s, _ := newTestJWTService(t)
+
+	ticket, err := s.GenerateTicket("user-1", "phone_verification", map[string]string{"phone": "+15550100"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := s.ValidateTicket(ticket, "phone_verification")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*claims)["sub"] != "user-1" || (*claims)["phone"] != "+15550100" {
+		t.Fatalf("unexpected claims %v", *claims)
+	}
+
+	for _, claim := range []string{"sub", "purpose", "exp", "iat", "nbf", "iss", "aud", "jti"} {
+		if _, err := s.GenerateTicket("user-1", "phone_verification", map[string]string{claim: "x"}, time.Minute); err == nil {
+			t.Errorf("extra claim %q must be rejected", claim)
+		}
+	}
+}
+
+func TestParseRejectsOtherAlgorithms(t *testing.T) {
+	s, key := newTestJWTService(t)
+	claims := jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Minute).Unix()}
+
+	rs256, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Тот же ключ, но другой алгоритм RSA
+	ps256, err := jwt.NewWithClaims(jwt.SigningMethodPS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Подмена алгоритма: открытый ключ в роли секрета HMAC
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.parse(rs256); err != nil {
+		t.Fatalf("RS256: %v", err)
+	}
+	for name, token := range map[string]string{"PS256": ps256, "HS256": hs256, "none": none} {
+		if _, err := s.parse(token); !errors.Is(err, model.ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
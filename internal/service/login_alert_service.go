@@ -0,0 +1,244 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginAlert сведения о входе, которые получает пользователь
+type LoginAlert struct {
+	Device     string // Браузер и система, см. util.DescribeUserAgent
+	Location   string // Примерное местоположение по IP
+	IP         string
+	Time       time.Time
+	RevokeLink string // Ссылка "это был не я"
+}
+
+// LoginAlertSender доставляет пользователю уведомление о входе.
+// sent=false — у пользователя нет подтвержденного канала связи.
+type LoginAlertSender interface {
+	SendLoginAlert(ctx context.Context, user *model.User, alert LoginAlert) (bool, error)
+}
+
+// MessageLoginAlertSender пишет на подтвержденный email, а если его нет — отправляет SMS
+// на подтвержденный телефон
+type MessageLoginAlertSender struct {
+	mailer Mailer
+	sms    SMSSender
+}
+
+func NewMessageLoginAlertSender(mailer Mailer, sms SMSSender) *MessageLoginAlertSender {
+	return &MessageLoginAlertSender{
+		mailer: mailer,
+		sms:    sms,
+	}
+}
+
+func (s *MessageLoginAlertSender) SendLoginAlert(ctx context.Context, user *model.User, alert LoginAlert) (bool, error) {
+	switch {
+	case user.EmailVerified:
+		return true, s.mailer.Send(ctx, EmailMessage{
+			To:      user.Email,
+			Subject: "New sign-in to your account",
+			Body: "Your account was just signed in to from a new device.\n\n" +
+				"Device:   " + alert.Device + "\n" +
+				"Location: " + alert.Location + "\n" +
+				"IP:       " + alert.IP + "\n" +
+				"Time:     " + alert.Time.UTC().Format("2006-01-02 15:04 MST") + "\n\n" +
+				"If this was you, no action is needed.\n\n" +
+				"If this wasn't you, open the link below. It signs out that device and every\n" +
+				"other session, and asks you to choose a new password:\n\n" +
+				alert.RevokeLink + "\n",
+		})
+	case user.PhoneVerified:
+		return true, s.sms.SendSMS(ctx, user.Phone, fmt.Sprintf(
+			"New sign-in to your account: %s, %s. Not you? %s",
+			alert.Device, alert.Location, alert.RevokeLink,
+		))
+	default:
+		return false, nil
+	}
+}
+
+// LoginAlertService уведомляет о входе с нового устройства и обрабатывает ответ "это был не я"
+type LoginAlertService struct {
+	userRepo        repository.UserRepository
+	sessionRepo     repository.SessionRepository
+	passwordService *PasswordService
+	auditService    *AuditService
+	sender          LoginAlertSender
+	geo             *util.GeoIPTable
+	redisClient     *redis.Client
+	baseURL         string
+}
+
+func NewLoginAlertService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	passwordService *PasswordService,
+	auditService *AuditService,
+	sender LoginAlertSender,
+	geo *util.GeoIPTable,
+	redisClient *redis.Client,
+	baseURL string,
+) *LoginAlertService {
+	return &LoginAlertService{
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		passwordService: passwordService,
+		auditService:    auditService,
+		sender:          sender,
+		geo:             geo,
+		redisClient:     redisClient,
+		baseURL:         strings.TrimRight(baseURL, "/"),
+	}
+}
+
+const (
+	loginAlertRevokePath = "/login/not-me"
+	loginAlertLinkTTL    = refreshTokenTTL // Пока жива сессия, ее можно отвергнуть
+	loginAlertTimeout    = 30 * time.Second
+)
+
+// loginAlertState хранится под хешем токена из ссылки
+type loginAlertState struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+// NotifyNewDevice фоново уведомляет пользователя о входе, создавшем session. Вход не ждет отправки.
+func (s *LoginAlertService) NotifyNewDevice(ctx context.Context, user *model.User, session *model.Session) {
+	ctx = context.WithoutCancel(ctx)
+	alertSession := *session
+
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, loginAlertTimeout)
+		defer cancel()
+
+		if err := s.notifyNewDevice(ctx, user, &alertSession); err != nil {
+			logger.Log.Warn("Failed to send new-device alert to user ", user.ID, ": ", err)
+		}
+	}()
+}
+
+func (s *LoginAlertService) notifyNewDevice(ctx context.Context, user *model.User, session *model.Session) error {
+	token, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(loginAlertState{
+		UserID:    user.ID,
+		SessionID: session.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.redisClient.Set(ctx, loginAlertKey(token), data, loginAlertLinkTTL).Err(); err != nil {
+		return err
+	}
+
+	location := s.geo.Lookup(session.IP)
+	if location == "" {
+		location = "Unknown location"
+	}
+
+	alert := LoginAlert{
+		Device:     util.DescribeUserAgent(session.UserAgent),
+		Location:   location,
+		IP:         session.IP,
+		Time:       session.CreatedAt,
+		RevokeLink: s.baseURL + loginAlertRevokePath + "?token=" + url.QueryEscape(token),
+	}
+
+	sent, err := s.sender.SendLoginAlert(ctx, user, alert)
+	if err != nil || !sent {
+		s.redisClient.Del(ctx, loginAlertKey(token))
+	}
+	if err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    user.ID,
+		ActorID:   user.ID,
+		SessionID: session.ID,
+		Type:      model.AuditNewDeviceLogin,
+		IP:        session.IP,
+		UserAgent: session.UserAgent,
+		Metadata: map[string]string{
+			"device":   alert.Device,
+			"location": alert.Location,
+			"notified": fmt.Sprint(sent),
+		},
+	})
+
+	return nil
+}
+
+// ReportNotMe обрабатывает ссылку "это был не я": завершает отвергнутую сессию и все остальные,
+// запрещает вход по текущему паролю и отправляет ссылку для установки нового
+func (s *LoginAlertService) ReportNotMe(ctx context.Context, token, userAgent, ip string) error {
+	if token == "" {
+		return model.ErrLoginAlertInvalid
+	}
+
+	// GetDel делает ссылку одноразовой даже при параллельных запросах
+	data, err := s.redisClient.GetDel(ctx, loginAlertKey(token)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return model.ErrLoginAlertInvalid
+		}
+		return err
+	}
+
+	var state loginAlertState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, state.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return model.ErrLoginAlertInvalid
+	}
+
+	if err := s.sessionRepo.RevokeSession(ctx, state.SessionID); err != nil && err != model.ErrSessionNotFound {
+		return err
+	}
+	if err := s.userRepo.RequirePasswordReset(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.RevokeAllUserSessions(ctx, user.ID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    user.ID,
+		ActorID:   user.ID,
+		SessionID: state.SessionID,
+		Type:      model.AuditLoginReportedNotMe,
+		IP:        ip,
+		UserAgent: userAgent,
+	})
+
+	return s.passwordService.SendResetLink(ctx, user)
+}
+
+func loginAlertKey(token string) string {
+	return "login_alert:" + util.HashToken(token)
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type MagicLinkService struct {
+	userRepo    repository.UserRepository
+	authService *AuthService
+	mailer      Mailer
+	redisClient *redis.Client
+	baseURL     string // Публичный адрес сервиса, из него собирается ссылка в письме
+}
+
+func NewMagicLinkService(
+	userRepo repository.UserRepository,
+	authService *AuthService,
+	mailer Mailer,
+	redisClient *redis.Client,
+	baseURL string,
+) *MagicLinkService {
+	return &MagicLinkService{
+		userRepo:    userRepo,
+		authService: authService,
+		mailer:      mailer,
+		redisClient: redisClient,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+	}
+}
+
+const (
+	magicLinkTTL         = 10 * time.Minute
+	maxMagicLinksPerMail = 3  // Писем на один адрес за magicLinkTTL
+	maxMagicLinksPerIP   = 10 // Запросов с одного IP за magicLinkTTL
+	magicLinkConsumePath = "/login/magic-link/consume"
+	magicLinkMailTimeout = 30 * time.Second
+)
+
+// magicLinkState хранится под хешем токена; сам токен есть только в письме
+type magicLinkState struct {
+	UserID    string `json:"user_id"`
+	NonceHash string `json:"nonce_hash"` // Хеш nonce из cookie устройства, запросившего ссылку
+}
+
+// RequestMagicLink отправляет ссылку для входа и возвращает nonce для cookie.
+// Поиск пользователя и отправка письма идут в фоне и для неизвестного адреса тоже: ответ
+// не отличается ни содержимым, ни временем, поэтому не раскрывает, есть ли аккаунт.
+func (s *MagicLinkService) RequestMagicLink(ctx context.Context, email, ip string) (string, error) {
+	normalizedIP, err := util.NormalizeIP(ip)
+	if err != nil {
+		return "", err
+	}
+
+	email, err = util.NormalizeEmail(email)
+	if err != nil {
+		return "", err
+	}
+
+	ipRequests, err := incrementWithTTL(ctx, s.redisClient, "magic_link_ip:"+normalizedIP, magicLinkTTL)
+	if err != nil {
+		return "", err
+	}
+	mailRequests, err := incrementWithTTL(ctx, s.redisClient, "magic_link_email:"+email, magicLinkTTL)
+	if err != nil {
+		return "", err
+	}
+	if ipRequests > maxMagicLinksPerIP || mailRequests > maxMagicLinksPerMail {
+		return "", model.ErrTooManyCodeRequests
+	}
+
+	nonce, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, magicLinkMailTimeout)
+		defer cancel()
+
+		if err := s.sendMagicLink(ctx, email, nonce); err != nil {
+			logger.Log.Warn("Failed to send magic link: ", err)
+		}
+	}()
+
+	return nonce, nil
+}
+
+// sendMagicLink создает ссылку, привязанную к nonce, и отправляет ее владельцу адреса, если он есть
+func (s *MagicLinkService) sendMagicLink(ctx context.Context, email, nonce string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	token, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(magicLinkState{
+		UserID:    user.ID,
+		NonceHash: util.HashToken(nonce),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.redisClient.Set(ctx, magicLinkKey(token), data, magicLinkTTL).Err(); err != nil {
+		return err
+	}
+
+	link := s.baseURL + magicLinkConsumePath + "?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(ctx, EmailMessage{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: "Use the link below to sign in. It expires in 10 minutes and works only once,\n" +
+			"in the same browser where you requested it.\n\n" +
+			link + "\n\n" +
+			"If you did not request this link, you can ignore this email.\n",
+	})
+	if err != nil {
+		s.redisClient.Del(ctx, magicLinkKey(token))
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeMagicLink обменивает ссылку на вход тем же путем, что и /login, включая второй фактор.
+// Ссылка без cookie запросившего устройства не погашается: почтовые сканеры, открывающие
+// ссылки заранее, не должны ее сжечь.
+func (s *MagicLinkService) ConsumeMagicLink(ctx context.Context, token, nonce, userAgent, ip string) (*model.LoginResult, error) {
+	if token == "" || nonce == "" {
+		return nil, model.ErrMagicLinkInvalid
+	}
+
+	data, err := s.redisClient.Get(ctx, magicLinkKey(token)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, model.ErrMagicLinkInvalid
+		}
+		return nil, err
+	}
+
+	var state magicLinkState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(util.HashToken(nonce)), []byte(state.NonceHash)) != 1 {
+		return nil, model.ErrMagicLinkInvalid
+	}
+
+	// GetDel делает ссылку одноразовой даже при параллельных запросах
+	if err := s.redisClient.GetDel(ctx, magicLinkKey(token)).Err(); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, model.ErrMagicLinkInvalid
+		}
+		return nil, err
+	}
+
+	return s.authService.CompleteLogin(ctx, state.UserID, userAgent, ip)
+}
+
+func magicLinkKey(token string) string {
+	return "magic_link:" + util.HashToken(token)
+}
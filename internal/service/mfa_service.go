@@ -0,0 +1,435 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/ratelimit"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// mfaStore настройки второго фактора; в работе — repository.MFARepository
+type mfaStore interface {
+	GetUserMFA(ctx context.Context, userID string) (*model.UserMFA, error)
+	SaveTOTPSecret(ctx context.Context, userID string, encryptedSecret []byte) error
+	ConfirmTOTP(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error
+	AdvanceTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	DisableTOTP(ctx context.Context, userID string) error
+	SetSMSEnabled(ctx context.Context, userID string, enabled bool) error
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	CountUnusedRecoveryCodes(ctx context.Context, userID string) (int, error)
+}
+
+type MFAService struct {
+	mfaRepo       mfaStore
+	userRepo      webAuthnUserStore
+	verifier      VerificationProvider
+	limiter       ratelimit.Limiter // Неудачные подтверждения изменений второго фактора
+	encryptionKey []byte
+	issuer        string
+}
+
+// NewMFAService принимает ключ шифрования TOTP-секретов в base64 (32 байта).
+// Без ключа сервис работает, но включить второй фактор нельзя.
+func NewMFAService(
+	mfaRepo repository.MFARepository,
+	userRepo repository.UserRepository,
+	verifier VerificationProvider,
+	limiter ratelimit.Limiter,
+	encryptionKey string,
+	issuer string,
+) (*MFAService, error) {
+	var key []byte
+	if encryptionKey != "" {
+		var err error
+		key, err = base64.StdEncoding.DecodeString(encryptionKey)
+		if err != nil {
+			return nil, errors.New("MFA encryption key must be base64-encoded")
+		}
+		if len(key) != 32 {
+			return nil, errors.New("MFA encryption key must be 32 bytes")
+		}
+	}
+
+	return &MFAService{
+		mfaRepo:       &mfaRepo,
+		userRepo:      &userRepo,
+		verifier:      verifier,
+		limiter:       limiter,
+		encryptionKey: key,
+		issuer:        issuer,
+	}, nil
+}
+
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789" // Без похожих символов: i, l, o, 0, 1
+)
+
+// Отключение второго фактора и новые коды восстановления требуют пароль и код. Неудачи
+// ограничены: иначе украденный токен доступа позволяет перебрать все миллион TOTP-кодов.
+var mfaStepUpFailures = ratelimit.Policy{
+	Name:      "mfa_step_up_failures",
+	Algorithm: ratelimit.SlidingWindow,
+	Limit:     5,
+	Window:    15 * time.Minute,
+}
+
+// MFAStatus состояние второго фактора для самого пользователя
+type MFAStatus struct {
+	TOTPEnabled            bool `json:"totp_enabled"`
+	SMSEnabled             bool `json:"sms_enabled"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+func (s *MFAService) Status(ctx context.Context, userID string) (*MFAStatus, error) {
+	mfa, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MFAStatus{
+		TOTPEnabled: mfa.TOTPEnabled(),
+		SMSEnabled:  mfa.SMSFactorEnabled(),
+	}
+	if status.TOTPEnabled {
+		status.RecoveryCodesRemaining, err = s.mfaRepo.CountUnusedRecoveryCodes(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
+
+// Methods возвращает способы подтверждения второго фактора, доступные пользователю.
+// Пустой список означает, что второй фактор не включен.
+func (s *MFAService) Methods(ctx context.Context, userID string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []string
+	if mfa.TOTPEnabled() {
+		methods = append(methods, model.MFAMethodTOTP, model.MFAMethodRecoveryCode)
+	}
+	if mfa.SMSFactorEnabled() {
+		methods = append(methods, model.MFAMethodSMS)
+	}
+	return methods, nil
+}
+
+// EnableSMS включает SMS-код вторым фактором. Телефон должен быть подтвержден через /verify-phone.
+func (s *MFAService) EnableSMS(ctx context.Context, userID string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return model.ErrUserNotFound
+	}
+	if user.Phone == "" || !user.PhoneVerified {
+		return model.ErrPhoneNotVerified
+	}
+
+	return s.mfaRepo.SetSMSEnabled(ctx, userID, true)
+}
+
+// DisableSMS отключает SMS-фактор после проверки пароля и кода любым включенным способом
+func (s *MFAService) DisableSMS(ctx context.Context, userID string, stepUp model.StepUp) error {
+	if err := s.checkStepUp(ctx, userID, stepUp); err != nil {
+		return err
+	}
+	return s.mfaRepo.SetSMSEnabled(ctx, userID, false)
+}
+
+// SendSMSCode отправляет код на подтвержденный телефон пользователя
+func (s *MFAService) SendSMSCode(ctx context.Context, userID string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return model.ErrUserNotFound
+	}
+	if user.Phone == "" || !user.PhoneVerified {
+		return model.ErrPhoneNotVerified
+	}
+
+	return s.verifier.SendCode(ctx, user.Phone, "sms")
+}
+
+// EnrollTOTP начинает подключение TOTP: генерирует секрет, который станет активным после ConfirmTOTP
+func (s *MFAService) EnrollTOTP(ctx context.Context, userID string) (*model.TOTPEnrollment, error) {
+	if s.encryptionKey == nil {
+		return nil, model.ErrMFANotConfigured
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, model.ErrUserNotFound
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := util.Encrypt(s.encryptionKey, []byte(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SaveTOTPSecret(ctx, userID, encrypted); err != nil {
+		return nil, err
+	}
+
+	return &model.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: util.TOTPProvisioningURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP включает TOTP после проверки первого кода и возвращает коды восстановления.
+// Коды показываются один раз, в базе хранятся только их HMAC.
+func (s *MFAService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || mfa.TOTPSecret == nil {
+		return nil, model.ErrMFANotEnrolled
+	}
+	if mfa.TOTPEnabled() {
+		return nil, model.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := s.decryptSecret(mfa)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := util.ValidateTOTPCode(secret, code, time.Now())
+	if !ok {
+		return nil, model.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.ConfirmTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP отключает TOTP после проверки пароля и действующего кода или кода восстановления
+func (s *MFAService) DisableTOTP(ctx context.Context, userID string, stepUp model.StepUp) error {
+	if err := s.checkStepUp(ctx, userID, stepUp); err != nil {
+		return err
+	}
+	return s.mfaRepo.DisableTOTP(ctx, userID)
+}
+
+// RegenerateRecoveryCodes заменяет все коды восстановления новыми (нужны пароль и действующий TOTP-код)
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID string, stepUp model.StepUp) ([]string, error) {
+	stepUp.MFAMethod = model.MFAMethodTOTP
+	if err := s.checkStepUp(ctx, userID, stepUp); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Verify проверяет код второго фактора выбранным способом. Каждый код принимается только один раз.
+func (s *MFAService) Verify(ctx context.Context, userID, method, code string) error {
+	mfa, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	switch method {
+	case model.MFAMethodTOTP:
+		if !mfa.TOTPEnabled() {
+			return model.ErrMFANotEnabled
+		}
+
+		secret, err := s.decryptSecret(mfa)
+		if err != nil {
+			return err
+		}
+
+		step, ok := util.ValidateTOTPCode(secret, code, time.Now())
+		if !ok || step <= mfa.TOTPLastStep {
+			return model.ErrInvalidMFACode
+		}
+
+		advanced, err := s.mfaRepo.AdvanceTOTPStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !advanced {
+			return model.ErrInvalidMFACode
+		}
+		return nil
+
+	case model.MFAMethodRecoveryCode:
+		if !mfa.TOTPEnabled() {
+			return model.ErrMFANotEnabled
+		}
+
+		if s.encryptionKey == nil {
+			return model.ErrMFANotConfigured
+		}
+		used, err := s.mfaRepo.UseRecoveryCode(ctx, userID, s.recoveryCodeHash(userID, code))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return model.ErrInvalidMFACode
+		}
+		return nil
+
+	case model.MFAMethodSMS:
+		if !mfa.SMSFactorEnabled() {
+			return model.ErrMFANotEnabled
+		}
+
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.Phone == "" {
+			return model.ErrPhoneNotVerified
+		}
+
+		approved, err := s.verifier.CheckCode(ctx, user.Phone, code)
+		if err != nil {
+			return err
+		}
+		if !approved {
+			return model.ErrInvalidMFACode
+		}
+		return nil
+
+	default:
+		return model.ErrInvalidMFACode
+	}
+}
+
+// checkStepUp проверяет пароль и код второго фактора; неудачи учитываются по пользователю
+func (s *MFAService) checkStepUp(ctx context.Context, userID string, stepUp model.StepUp) error {
+	result, err := s.limiter.Peek(ctx, mfaStepUpFailures, userID)
+	if err != nil {
+		return err
+	}
+	if !result.Allowed {
+		return model.ErrTooManyAttempts
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return model.ErrUserNotFound
+	}
+	if stepUp.Password == "" || stepUp.MFACode == "" {
+		return model.ErrStepUpRequired
+	}
+
+	var failure error
+	if !util.CheckPasswordHash(stepUp.Password, user.Password) {
+		failure = model.ErrInvalidCredentials
+	} else if err := s.Verify(ctx, userID, stepUp.MFAMethod, stepUp.MFACode); err != nil {
+		if !errors.Is(err, model.ErrInvalidMFACode) {
+			return err
+		}
+		failure = err
+	}
+	if failure == nil {
+		return nil
+	}
+
+	if _, err := s.limiter.Allow(ctx, mfaStepUpFailures, userID); err != nil {
+		return err
+	}
+	return failure
+}
+
+func (s *MFAService) decryptSecret(mfa *model.UserMFA) (string, error) {
+	if s.encryptionKey == nil {
+		return "", model.ErrMFANotConfigured
+	}
+
+	secret, err := util.Decrypt(s.encryptionKey, mfa.TOTPSecret)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// generateRecoveryCodes возвращает коды вида xxxxx-xxxxx и их хеши
+func (s *MFAService) generateRecoveryCodes(userID string) ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, recoveryCodeLength)
+		for j := range raw {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(recoveryCodeAlphabet))))
+			if err != nil {
+				return nil, nil, err
+			}
+			raw[j] = recoveryCodeAlphabet[n.Int64()]
+		}
+
+		code := string(raw[:recoveryCodeLength/2]) + "-" + string(raw[recoveryCodeLength/2:])
+		codes = append(codes, code)
+		hashes = append(hashes, s.recoveryCodeHash(userID, code))
+	}
+
+	return codes, hashes, nil
+}
+
+// recoveryCodeHash в коде около 50 бит: простой SHA-256 из копии базы перебирается на GPU.
+// HMAC ключом шифрования TOTP-секретов без самого ключа не перебрать; userID в сообщении
+// делает хеш одного и того же кода разным у разных пользователей.
+func (s *MFAService) recoveryCodeHash(userID, code string) string {
+	return util.HashTokenHMAC(s.encryptionKey, "mfa_recovery_code:"+userID+":"+normalizeRecoveryCode(code))
+}
+
+// normalizeRecoveryCode прощает регистр, пробелы и дефисы при вводе
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
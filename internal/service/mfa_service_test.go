@@ -0,0 +1,338 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/ratelimit"
+	"authorization_authentication/internal/util"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryMFA настройки второго фактора одного пользователя в памяти
+type memoryMFA struct {
+	mu            sync.Mutex
+	mfa           *model.UserMFA
+	recoveryCodes map[string]bool // Хеш -> использован
+}
+
+func (m *memoryMFA) GetUserMFA(context.Context, string) (*model.UserMFA, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.mfa == nil {
+		return nil, nil
+	}
+	mfa := *m.mfa
+	return &mfa, nil
+}
+
+func (m *memoryMFA) SaveTOTPSecret(_ context.Context, userID string, encryptedSecret []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mfa = &model.UserMFA{UserID: userID, TOTPSecret: encryptedSecret}
+	return nil
+}
+
+func (m *memoryMFA) ConfirmTOTP(_ context.Context, _ string, step int64, recoveryCodeHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.mfa.TOTPConfirmedAt = &now
+	m.mfa.TOTPLastStep = step
+	m.replaceRecoveryCodes(recoveryCodeHashes)
+	return nil
+}
+
+func (m *memoryMFA) AdvanceTOTPStep(_ context.Context, _ string, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if step <= m.mfa.TOTPLastStep {
+		return false, nil
+	}
+	m.mfa.TOTPLastStep = step
+	return true, nil
+}
+
+func (m *memoryMFA) DisableTOTP(context.Context, string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mfa.TOTPSecret = nil
+	m.mfa.TOTPConfirmedAt = nil
+	m.recoveryCodes = nil
+	return nil
+}
+
+func (m *memoryMFA) SetSMSEnabled(_ context.Context, _ string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mfa.SMSEnabled = enabled
+	return nil
+}
+
+func (m *memoryMFA) UseRecoveryCode(_ context.Context, _, codeHash string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if used, ok := m.recoveryCodes[codeHash]; !ok || used {
+		return false, nil
+	}
+	m.recoveryCodes[codeHash] = true
+	return true, nil
+}
+
+func (m *memoryMFA) ReplaceRecoveryCodes(_ context.Context, _ string, codeHashes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.replaceRecoveryCodes(codeHashes)
+	return nil
+}
+
+func (m *memoryMFA) replaceRecoveryCodes(codeHashes []string) {
+	m.recoveryCodes = make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		m.recoveryCodes[hash] = false
+	}
+}
+
+func (m *memoryMFA) CountUnusedRecoveryCodes(context.Context, string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, used := range m.recoveryCodes {
+		if !used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// totpCode код для момента at, как его показало бы приложение-аутентификатор
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/int64(util.TOTPPeriod.Seconds())))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+type mfaFixture struct {
+	service       *MFAService
+	store         *memoryMFA
+	secret        string
+	confirmedAt   time.Time // Шаг этого момента уже использован при подключении
+	recoveryCodes []string
+}
+
+// newMFAFixture пользователь с подключенным TOTP
+func newMFAFixture(t *testing.T) *mfaFixture {
+	t.Helper()
+
+	hash, err := util.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &mfaFixture{store: &memoryMFA{}}
+	f.service = &MFAService{
+		mfaRepo:       f.store,
+		userRepo:      staticUsers{testUserID: {ID: testUserID, Email: "user@example.com", Password: hash}},
+		limiter:       ratelimit.NewMemoryLimiter(nil),
+		encryptionKey: make([]byte, 32),
+		issuer:        "Auth",
+	}
+
+	ctx := context.Background()
+	enrollment, err := f.service.EnrollTOTP(ctx, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.secret = enrollment.Secret
+	f.confirmedAt = time.Now()
+	f.recoveryCodes, err = f.service.ConfirmTOTP(ctx, testUserID, totpCode(t, f.secret, f.confirmedAt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestMFAStepUp(t *testing.T) {
+	tests := []struct {
+		name   string
+		stepUp func(f *mfaFixture) model.StepUp
+		want   error
+	}{
+		{
+			name:   "missing password",
+			stepUp: func(f *mfaFixture) model.StepUp { return model.StepUp{MFACode: totpCode(t, f.secret, time.Now())} },
+			want:   model.ErrStepUpRequired,
+		},
+		{
+			name:   "missing code",
+			stepUp: func(f *mfaFixture) model.StepUp { return model.StepUp{Password: testPassword} },
+			want:   model.ErrStepUpRequired,
+		},
+		{
+			name: "wrong password",
+			stepUp: func(f *mfaFixture) model.StepUp {
+				return model.StepUp{Password: "wrong", MFACode: totpCode(t, f.secret, time.Now())}
+			},
+			want: model.ErrInvalidCredentials,
+		},
+		{
+			name:   "wrong code",
+			stepUp: func(f *mfaFixture) model.StepUp { return model.StepUp{Password: testPassword, MFACode: "000000"} },
+			want:   model.ErrInvalidMFACode,
+		},
+		{
+			name: "reused step",
+			stepUp: func(f *mfaFixture) model.StepUp {
+				return model.StepUp{Password: testPassword, MFACode: totpCode(t, f.secret, f.confirmedAt)}
+			},
+			want: model.ErrInvalidMFACode,
+		},
+		{
+			name: "password and code",
+			stepUp: func(f *mfaFixture) model.StepUp {
+				return model.StepUp{Password: testPassword, MFACode: totpCode(t, f.secret, f.confirmedAt.Add(util.TOTPPeriod))}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMFAFixture(t)
+
+			codes, err := f.service.RegenerateRecoveryCodes(context.Background(), testUserID, tt.stepUp(f))
+			if err != tt.want {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && (len(codes) != recoveryCodeCount || slices.Equal(codes, f.recoveryCodes)) {
+				t.Fatalf("expected fresh recovery codes, got %v", codes)
+			}
+		})
+	}
+}
+
+func TestMFAStepUpFailuresAreLimited(t *testing.T) {
+	f := newMFAFixture(t)
+	ctx := context.Background()
+
+	for i := 0; i < mfaStepUpFailures.Limit; i++ {
+		stepUp := model.StepUp{Password: testPassword, MFAMethod: model.MFAMethodTOTP, MFACode: fmt.Sprintf("%06d", i)}
+		if err := f.service.DisableTOTP(ctx, testUserID, stepUp); err != model.ErrInvalidMFACode {
+			t.Fatalf("attempt %d: err = %v", i, err)
+		}
+	}
+
+	// Дальше отказ даже с верным кодом, и код не тратится
+	valid := model.StepUp{Password: testPassword, MFAMethod: model.MFAMethodTOTP, MFACode: totpCode(t, f.secret, time.Now())}
+	if err := f.service.DisableTOTP(ctx, testUserID, valid); err != model.ErrTooManyAttempts {
+		t.Fatalf("err = %v, want ErrTooManyAttempts", err)
+	}
+	if mfa, _ := f.store.GetUserMFA(ctx, testUserID); !mfa.TOTPEnabled() {
+		t.Fatal("TOTP must stay enabled")
+	}
+}
+
+func TestMFARecoveryCodes(t *testing.T) {
+	f := newMFAFixture(t)
+	ctx := context.Background()
+
+	code := f.recoveryCodes[0]
+	// Регистр, пробелы и дефисы при вводе не важны
+	typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+	if err := f.service.Verify(ctx, testUserID, model.MFAMethodRecoveryCode, typed); err != nil {
+		t.Fatalf("recovery code rejected: %v", err)
+	}
+	if err := f.service.Verify(ctx, testUserID, model.MFAMethodRecoveryCode, code); err != model.ErrInvalidMFACode {
+		t.Fatalf("reused recovery code: err = %v", err)
+	}
+	if err := f.service.Verify(ctx, testUserID, model.MFAMethodRecoveryCode, "abcde-fghjk"); err != model.ErrInvalidMFACode {
+		t.Fatalf("unknown recovery code: err = %v", err)
+	}
+
+	status, err := f.service.Status(ctx, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.RecoveryCodesRemaining != recoveryCodeCount-1 {
+		t.Fatalf("remaining = %d", status.RecoveryCodesRemaining)
+	}
+
+	// Коду восстановления тоже нужен пароль, чтобы отключить TOTP
+	stepUp := model.StepUp{Password: testPassword, MFAMethod: model.MFAMethodRecoveryCode, MFACode: f.recoveryCodes[1]}
+	if err := f.service.DisableTOTP(ctx, testUserID, stepUp); err != nil {
+		t.Fatal(err)
+	}
+	if mfa, _ := f.store.GetUserMFA(ctx, testUserID); mfa.TOTPEnabled() {
+		t.Fatal("TOTP must be disabled")
+	}
+}
+
+func TestMFARecoveryCodeHashIsKeyed(t *testing.T) {
+	f := newMFAFixture(t)
+	code := normalizeRecoveryCode(f.recoveryCodes[0])
+
+	if _, ok := f.store.recoveryCodes[util.HashToken(code)]; ok {
+		t.Fatal("recovery code stored as plain SHA-256")
+	}
+	if _, ok := f.store.recoveryCodes[f.service.recoveryCodeHash(testUserID, code)]; !ok {
+		t.Fatal("recovery code hash not found")
+	}
+	if f.service.recoveryCodeHash(testUserID, code) == f.service.recoveryCodeHash("another-user", code) {
+		t.Fatal("hash must depend on the user")
+	}
+
+	// С другим ключом хеши не сходятся
+	other := *f.service
+	other.encryptionKey = []byte(strings.Repeat("k", 32))
+	if err := other.Verify(context.Background(), testUserID, model.MFAMethodRecoveryCode, f.recoveryCodes[0]); err != model.ErrInvalidMFACode {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestMFAVerifyRejectsReusedTOTPStep(t *testing.T) {
+	f := newMFAFixture(t)
+	ctx := context.Background()
+	next := totpCode(t, f.secret, f.confirmedAt.Add(util.TOTPPeriod))
+
+	tests := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"code used to confirm", totpCode(t, f.secret, f.confirmedAt), model.ErrInvalidMFACode},
+		{"next step", next, nil},
+		{"same step again", next, model.ErrInvalidMFACode},
+		{"earlier step", totpCode(t, f.secret, f.confirmedAt), model.ErrInvalidMFACode},
+	}
+	// Шаги проверяются по порядку: каждый успешный код сдвигает TOTPLastStep
+	for _, tt := range tests {
+		if err := f.service.Verify(ctx, testUserID, model.MFAMethodTOTP, tt.code); err != tt.want {
+			t.Fatalf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,590 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type OAuthService struct {
+	clientRepo  repository.OAuthClientRepository
+	authService *AuthService
+	jwtService  *JWTService
+	redisClient *redis.Client
+}
+
+func NewOAuthService(
+	clientRepo repository.OAuthClientRepository,
+	authService *AuthService,
+	jwtService *JWTService,
+	redisClient *redis.Client,
+) *OAuthService {
+	return &OAuthService{
+		clientRepo:  clientRepo,
+		authService: authService,
+		jwtService:  jwtService,
+		redisClient: redisClient,
+	}
+}
+
+const (
+	authorizationRequestTTL = 10 * time.Minute // Сколько живет открытая страница входа
+	authorizationCodeTTL    = 1 * time.Minute
+	minCodeVerifierLength   = 43
+	maxCodeVerifierLength   = 128
+
+	clientCredentialsTokenTTL    = 5 * time.Minute // Машинные токены короткоживущие: клиент всегда может получить новый
+	DefaultSecretRotationOverlap = 24 * time.Hour
+	maxSecretRotationOverlap     = 30 * 24 * time.Hour
+)
+
+var supportedGrantTypes = []string{
+	model.GrantAuthorizationCode,
+	model.GrantRefreshToken,
+	model.GrantClientCredentials,
+}
+
+// NewOAuthClient собирает нового клиента приложения. Секрет конфиденциальному клиенту
+// выдается отдельно через NewClientSecret.
+func NewOAuthClient(name string, redirectURIs, grantTypes, scopes []string, confidential bool) (*model.OAuthClient, error) {
+	if name == "" {
+		return nil, errors.New("client name is required")
+	}
+
+	for _, grantType := range grantTypes {
+		if !slices.Contains(supportedGrantTypes, grantType) {
+			return nil, errors.New("unsupported grant type: " + grantType)
+		}
+	}
+	if slices.Contains(grantTypes, model.GrantClientCredentials) && !confidential {
+		return nil, errors.New("client_credentials grant requires a confidential client")
+	}
+	if slices.Contains(grantTypes, model.GrantAuthorizationCode) && len(redirectURIs) == 0 {
+		return nil, errors.New("authorization_code grant requires at least one redirect URI")
+	}
+
+	for _, uri := range redirectURIs {
+		parsed, err := url.Parse(uri)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			return nil, errors.New("invalid redirect URI: " + uri)
+		}
+	}
+
+	now := time.Now().UTC()
+	return &model.OAuthClient{
+		ID:           uuid.NewString(),
+		Name:         name,
+		Confidential: confidential,
+		RedirectURIs: redirectURIs,
+		GrantTypes:   grantTypes,
+		Scopes:       scopes,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}, nil
+}
+
+// NewServiceAccount собирает машинного клиента: только client_credentials, без redirect URI
+func NewServiceAccount(name string, scopes []string) (*model.OAuthClient, error) {
+	client, err := NewOAuthClient(name, nil, []string{model.GrantClientCredentials}, scopes, true)
+	if err != nil {
+		return nil, err
+	}
+	client.ServiceAccount = true
+	return client, nil
+}
+
+// NewClientSecret генерирует секрет клиента. Открытый секрет возвращается один раз,
+// в базе хранится только его bcrypt-хеш.
+func NewClientSecret(clientID string) (*model.OAuthClientSecret, string, error) {
+	plain, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	hash, err := util.HashPassword(plain)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &model.OAuthClientSecret{
+		ID:         uuid.NewString(),
+		ClientID:   clientID,
+		SecretHash: hash,
+		CreatedAt:  time.Now().UTC(),
+	}, plain, nil
+}
+
+// AuthenticateClient проверяет client_id и секрет. Публичный клиент не должен присылать секрет.
+// Конфиденциальный клиент может предъявить любой из действующих секретов (во время ротации их два).
+func (s *OAuthService) AuthenticateClient(ctx context.Context, clientID, clientSecret string) (*model.OAuthClient, error) {
+	invalidClient := model.NewOAuthError(model.OAuthInvalidClient, "Client authentication failed")
+
+	client, err := s.clientRepo.GetClientByID(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, model.ErrClientNotFound) {
+			return nil, invalidClient
+		}
+		return nil, err
+	}
+
+	if !client.IsConfidential() {
+		if clientSecret != "" {
+			return nil, invalidClient
+		}
+		return client, nil
+	}
+
+	if clientSecret == "" {
+		return nil, invalidClient
+	}
+
+	secrets, err := s.clientRepo.GetActiveSecrets(ctx, client.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, secret := range secrets {
+		if util.CheckPasswordHash(clientSecret, secret.SecretHash) {
+			return client, nil
+		}
+	}
+
+	return nil, invalidClient
+}
+
+// RotateClientSecret выпускает новый секрет. Прежние секреты продолжают работать еще overlap,
+// чтобы все экземпляры клиента успели перейти на новый. Возвращает новый секрет и срок действия старых.
+func (s *OAuthService) RotateClientSecret(ctx context.Context, client *model.OAuthClient, overlap time.Duration) (string, time.Time, error) {
+	if !client.IsConfidential() {
+		return "", time.Time{}, model.NewOAuthError(model.OAuthUnauthorizedClient, "Public clients have no secret to rotate")
+	}
+	if overlap < 0 || overlap > maxSecretRotationOverlap {
+		return "", time.Time{}, model.NewOAuthError(model.OAuthInvalidRequest, "Invalid rotation overlap")
+	}
+
+	secret, plain, err := NewClientSecret(client.ID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	oldExpiresAt := secret.CreatedAt.Add(overlap)
+	if err := s.clientRepo.RotateSecret(ctx, secret, oldExpiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return plain, oldExpiresAt, nil
+}
+
+// StartCleanupRoutine периодически удаляет истекшие после ротации секреты
+func (s *OAuthService) StartCleanupRoutine(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.clientRepo.DeleteExpiredSecrets(ctx, time.Now()); err != nil {
+					logger.Log.Warn("Failed to delete expired client secrets: ", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// ValidateAuthorizationRequest проверяет параметры /authorize.
+// Пока client_id и redirect_uri не проверены, возвращаемый запрос равен nil:
+// на непроверенный redirect_uri перенаправлять пользователя нельзя (RFC 6749, 4.1.2.1).
+func (s *OAuthService) ValidateAuthorizationRequest(ctx context.Context, params url.Values) (*model.AuthorizationRequest, *model.OAuthClient, error) {
+	client, err := s.clientRepo.GetClientByID(ctx, params.Get("client_id"))
+	if err != nil {
+		if errors.Is(err, model.ErrClientNotFound) {
+			return nil, nil, model.NewOAuthError(model.OAuthInvalidRequest, "Unknown client_id")
+		}
+		return nil, nil, err
+	}
+
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !client.AllowsRedirectURI(redirectURI) {
+		return nil, client, model.NewOAuthError(model.OAuthInvalidRequest, "Invalid redirect_uri")
+	}
+
+	req := &model.AuthorizationRequest{
+		ClientID:            client.ID,
+		RedirectURI:         redirectURI,
+		RedirectURISent:     params.Get("redirect_uri") != "",
+		Scope:               params.Get("scope"),
+		State:               params.Get("state"),
+		CodeChallenge:       params.Get("code_challenge"),
+		CodeChallengeMethod: params.Get("code_challenge_method"),
+	}
+
+	if params.Get("response_type") != "code" {
+		return req, client, model.NewOAuthError(model.OAuthUnsupportedResponseType, "Only response_type=code is supported")
+	}
+	if !client.AllowsGrant(model.GrantAuthorizationCode) {
+		return req, client, model.NewOAuthError(model.OAuthUnauthorizedClient, "Client is not allowed to use authorization code grant")
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != model.PKCEMethodS256 {
+		return req, client, model.NewOAuthError(model.OAuthInvalidRequest, "PKCE with code_challenge_method=S256 is required")
+	}
+
+	scopes := model.ParseScope(req.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	if !client.AllowsScope(scopes) {
+		return req, client, model.NewOAuthError(model.OAuthInvalidScope, "Requested scope is not allowed")
+	}
+	req.Scope = model.JoinScope(scopes)
+
+	return req, client, nil
+}
+
+// SaveAuthorizationRequest запоминает проверенный запрос на время показа страницы входа.
+// Его идентификатор передается в форме вместо исходных параметров, чтобы их нельзя было подменить.
+func (s *OAuthService) SaveAuthorizationRequest(ctx context.Context, req *model.AuthorizationRequest) (string, error) {
+	requestID, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.redisClient.Set(ctx, authorizationRequestKey(requestID), data, authorizationRequestTTL).Err(); err != nil {
+		return "", err
+	}
+
+	return requestID, nil
+}
+
+func (s *OAuthService) GetAuthorizationRequest(ctx context.Context, requestID string) (*model.AuthorizationRequest, *model.OAuthClient, error) {
+	data, err := s.redisClient.Get(ctx, authorizationRequestKey(requestID)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil, model.NewOAuthError(model.OAuthInvalidRequest, "Authorization request expired, please start again")
+		}
+		return nil, nil, err
+	}
+
+	var req model.AuthorizationRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, nil, err
+	}
+
+	client, err := s.clientRepo.GetClientByID(ctx, req.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &req, client, nil
+}
+
+// Approve аутентифицирует пользователя и выдает код авторизации.
+// Возвращает redirect_uri клиента с кодом и state либо, если включен второй фактор,
+// челлендж, который завершается через ApproveMFA.
+func (s *OAuthService) Approve(ctx context.Context, requestID, email, password, captchaToken, userAgent, ip string) (string, *model.MFAChallenge, error) {
+	req, _, err := s.GetAuthorizationRequest(ctx, requestID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	user, err := s.authService.AuthenticateUser(ctx, email, password, captchaToken, ip)
+	if err != nil {
+		return "", nil, err
+	}
+
+	challenge, err := s.authService.BeginMFAChallenge(ctx, user.ID, userAgent, ip)
+	if err != nil {
+		return "", nil, err
+	}
+	if challenge != nil {
+		return "", challenge, nil
+	}
+
+	redirectURL, err := s.issueAuthorizationCode(ctx, requestID, req, user.ID, userAgent, ip)
+	return redirectURL, nil, err
+}
+
+// ApproveMFA завершает вход вторым фактором и выдает код авторизации
+func (s *OAuthService) ApproveMFA(ctx context.Context, requestID, mfaToken, method, code, userAgent, ip string) (string, error) {
+	req, _, err := s.GetAuthorizationRequest(ctx, requestID)
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := s.authService.VerifyMFAChallenge(ctx, mfaToken, method, code)
+	if err != nil {
+		return "", err
+	}
+
+	return s.issueAuthorizationCode(ctx, requestID, req, userID, userAgent, ip)
+}
+
+// SendMFASMS отправляет SMS-код для второго шага входа на странице авторизации
+func (s *OAuthService) SendMFASMS(ctx context.Context, mfaToken string) error {
+	return s.authService.SendMFASMS(ctx, mfaToken)
+}
+
+// Deny завершает запрос отказом пользователя (access_denied)
+func (s *OAuthService) Deny(ctx context.Context, requestID string) (string, error) {
+	req, _, err := s.GetAuthorizationRequest(ctx, requestID)
+	if err != nil {
+		return "", err
+	}
+
+	s.redisClient.Del(ctx, authorizationRequestKey(requestID))
+
+	return AuthorizationErrorRedirect(req, model.NewOAuthError(model.OAuthAccessDenied, "The user denied the request")), nil
+}
+
+func (s *OAuthService) issueAuthorizationCode(ctx context.Context, requestID string, req *model.AuthorizationRequest, userID, userAgent, ip string) (string, error) {
+	code, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	authCode := &model.AuthorizationCode{
+		ClientID:            req.ClientID,
+		UserID:              userID,
+		RedirectURI:         req.RedirectURI,
+		RedirectURISent:     req.RedirectURISent,
+		Scope:               req.Scope,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		UserAgent:           userAgent,
+		IP:                  ip,
+		CreatedAt:           time.Now().UTC(),
+	}
+
+	data, err := json.Marshal(authCode)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.redisClient.Set(ctx, authorizationCodeKey(code), data, authorizationCodeTTL).Err(); err != nil {
+		return "", err
+	}
+
+	// Запрос одноразовый: повторная отправка формы не выдаст второй код
+	s.redisClient.Del(ctx, authorizationRequestKey(requestID))
+
+	query := url.Values{}
+	query.Set("code", code)
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+
+	return appendQuery(req.RedirectURI, query), nil
+}
+
+// AuthorizationErrorRedirect строит redirect_uri с ошибкой авторизации (RFC 6749, 4.1.2.1)
+func AuthorizationErrorRedirect(req *model.AuthorizationRequest, oauthErr *model.OAuthError) string {
+	query := url.Values{}
+	query.Set("error", oauthErr.Code)
+	if oauthErr.Description != "" {
+		query.Set("error_description", oauthErr.Description)
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+
+	return appendQuery(req.RedirectURI, query)
+}
+
+// Token обрабатывает запрос к /token от уже аутентифицированного клиента
+func (s *OAuthService) Token(ctx context.Context, client *model.OAuthClient, form url.Values, userAgent, ip string) (*model.AuthTokens, error) {
+	grantType := form.Get("grant_type")
+	if grantType == "" {
+		return nil, model.NewOAuthError(model.OAuthInvalidRequest, "grant_type is required")
+	}
+	if !slices.Contains(supportedGrantTypes, grantType) {
+		return nil, model.NewOAuthError(model.OAuthUnsupportedGrantType, "Unsupported grant_type")
+	}
+	if !client.AllowsGrant(grantType) || (client.ServiceAccount && grantType != model.GrantClientCredentials) {
+		return nil, model.NewOAuthError(model.OAuthUnauthorizedClient, "Client is not allowed to use this grant type")
+	}
+
+	switch grantType {
+	case model.GrantAuthorizationCode:
+		return s.exchangeAuthorizationCode(ctx, client, form, userAgent, ip)
+	case model.GrantRefreshToken:
+		return s.refreshToken(ctx, client, form)
+	default:
+		return s.clientCredentials(client, form)
+	}
+}
+
+func (s *OAuthService) exchangeAuthorizationCode(ctx context.Context, client *model.OAuthClient, form url.Values, userAgent, ip string) (*model.AuthTokens, error) {
+	invalidGrant := model.NewOAuthError(model.OAuthInvalidGrant, "Invalid or expired authorization code")
+
+	code := form.Get("code")
+	if code == "" {
+		return nil, model.NewOAuthError(model.OAuthInvalidRequest, "code is required")
+	}
+
+	// GETDEL делает код одноразовым даже при параллельных запросах
+	data, err := s.redisClient.GetDel(ctx, authorizationCodeKey(code)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, invalidGrant
+		}
+		return nil, err
+	}
+
+	var authCode model.AuthorizationCode
+	if err := json.Unmarshal(data, &authCode); err != nil {
+		return nil, err
+	}
+
+	if authCode.ClientID != client.ID {
+		return nil, invalidGrant
+	}
+	// redirect_uri обязателен, только если был в запросе авторизации (RFC 6749, 4.1.3);
+	// переданный без необходимости все равно должен совпадать
+	if redirectURI := form.Get("redirect_uri"); (authCode.RedirectURISent || redirectURI != "") && redirectURI != authCode.RedirectURI {
+		return nil, invalidGrant
+	}
+
+	if !verifyCodeChallenge(form.Get("code_verifier"), authCode.CodeChallenge) {
+		return nil, model.NewOAuthError(model.OAuthInvalidGrant, "PKCE verification failed")
+	}
+
+	tokens, err := s.authService.IssueTokens(ctx, &model.Session{
+		UserID:    authCode.UserID,
+		UserAgent: userAgent,
+		IP:        ip,
+		ClientID:  client.ID,
+		Scope:     authCode.Scope,
+	})
+	var statusErr *model.AccountStatusError
+	if errors.As(err, &statusErr) || err == model.ErrPasswordResetRequired {
+		// Аккаунт отключили или удалили, пока код ждал обмена
+		return nil, model.NewOAuthError(model.OAuthInvalidGrant, err.Error())
+	}
+	return tokens, err
+}
+
+func (s *OAuthService) refreshToken(ctx context.Context, client *model.OAuthClient, form url.Values) (*model.AuthTokens, error) {
+	refreshToken := form.Get("refresh_token")
+	if refreshToken == "" {
+		return nil, model.NewOAuthError(model.OAuthInvalidRequest, "refresh_token is required")
+	}
+
+	tokens, err := s.authService.RefreshTokens(ctx, refreshToken, client.ID)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidSession) || errors.Is(err, model.ErrSessionExpired) {
+			return nil, model.NewOAuthError(model.OAuthInvalidGrant, "Invalid or expired refresh token")
+		}
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// clientCredentials выдает короткоживущий токен самому клиенту: sub и client_id равны id клиента,
+// пользователя и refresh-токена нет (RFC 6749, 4.4.3)
+func (s *OAuthService) clientCredentials(client *model.OAuthClient, form url.Values) (*model.AuthTokens, error) {
+	if !client.IsConfidential() {
+		return nil, model.NewOAuthError(model.OAuthUnauthorizedClient, "Public clients cannot use client_credentials")
+	}
+
+	scopes := model.ParseScope(form.Get("scope"))
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	if !client.AllowsScope(scopes) {
+		return nil, model.NewOAuthError(model.OAuthInvalidScope, "Requested scope is not allowed")
+	}
+	scope := model.JoinScope(scopes)
+
+	accessToken, expiresAtUnix, err := s.jwtService.GenerateToken(client.ID, TokenOptions{
+		ClientID: client.ID,
+		Scope:    scope,
+		TTL:      clientCredentialsTokenTTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.AuthTokens{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Unix(expiresAtUnix, 0).Sub(time.Now()).Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// Introspect проверяет токен по запросу ресурс-сервера (RFC 7662)
+func (s *OAuthService) Introspect(ctx context.Context, client *model.OAuthClient, token, tokenTypeHint string) (*model.TokenIntrospection, error) {
+	// Публичный клиент не может доказать свою подлинность
+	if !client.IsConfidential() {
+		return nil, model.NewOAuthError(model.OAuthUnauthorizedClient, "Introspection requires a confidential client")
+	}
+
+	return s.authService.IntrospectToken(ctx, token, tokenTypeHint)
+}
+
+// verifyCodeChallenge сверяет code_verifier с сохраненным S256 code_challenge (RFC 7636, 4.6)
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < minCodeVerifierLength || len(verifier) > maxCodeVerifierLength {
+		return false
+	}
+	for _, c := range verifier {
+		isUnreserved := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~'
+		if !isUnreserved {
+			return false
+		}
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func appendQuery(rawURL string, params url.Values) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := parsed.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+func authorizationRequestKey(requestID string) string {
+	return "oauth_authz_request:" + util.HashToken(requestID)
+}
+
+func authorizationCodeKey(code string) string {
+	return "oauth_code:" + util.HashToken(code)
+}
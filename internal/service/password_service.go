@@ -0,0 +1,119 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PasswordService смена пароля по ссылке из письма
+type PasswordService struct {
+	userRepo     repository.UserRepository
+	sessionRepo  repository.SessionRepository
+	auditService *AuditService
+	mailer       Mailer
+	redisClient  *redis.Client
+	baseURL      string
+}
+
+func NewPasswordService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	auditService *AuditService,
+	mailer Mailer,
+	redisClient *redis.Client,
+	baseURL string,
+) *PasswordService {
+	return &PasswordService{
+		userRepo:     userRepo,
+		sessionRepo:  sessionRepo,
+		auditService: auditService,
+		mailer:       mailer,
+		redisClient:  redisClient,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+	}
+}
+
+const (
+	passwordResetTTL  = time.Hour
+	passwordResetPath = "/password/reset"
+)
+
+// SendResetLink отправляет пользователю одноразовую ссылку для установки нового пароля
+func (s *PasswordService) SendResetLink(ctx context.Context, user *model.User) error {
+	token, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	if err := s.redisClient.Set(ctx, passwordResetKey(token), user.ID, passwordResetTTL).Err(); err != nil {
+		return err
+	}
+
+	link := s.baseURL + passwordResetPath + "?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(ctx, EmailMessage{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "A password reset is required for your account. Use the link below to choose\n" +
+			"a new password. It expires in 1 hour and works only once.\n\n" +
+			link + "\n",
+	})
+	if err != nil {
+		s.redisClient.Del(ctx, passwordResetKey(token))
+		return err
+	}
+
+	return nil
+}
+
+// ResetPassword устанавливает новый пароль по ссылке из письма и завершает все сессии пользователя
+func (s *PasswordService) ResetPassword(ctx context.Context, token, newPassword, userAgent, ip string) error {
+	if token == "" {
+		return model.ErrPasswordResetInvalid
+	}
+
+	// GetDel делает ссылку одноразовой даже при параллельных запросах
+	userID, err := s.redisClient.GetDel(ctx, passwordResetKey(token)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return model.ErrPasswordResetInvalid
+		}
+		return err
+	}
+
+	hashedPassword, err := util.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		if err == model.ErrUserNotFound {
+			return model.ErrPasswordResetInvalid
+		}
+		return err
+	}
+	if err := s.sessionRepo.RevokeAllUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    userID,
+		ActorID:   userID,
+		Type:      model.AuditPasswordReset,
+		IP:        ip,
+		UserAgent: userAgent,
+	})
+
+	return nil
+}
+
+func passwordResetKey(token string) string {
+	return "password_reset:" + util.HashToken(token)
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"authorization_authentication/pkg/logger"
+	"context"
+	"time"
+)
+
+// PrivacyService удаление аккаунта и выгрузка данных пользователя
+type PrivacyService struct {
+	userRepo     repository.UserRepository
+	sessionRepo  repository.SessionRepository
+	apiKeyRepo   repository.APIKeyRepository
+	webAuthnRepo repository.WebAuthnRepository
+	mfaRepo      repository.MFARepository
+	auditRepo    repository.AuditRepository
+	authService  *AuthService
+	auditService *AuditService
+	gracePeriod  time.Duration // Сколько аккаунт можно восстановить после удаления
+}
+
+func NewPrivacyService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	apiKeyRepo repository.APIKeyRepository,
+	webAuthnRepo repository.WebAuthnRepository,
+	mfaRepo repository.MFARepository,
+	auditRepo repository.AuditRepository,
+	authService *AuthService,
+	auditService *AuditService,
+	gracePeriod time.Duration,
+) *PrivacyService {
+	return &PrivacyService{
+		userRepo:     userRepo,
+		sessionRepo:  sessionRepo,
+		apiKeyRepo:   apiKeyRepo,
+		webAuthnRepo: webAuthnRepo,
+		mfaRepo:      mfaRepo,
+		auditRepo:    auditRepo,
+		authService:  authService,
+		auditService: auditService,
+		gracePeriod:  gracePeriod,
+	}
+}
+
+const purgeInterval = time.Hour
+
+// DeleteAccount помечает аккаунт удаленным после повторной проверки пароля.
+// Все сессии и API-ключи отзываются сразу; данные удаляются после периода ожидания.
+func (s *PrivacyService) DeleteAccount(ctx context.Context, userID, password, userAgent, ip string) (time.Time, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if user == nil {
+		return time.Time{}, model.ErrUserNotFound
+	}
+	if !util.CheckPasswordHash(password, user.Password) {
+		return time.Time{}, model.ErrInvalidCredentials
+	}
+
+	return s.ScheduleDeletion(ctx, userID, userID, model.AuditAccountDeletionRequested, userAgent, ip)
+}
+
+// ScheduleDeletion помечает аккаунт удаленным и отзывает все его сессии и API-ключи.
+// actorID и eventType различают удаление самим пользователем и администратором.
+func (s *PrivacyService) ScheduleDeletion(ctx context.Context, userID, actorID, eventType, userAgent, ip string) (time.Time, error) {
+	if err := s.userRepo.MarkDeleted(ctx, userID); err != nil {
+		return time.Time{}, err
+	}
+	if err := s.sessionRepo.RevokeAllUserSessions(ctx, userID); err != nil {
+		return time.Time{}, err
+	}
+	if err := s.apiKeyRepo.RevokeAllUserAPIKeys(ctx, userID); err != nil {
+		return time.Time{}, err
+	}
+
+	purgeAt := time.Now().UTC().Add(s.gracePeriod)
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    userID,
+		ActorID:   actorID,
+		Type:      eventType,
+		IP:        ip,
+		UserAgent: userAgent,
+		Metadata:  map[string]string{"purge_at": purgeAt.Format(time.RFC3339)},
+	})
+
+	return purgeAt, nil
+}
+
+// RestoreAccount отменяет удаление в течение периода ожидания. Вход по паролю проходит
+// те же проверки и счетчики попыток, что и /login.
+func (s *PrivacyService) RestoreAccount(ctx context.Context, email, password, captchaToken, userAgent, ip string) error {
+	user, err := s.authService.AuthenticateUser(ctx, email, password, captchaToken, ip)
+	if err != nil {
+		return err
+	}
+	if user.Status != model.UserStatusPendingDeletion {
+		return model.ErrAccountNotDeleted
+	}
+
+	if err := s.userRepo.Restore(ctx, user.ID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    user.ID,
+		ActorID:   user.ID,
+		Type:      model.AuditAccountRestored,
+		IP:        ip,
+		UserAgent: userAgent,
+	})
+
+	return nil
+}
+
+// ExportAccount собирает все данные, которые сервис хранит о пользователе
+func (s *PrivacyService) ExportAccount(ctx context.Context, userID, userAgent, ip string) (*model.AccountExport, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, model.ErrUserNotFound
+	}
+
+	sessions, err := s.sessionRepo.ListUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.auditRepo.ListUserEvents(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := s.apiKeyRepo.ListUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := s.webAuthnRepo.ListUserCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	mfa, err := s.mfaRepo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &model.AccountExport{
+		ExportedAt:  time.Now().UTC(),
+		Profile:     user,
+		Sessions:    make([]model.SessionInfo, 0, len(sessions)),
+		AuditEvents: events,
+		APIKeys:     apiKeys,
+		Passkeys:    passkeys,
+		TwoFactor: model.ExportedTwoFactor{
+			TOTPEnabled: mfa.TOTPEnabled(),
+			SMSEnabled:  mfa.SMSFactorEnabled(),
+		},
+	}
+	if mfa != nil {
+		export.TwoFactor.TOTPConfirmedAt = mfa.TOTPConfirmedAt
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, model.NewSessionInfo(session))
+	}
+	if export.AuditEvents == nil {
+		export.AuditEvents = []*model.AuditEvent{}
+	}
+	if export.APIKeys == nil {
+		export.APIKeys = []*model.APIKey{}
+	}
+	if export.Passkeys == nil {
+		export.Passkeys = []*model.WebAuthnCredential{}
+	}
+
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    userID,
+		ActorID:   userID,
+		Type:      model.AuditAccountExported,
+		IP:        ip,
+		UserAgent: userAgent,
+	})
+
+	return export, nil
+}
+
+// StartPurgeRoutine периодически окончательно удаляет аккаунты, у которых истек период ожидания
+func (s *PrivacyService) StartPurgeRoutine(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.purgeDeletedAccounts(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (s *PrivacyService) purgeDeletedAccounts(ctx context.Context) {
+	ids, err := s.userRepo.PurgeDeletedUsers(ctx, time.Now().UTC().Add(-s.gracePeriod))
+	if err != nil {
+		logger.Log.Warn("Failed to purge deleted accounts: ", err)
+		return
+	}
+
+	for _, id := range ids {
+		// Пользователя уже нет, поэтому id только в метаданных
+		s.auditService.Record(ctx, &model.AuditEvent{
+			Type:     model.AuditAccountPurged,
+			Metadata: map[string]string{"user_id": id},
+		})
+	}
+}
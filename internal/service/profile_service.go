@@ -0,0 +1,138 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/repository"
+	"context"
+	"net/url"
+	"strings"
+	"time"
+	_ "time/tzdata" // Часовые пояса не зависят от наличия базы IANA в образе
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+)
+
+type ProfileService struct {
+	userRepo     repository.UserRepository
+	auditService *AuditService
+}
+
+func NewProfileService(userRepo repository.UserRepository, auditService *AuditService) *ProfileService {
+	return &ProfileService{
+		userRepo:     userRepo,
+		auditService: auditService,
+	}
+}
+
+const (
+	maxDisplayNameLength = 100
+	maxAvatarURLLength   = 2048
+)
+
+func (s *ProfileService) GetProfile(ctx context.Context, userID string) (*model.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, model.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// UpdateProfile применяет изменения, если клиент видел актуальную версию профиля (ifMatch — ETag из GET /me)
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID, ifMatch string, update *model.ProfileUpdate, userAgent, ip string) (*model.User, error) {
+	if err := validateProfileUpdate(update); err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if ifMatch != user.ETag() {
+		return nil, model.ErrProfileModified
+	}
+
+	var changed []string
+	apply := func(field string, dst *string, src *string) {
+		if src != nil && *src != *dst {
+			*dst = *src
+			changed = append(changed, field)
+		}
+	}
+	apply("display_name", &user.DisplayName, update.DisplayName)
+	apply("locale", &user.Locale, update.Locale)
+	apply("timezone", &user.Timezone, update.Timezone)
+	apply("avatar_url", &user.AvatarURL, update.AvatarURL)
+
+	if len(changed) == 0 {
+		return user, nil
+	}
+
+	user.UpdatedAt, err = s.userRepo.UpdateProfile(ctx, user, user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(ctx, &model.AuditEvent{
+		UserID:    userID,
+		ActorID:   userID,
+		Type:      model.AuditProfileUpdated,
+		IP:        ip,
+		UserAgent: userAgent,
+		Metadata:  map[string]string{"fields": strings.Join(changed, ",")},
+	})
+
+	return user, nil
+}
+
+// validateProfileUpdate проверяет поля и приводит их к каноническому виду
+func validateProfileUpdate(update *model.ProfileUpdate) error {
+	verr := &model.ValidationError{}
+
+	if update.DisplayName != nil {
+		name := strings.TrimSpace(*update.DisplayName)
+		switch {
+		case !utf8.ValidString(name):
+			verr.Add("display_name", "must be valid UTF-8")
+		case utf8.RuneCountInString(name) > maxDisplayNameLength:
+			verr.Add("display_name", "must be at most 100 characters")
+		case strings.IndexFunc(name, unicode.IsControl) >= 0:
+			verr.Add("display_name", "must not contain control characters")
+		}
+		update.DisplayName = &name
+	}
+
+	if update.Locale != nil && *update.Locale != "" {
+		tag, err := language.Parse(*update.Locale)
+		if err != nil {
+			verr.Add("locale", "must be a BCP 47 language tag, e.g. en-US")
+		} else {
+			locale := tag.String()
+			update.Locale = &locale
+		}
+	}
+
+	if update.Timezone != nil && *update.Timezone != "" {
+		if _, err := time.LoadLocation(*update.Timezone); err != nil || *update.Timezone == "Local" {
+			verr.Add("timezone", "must be an IANA time zone, e.g. Europe/Moscow")
+		}
+	}
+
+	if update.AvatarURL != nil && *update.AvatarURL != "" {
+		u, err := url.Parse(*update.AvatarURL)
+		switch {
+		case len(*update.AvatarURL) > maxAvatarURLLength:
+			verr.Add("avatar_url", "must be at most 2048 characters")
+		case err != nil || u.Scheme != "https" || u.Host == "":
+			verr.Add("avatar_url", "must be an absolute https URL")
+		case u.User != nil:
+			verr.Add("avatar_url", "must not contain credentials")
+		}
+	}
+
+	return verr.OrNil()
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	"github.com/twilio/twilio-go"
+	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+)
+
+// SMSSender отправляет произвольные SMS-уведомления (не коды подтверждения)
+type SMSSender interface {
+	SendSMS(ctx context.Context, to, body string) error
+}
+
+// TwilioSMSSender реализация SMSSender поверх Twilio Messaging
+type TwilioSMSSender struct {
+	client *twilio.RestClient
+	from   string
+}
+
+func NewTwilioSMSSender(client *twilio.RestClient, from string) *TwilioSMSSender {
+	return &TwilioSMSSender{
+		client: client,
+		from:   from,
+	}
+}
+
+func (s *TwilioSMSSender) SendSMS(_ context.Context, to, body string) error {
+	params := &twilioApi.CreateMessageParams{}
+	params.SetTo(to)
+	params.SetFrom(s.from)
+	params.SetBody(body)
+
+	_, err := s.client.Api.CreateMessage(params)
+	return err
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SyslogFormatJSON = "json"
+	SyslogFormatCEF  = "cef"
+
+	syslogFacilityAuthPriv = 10
+	syslogSeverityWarning  = 4
+	syslogSeverityInfo     = 6
+
+	syslogAppName     = "auth"
+	syslogDialTimeout = 5 * time.Second
+	syslogMaxMsgID    = 32 // RFC 5424, 6.2.7
+)
+
+// SyslogSink отправляет события по RFC 5424 на syslog-сервер по UDP или TCP.
+// Тело сообщения — JSON события или строка CEF. По TCP сообщения разделяются
+// префиксом длины (octet counting, RFC 6587).
+type SyslogSink struct {
+	network  string
+	addr     string
+	format   string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink принимает адрес вида udp://host:514 или tcp://host:601
+func NewSyslogSink(rawURL, format string) (*SyslogSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog address %q: %w", rawURL, err)
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("syslog address must start with udp:// or tcp://, got %q", rawURL)
+	}
+	if u.Port() == "" {
+		return nil, fmt.Errorf("syslog address %q has no port", rawURL)
+	}
+
+	if format == "" {
+		format = SyslogFormatJSON
+	}
+	if format != SyslogFormatJSON && format != SyslogFormatCEF {
+		return nil, fmt.Errorf("unknown syslog format %q", format)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &SyslogSink{
+		network:  u.Scheme,
+		addr:     u.Host,
+		format:   format,
+		hostname: hostname,
+	}, nil
+}
+
+func (s *SyslogSink) Send(ctx context.Context, event *model.AuditEvent) error {
+	msg, err := s.formatMessage(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Сервер мог закрыть соединение: пробуем один раз переподключиться
+	err = s.write(ctx, msg)
+	if err != nil {
+		s.closeConn()
+		err = s.write(ctx, msg)
+	}
+	return err
+}
+
+func (s *SyslogSink) write(ctx context.Context, msg []byte) error {
+	if s.conn == nil {
+		dialer := net.Dialer{Timeout: syslogDialTimeout}
+		conn, err := dialer.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	if s.network == "tcp" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+func (s *SyslogSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeConn()
+	return nil
+}
+
+// formatMessage собирает сообщение RFC 5424: <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+func (s *SyslogSink) formatMessage(event *model.AuditEvent) ([]byte, error) {
+	severity := syslogSeverityInfo
+	if event.Outcome == model.AuditOutcomeFailure {
+		severity = syslogSeverityWarning
+	}
+
+	var body string
+	if s.format == SyslogFormatCEF {
+		body = formatCEF(event)
+	} else {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		body = string(data)
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s -",
+		syslogFacilityAuthPriv*8+severity,
+		event.CreatedAt.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname,
+		syslogAppName,
+		os.Getpid(),
+		syslogMsgID(event.Type),
+	)
+
+	return []byte(header + " " + body), nil
+}
+
+// syslogMsgID тип события в виде MSGID: только печатные ASCII без пробелов, до 32 символов
+func syslogMsgID(eventType string) string {
+	id := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, eventType)
+	if len(id) > syslogMaxMsgID {
+		id = id[:syslogMaxMsgID]
+	}
+	if id == "" {
+		return "-"
+	}
+	return id
+}
+
+// formatCEF представляет событие в ArcSight Common Event Format
+func formatCEF(event *model.AuditEvent) string {
+	severity := "3"
+	if event.Outcome == model.AuditOutcomeFailure {
+		severity = "6"
+	}
+
+	header := strings.Join([]string{
+		"CEF:0",
+		cefHeader("Auth"),
+		cefHeader(syslogAppName),
+		"1.0",
+		cefHeader(event.Type),
+		cefHeader(event.Type),
+		severity,
+	}, "|")
+
+	ext := []string{"rt=" + strconv.FormatInt(event.CreatedAt.UnixMilli(), 10)}
+	add := func(key, value string) {
+		if value != "" {
+			ext = append(ext, key+"="+cefValue(value))
+		}
+	}
+	add("externalId", event.ID)
+	add("suid", event.UserID)
+	add("outcome", event.Outcome)
+	add("reason", event.Reason)
+	add("src", event.IP)
+	add("requestClientApplication", event.UserAgent)
+	if event.RequestID != "" {
+		add("cs1Label", "requestId")
+		add("cs1", event.RequestID)
+	}
+	if event.ActorID != "" {
+		add("cs2Label", "actorId")
+		add("cs2", event.ActorID)
+	}
+	if event.SessionID != "" {
+		add("cs3Label", "sessionId")
+		add("cs3", event.SessionID)
+	}
+	if len(event.Metadata) > 0 {
+		if metadata, err := json.Marshal(event.Metadata); err == nil {
+			add("cs4Label", "metadata")
+			add("cs4", string(metadata))
+		}
+	}
+
+	return header + "|" + strings.Join(ext, " ")
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+func cefHeader(s string) string {
+	return cefHeaderEscaper.Replace(s)
+}
+
+func cefValue(s string) string {
+	return cefValueEscaper.Replace(s)
+}
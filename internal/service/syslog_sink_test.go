@@ -0,0 +1,225 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAuditEvent(id, outcome string) *model.AuditEvent {
+	return &model.AuditEvent{
+		ID:        id,
+		UserID:    "0b7d4f5e-9f8e-4c1a-a7a3-3f2b6c1d2e4f",
+		Type:      model.AuditLoginFailed,
+		Outcome:   outcome,
+		Reason:    "invalid_credentials",
+		IP:        "192.0.2.10",
+		UserAgent: "curl/8.0",
+		RequestID: "req-1",
+		Metadata:  map[string]string{"email": "user@example.com"},
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.UTC),
+	}
+}
+
+// syslogHeader <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+var syslogHeader = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) (\S+) (\d+) (\S+) - (.*)$`)
+
+func checkSyslogMessage(t *testing.T, msg string, wantPRI int, event *model.AuditEvent) string {
+	t.Helper()
+
+	m := syslogHeader.FindStringSubmatch(msg)
+	if m == nil {
+		t.Fatalf("message is not RFC 5424: %q", msg)
+	}
+	if pri, _ := strconv.Atoi(m[1]); pri != wantPRI {
+		t.Errorf("PRI = %d, want %d", pri, wantPRI)
+	}
+	if m[2] != "2026-01-02T03:04:05.123456Z" {
+		t.Errorf("timestamp = %q", m[2])
+	}
+	if m[4] != syslogAppName {
+		t.Errorf("app name = %q", m[4])
+	}
+	if m[5] != strconv.Itoa(os.Getpid()) {
+		t.Errorf("procid = %q", m[5])
+	}
+	if m[6] != event.Type {
+		t.Errorf("msgid = %q, want %q", m[6], event.Type)
+	}
+	return m[7]
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sink, err := NewSyslogSink("udp://"+conn.LocalAddr().String(), SyslogFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	event := testAuditEvent("evt-udp", model.AuditOutcomeFailure)
+	if err := sink.Send(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64*1024)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// authpriv (10) * 8 + warning (4) для неудачи
+	body := checkSyslogMessage(t, string(buf[:n]), 84, event)
+	var decoded model.AuditEvent
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if decoded.ID != event.ID || decoded.Metadata["email"] != "user@example.com" {
+		t.Fatalf("unexpected body %+v", decoded)
+	}
+}
+
+func TestSyslogSinkTCPOctetCounting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	frames := make(chan string, 2)
+	errs := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer conn.Close()
+
+		// RFC 6587: MSG-LEN SP SYSLOG-MSG, без разделителей между кадрами
+		reader := bufio.NewReader(conn)
+		for i := 0; i < 2; i++ {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				errs <- err
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+			if err != nil {
+				errs <- fmt.Errorf("bad frame length %q", length)
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				errs <- err
+				return
+			}
+			frames <- string(msg)
+		}
+	}()
+
+	sink, err := NewSyslogSink("tcp://"+listener.Addr().String(), SyslogFormatCEF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	events := []*model.AuditEvent{
+		testAuditEvent("evt-1", model.AuditOutcomeSuccess),
+		testAuditEvent("evt-2", model.AuditOutcomeFailure),
+	}
+	for _, event := range events {
+		if err := sink.Send(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, wantPRI := range []int{86, 84} {
+		select {
+		case msg := <-frames:
+			body := checkSyslogMessage(t, msg, wantPRI, events[i])
+			if !strings.HasPrefix(body, "CEF:0|Auth|auth|1.0|login_failed|login_failed|") {
+				t.Errorf("unexpected CEF header %q", body)
+			}
+			if !strings.Contains(body, "externalId="+events[i].ID) {
+				t.Errorf("CEF body lacks event id: %q", body)
+			}
+		case err := <-errs:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for syslog frame")
+		}
+	}
+}
+
+func TestCEFEscaping(t *testing.T) {
+	headers := []struct{ in, want string }{
+		{"plain", "plain"},
+		{`a|b`, `a\|b`},
+		{`back\slash`, `back\\slash`},
+		{"line\nbreak\r", "line break "},
+		{"a=b", "a=b"}, // = в заголовке не экранируется
+	}
+	for _, tt := range headers {
+		if got := cefHeader(tt.in); got != tt.want {
+			t.Errorf("cefHeader(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	values := []struct{ in, want string }{
+		{"plain", "plain"},
+		{"a=b", `a\=b`},
+		{`back\slash`, `back\\slash`},
+		{"line\nbreak\r", `line\nbreak\r`},
+		{"a|b", "a|b"}, // | в расширении не экранируется
+	}
+	for _, tt := range values {
+		if got := cefValue(tt.in); got != tt.want {
+			t.Errorf("cefValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	event := testAuditEvent("evt|1", model.AuditOutcomeFailure)
+	event.Type = "odd|type"
+	event.UserAgent = "agent=x\ninjected=1"
+	cef := formatCEF(event)
+	if !strings.HasPrefix(cef, `CEF:0|Auth|auth|1.0|odd\|type|odd\|type|6|`) {
+		t.Errorf("unexpected header in %q", cef)
+	}
+	if !strings.Contains(cef, `requestClientApplication=agent\=x\ninjected\=1`) {
+		t.Errorf("user agent not escaped in %q", cef)
+	}
+	if strings.Contains(cef, "\n") {
+		t.Errorf("CEF line contains a raw newline: %q", cef)
+	}
+}
+
+func TestSyslogMsgID(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"login_failed", "login_failed"},
+		{"with space", "with_space"},
+		{"", "-"},
+		{strings.Repeat("x", 40), strings.Repeat("x", syslogMaxMsgID)},
+	}
+	for _, tt := range tests {
+		if got := syslogMsgID(tt.in); got != tt.want {
+			t.Errorf("syslogMsgID(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+
+	"github.com/twilio/twilio-go"
+	verify "github.com/twilio/twilio-go/rest/verify/v2"
+)
+
+// VerificationProvider отправляет и проверяет одноразовые коды подтверждения
+type VerificationProvider interface {
+	SendCode(ctx context.Context, to, channel string) error
+	CheckCode(ctx context.Context, to, code string) (bool, error)
+}
+
+// TwilioVerifier реализация VerificationProvider поверх Twilio Verify
+type TwilioVerifier struct {
+	client     *twilio.RestClient
+	serviceSID string
+}
+
+func NewTwilioVerifier(client *twilio.RestClient, serviceSID string) *TwilioVerifier {
+	return &TwilioVerifier{
+		client:     client,
+		serviceSID: serviceSID,
+	}
+}
+
+func (v *TwilioVerifier) SendCode(_ context.Context, to, channel string) error {
+	params := &verify.CreateVerificationParams{}
+	params.SetTo(to)
+	params.SetChannel(channel)
+
+	_, err := v.client.VerifyV2.CreateVerification(v.serviceSID, params)
+	return err
+}
+
+func (v *TwilioVerifier) CheckCode(_ context.Context, to, code string) (bool, error) {
+	params := &verify.CreateVerificationCheckParams{}
+	params.SetTo(to)
+	params.SetCode(code)
+
+	resp, err := v.client.VerifyV2.CreateVerificationCheck(v.serviceSID, params)
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Status != nil && *resp.Status == "approved", nil
+}
@@ -0,0 +1,440 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/ratelimit"
+	"authorization_authentication/internal/repository"
+	"authorization_authentication/internal/util"
+	"authorization_authentication/pkg/logger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type WebAuthnService struct {
+	credentialRepo webAuthnCredentialStore
+	userRepo       webAuthnUserStore
+	mfaService     secondFactorVerifier
+	authService    tokenIssuer
+	redisClient    redis.Cmdable
+	limiter        ratelimit.Limiter  // Неудачные подтверждения пароля перед добавлением passkey
+	webAuthn       *webauthn.WebAuthn // nil, если relying party не настроен
+}
+
+// Зависимости сервиса в виде интерфейсов, чтобы церемонии можно было проверить в тестах
+// программным аутентификатором без базы и Redis
+type webAuthnCredentialStore interface {
+	CreateCredential(ctx context.Context, cred *model.WebAuthnCredential) error
+	GetCredentialByCredentialID(ctx context.Context, credentialID []byte) (*model.WebAuthnCredential, error)
+	ListUserCredentials(ctx context.Context, userID string) ([]*model.WebAuthnCredential, error)
+	UpdateCredentialUsage(ctx context.Context, cred *model.WebAuthnCredential) error
+	DeleteCredential(ctx context.Context, userID, id string) error
+}
+
+type webAuthnUserStore interface {
+	GetUserByID(ctx context.Context, id string) (*model.User, error)
+}
+
+type secondFactorVerifier interface {
+	Methods(ctx context.Context, userID string) ([]string, error)
+	Verify(ctx context.Context, userID, method, code string) error
+}
+
+type tokenIssuer interface {
+	IssueTokens(ctx context.Context, session *model.Session) (*model.AuthTokens, error)
+}
+
+// NewWebAuthnService без rpID сервис работает, но passkeys недоступны
+func NewWebAuthnService(
+	credentialRepo repository.WebAuthnRepository,
+	userRepo repository.UserRepository,
+	authService *AuthService,
+	mfaService *MFAService,
+	redisClient *redis.Client,
+	limiter ratelimit.Limiter,
+	rpID string,
+	rpName string,
+	rpOrigins []string,
+) (*WebAuthnService, error) {
+	s := &WebAuthnService{
+		credentialRepo: &credentialRepo,
+		userRepo:       &userRepo,
+		mfaService:     mfaService,
+		authService:    authService,
+		redisClient:    redisClient,
+		limiter:        limiter,
+	}
+	if rpID == "" {
+		return s, nil
+	}
+
+	w, err := newRelyingParty(rpID, rpName, rpOrigins)
+	if err != nil {
+		return nil, err
+	}
+	s.webAuthn = w
+	return s, nil
+}
+
+func newRelyingParty(rpID, rpName string, rpOrigins []string) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     rpOrigins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnCeremonyTTL},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnCeremonyTTL},
+		},
+	})
+}
+
+const (
+	webAuthnCeremonyTTL = 5 * time.Minute
+	webAuthnKeyPrefix   = "webauthn_ceremony:"
+)
+
+// Passkey входит без второго фактора, поэтому перед его добавлением пароль и второй фактор
+// подтверждаются заново. Неудачные подтверждения ограничены, чтобы украденный токен доступа
+// не превращался в перебор пароля.
+var passkeyStepUpFailures = ratelimit.Policy{
+	Name:      "passkey_step_up_failures",
+	Algorithm: ratelimit.SlidingWindow,
+	Limit:     5,
+	Window:    15 * time.Minute,
+}
+
+// Вид церемонии хранится вместе с challenge, чтобы ответ на регистрацию нельзя было выдать за вход
+const (
+	webAuthnCeremonyRegistration = "registration"
+	webAuthnCeremonyLogin        = "login"
+)
+
+type webAuthnCeremonyState struct {
+	Kind    string               `json:"kind"`
+	Session webauthn.SessionData `json:"session"`
+}
+
+// webAuthnUser адаптер пользователя к интерфейсу webauthn.User.
+// User handle — UUID пользователя, по нему находим аккаунт при входе без логина.
+type webAuthnUser struct {
+	user        *model.User
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte                         { return []byte(u.user.ID) }
+func (u *webAuthnUser) WebAuthnName() string                       { return u.user.Email }
+func (u *webAuthnUser) WebAuthnDisplayName() string                { return u.user.Email }
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+// BeginRegistration выдает параметры для navigator.credentials.create()
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, userID string) (*model.WebAuthnCeremony, error) {
+	if s.webAuthn == nil {
+		return nil, model.ErrWebAuthnNotConfigured
+	}
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.saveCeremony(ctx, webAuthnCeremonyRegistration, session, creation.Response)
+}
+
+// FinishRegistration проверяет повторное подтверждение личности и ответ аутентификатора и сохраняет новый ключ
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, userID, ceremonyID, name string, stepUp model.StepUp, response []byte) (*model.WebAuthnCredential, error) {
+	if s.webAuthn == nil {
+		return nil, model.ErrWebAuthnNotConfigured
+	}
+
+	// До церемонии: неверный пароль не сжигает challenge, и его можно ввести заново
+	if err := s.checkStepUp(ctx, userID, stepUp); err != nil {
+		return nil, err
+	}
+
+	session, err := s.takeCeremony(ctx, ceremonyID, webAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(session.UserID, []byte(userID)) {
+		return nil, model.ErrWebAuthnCeremonyFailed
+	}
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, model.ErrWebAuthnCeremonyFailed
+	}
+
+	credential, err := s.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, model.ErrWebAuthnCeremonyFailed
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+
+	cred := &model.WebAuthnCredential{
+		ID:              uuid.NewString(),
+		UserID:          userID,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      transports,
+		Flags:           uint8(credential.Flags.ProtocolValue()),
+		Name:            name,
+		CreatedAt:       time.Now().UTC(),
+	}
+	if err := s.credentialRepo.CreateCredential(ctx, cred); err != nil {
+		return nil, err
+	}
+
+	return cred, nil
+}
+
+// BeginLogin выдает параметры для navigator.credentials.get() без указания пользователя:
+// аутентификатор сам предложит подходящий passkey.
+func (s *WebAuthnService) BeginLogin(ctx context.Context) (*model.WebAuthnCeremony, error) {
+	if s.webAuthn == nil {
+		return nil, model.ErrWebAuthnNotConfigured
+	}
+
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.saveCeremony(ctx, webAuthnCeremonyLogin, session, assertion.Response)
+}
+
+// FinishLogin проверяет подпись и выдает обычные токены.
+// Passkey с проверкой пользователя уже двухфакторный, поэтому отдельный второй фактор не запрашиваем.
+func (s *WebAuthnService) FinishLogin(ctx context.Context, ceremonyID string, response []byte, userAgent, ip string) (*model.AuthTokens, error) {
+	if s.webAuthn == nil {
+		return nil, model.ErrWebAuthnNotConfigured
+	}
+
+	session, err := s.takeCeremony(ctx, ceremonyID, webAuthnCeremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, model.ErrWebAuthnCeremonyFailed
+	}
+
+	var stored *model.WebAuthnCredential
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		cred, err := s.credentialRepo.GetCredentialByCredentialID(ctx, rawID)
+		if err != nil {
+			return nil, err
+		}
+		if cred == nil || !bytes.Equal(userHandle, []byte(cred.UserID)) {
+			return nil, model.ErrCredentialNotFound
+		}
+		stored = cred
+		return s.loadUser(ctx, cred.UserID)
+	}
+
+	_, credential, err := s.webAuthn.ValidatePasskeyLogin(handler, *session, parsed)
+	if err != nil || stored == nil {
+		return nil, model.ErrInvalidCredentials
+	}
+
+	stored.SignCount = credential.Authenticator.SignCount
+	stored.CloneWarning = stored.CloneWarning || credential.Authenticator.CloneWarning
+	stored.Flags = uint8(credential.Flags.ProtocolValue())
+	if err := s.credentialRepo.UpdateCredentialUsage(ctx, stored); err != nil {
+		return nil, err
+	}
+	if stored.CloneWarning {
+		logger.Log.Warn("Passkey sign counter went backwards, possible cloned authenticator: ", stored.ID)
+		return nil, model.ErrInvalidCredentials
+	}
+
+	return s.authService.IssueTokens(ctx, &model.Session{
+		UserID:    stored.UserID,
+		UserAgent: userAgent,
+		IP:        ip,
+	})
+}
+
+// checkStepUp пароль обязателен всегда, код второго фактора — если он включен
+func (s *WebAuthnService) checkStepUp(ctx context.Context, userID string, stepUp model.StepUp) error {
+	result, err := s.limiter.Peek(ctx, passkeyStepUpFailures, userID)
+	if err != nil {
+		return err
+	}
+	if !result.Allowed {
+		return model.ErrTooManyAttempts
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return model.ErrUserNotFound
+	}
+
+	methods, err := s.mfaService.Methods(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if stepUp.Password == "" || (len(methods) > 0 && stepUp.MFACode == "") {
+		return model.ErrStepUpRequired
+	}
+
+	var failure error
+	if !util.CheckPasswordHash(stepUp.Password, user.Password) {
+		failure = model.ErrInvalidCredentials
+	} else if len(methods) > 0 {
+		if err := s.mfaService.Verify(ctx, userID, stepUp.MFAMethod, stepUp.MFACode); err != nil {
+			if !errors.Is(err, model.ErrInvalidMFACode) && !errors.Is(err, model.ErrMFANotEnabled) {
+				return err
+			}
+			failure = model.ErrInvalidMFACode
+		}
+	}
+	if failure == nil {
+		return nil
+	}
+
+	if _, err := s.limiter.Allow(ctx, passkeyStepUpFailures, userID); err != nil {
+		return err
+	}
+	return failure
+}
+
+func (s *WebAuthnService) ListCredentials(ctx context.Context, userID string) ([]*model.WebAuthnCredential, error) {
+	return s.credentialRepo.ListUserCredentials(ctx, userID)
+}
+
+func (s *WebAuthnService) DeleteCredential(ctx context.Context, userID, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return model.ErrCredentialNotFound
+	}
+	return s.credentialRepo.DeleteCredential(ctx, userID, id)
+}
+
+func (s *WebAuthnService) loadUser(ctx context.Context, userID string) (*webAuthnUser, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, model.ErrUserNotFound
+	}
+
+	stored, err := s.credentialRepo.ListUserCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, c := range stored {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(c.Transports))
+		for _, t := range c.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(c.Flags)),
+			Authenticator: webauthn.Authenticator{
+				AAGUID:       c.AAGUID,
+				SignCount:    c.SignCount,
+				CloneWarning: c.CloneWarning,
+			},
+		})
+	}
+
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+func (s *WebAuthnService) saveCeremony(ctx context.Context, kind string, session *webauthn.SessionData, options any) (*model.WebAuthnCeremony, error) {
+	ceremonyID, err := util.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(webAuthnCeremonyState{Kind: kind, Session: *session})
+	if err != nil {
+		return nil, err
+	}
+
+	encodedOptions, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.redisClient.Set(ctx, webAuthnCeremonyKey(ceremonyID), data, webAuthnCeremonyTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return &model.WebAuthnCeremony{
+		CeremonyID: ceremonyID,
+		Options:    encodedOptions,
+		ExpiresIn:  int(webAuthnCeremonyTTL.Seconds()),
+	}, nil
+}
+
+// takeCeremony одноразово забирает challenge: повторно использовать его нельзя
+func (s *WebAuthnService) takeCeremony(ctx context.Context, ceremonyID, kind string) (*webauthn.SessionData, error) {
+	if ceremonyID == "" {
+		return nil, model.ErrWebAuthnCeremonyFailed
+	}
+
+	data, err := s.redisClient.GetDel(ctx, webAuthnCeremonyKey(ceremonyID)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, model.ErrWebAuthnCeremonyFailed
+		}
+		return nil, err
+	}
+
+	var state webAuthnCeremonyState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if state.Kind != kind {
+		return nil, model.ErrWebAuthnCeremonyFailed
+	}
+
+	return &state.Session, nil
+}
+
+func webAuthnCeremonyKey(ceremonyID string) string {
+	return webAuthnKeyPrefix + ceremonyID
+}
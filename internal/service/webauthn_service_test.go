@@ -0,0 +1,552 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"authorization_authentication/internal/ratelimit"
+	"authorization_authentication/internal/util"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	testRPID     = "auth.example.com"
+	testOrigin   = "https://auth.example.com"
+	testUserID   = "0b7d4f5e-9f8e-4c1a-a7a3-3f2b6c1d2e4f"
+	testPassword = "correct horse battery staple"
+)
+
+// softAuthenticator программный аутентификатор: ключ P-256, attestation "none",
+// флаги присутствия и проверки пользователя выставлены всегда
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// create ответ на navigator.credentials.create() для параметров, выданных BeginRegistration
+func (a *softAuthenticator) create(t *testing.T, options json.RawMessage) []byte {
+	t.Helper()
+
+	var opts struct {
+		Challenge string `json:"challenge"`
+		User      struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		t.Fatal(err)
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(opts.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := a.authData(flagUserPresent | flagUserVerified | flagAttestedData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(struct {
+		Fmt      string         `cbor:"fmt"`
+		AttStmt  map[string]any `cbor:"attStmt"`
+		AuthData []byte         `cbor:"authData"`
+	}{Fmt: "none", AttStmt: map[string]any{}, AuthData: authData})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    b64(clientData(t, "webauthn.create", opts.Challenge)),
+		"attestationObject": b64(attestation),
+	})
+}
+
+// get ответ на navigator.credentials.get() для параметров, выданных BeginLogin
+func (a *softAuthenticator) get(t *testing.T, options json.RawMessage) []byte {
+	t.Helper()
+
+	var opts struct {
+		Challenge string `json:"challenge"`
+	}
+	if err := json.Unmarshal(options, &opts); err != nil {
+		t.Fatal(err)
+	}
+
+	authData := a.authData(flagUserPresent | flagUserVerified)
+	data := clientData(t, "webauthn.get", opts.Challenge)
+	dataHash := sha256.Sum256(data)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), dataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    b64(data),
+		"authenticatorData": b64(authData),
+		"signature":         b64(signature),
+		"userHandle":        b64(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]any{
+		"id":       b64(a.credentialID),
+		"rawId":    b64(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func clientData(t *testing.T, ceremonyType, challenge string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremonyType,
+		"challenge": challenge,
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// memoryCeremonies Set/GetDel поверх map; остальные команды Redis сервису не нужны
+type memoryCeremonies struct {
+	redis.Cmdable
+
+	mu   sync.Mutex
+	data map[string]string
+}
+
+func (m *memoryCeremonies) Set(ctx context.Context, key string, value interface{}, _ time.Duration) *redis.StatusCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch v := value.(type) {
+	case []byte:
+		m.data[key] = string(v)
+	case string:
+		m.data[key] = v
+	}
+	cmd := redis.NewStatusCmd(ctx)
+	cmd.SetVal("OK")
+	return cmd
+}
+
+func (m *memoryCeremonies) GetDel(ctx context.Context, key string) *redis.StringCmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cmd := redis.NewStringCmd(ctx)
+	value, ok := m.data[key]
+	if !ok {
+		cmd.SetErr(redis.Nil)
+		return cmd
+	}
+	delete(m.data, key)
+	cmd.SetVal(value)
+	return cmd
+}
+
+type memoryCredentials struct {
+	mu    sync.Mutex
+	items map[string]*model.WebAuthnCredential
+}
+
+func (m *memoryCredentials) CreateCredential(_ context.Context, cred *model.WebAuthnCredential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.items {
+		if bytes.Equal(existing.CredentialID, cred.CredentialID) {
+			return model.ErrCredentialAlreadyExists
+		}
+	}
+	stored := *cred
+	m.items[cred.ID] = &stored
+	return nil
+}
+
+func (m *memoryCredentials) GetCredentialByCredentialID(_ context.Context, credentialID []byte) (*model.WebAuthnCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, cred := range m.items {
+		if bytes.Equal(cred.CredentialID, credentialID) {
+			found := *cred
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryCredentials) ListUserCredentials(_ context.Context, userID string) ([]*model.WebAuthnCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []*model.WebAuthnCredential
+	for _, cred := range m.items {
+		if cred.UserID == userID {
+			found := *cred
+			result = append(result, &found)
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryCredentials) UpdateCredentialUsage(_ context.Context, cred *model.WebAuthnCredential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.items[cred.ID]
+	if !ok {
+		return model.ErrCredentialNotFound
+	}
+	stored.SignCount = cred.SignCount
+	stored.CloneWarning = cred.CloneWarning
+	stored.Flags = cred.Flags
+	return nil
+}
+
+func (m *memoryCredentials) DeleteCredential(_ context.Context, userID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cred, ok := m.items[id]; ok && cred.UserID == userID {
+		delete(m.items, id)
+		return nil
+	}
+	return model.ErrCredentialNotFound
+}
+
+type staticUsers map[string]*model.User
+
+func (u staticUsers) GetUserByID(_ context.Context, id string) (*model.User, error) {
+	return u[id], nil
+}
+
+// staticMFA второй фактор с одним заранее известным кодом
+type staticMFA struct {
+	methods []string
+	code    string
+}
+
+func (m *staticMFA) Methods(context.Context, string) ([]string, error) {
+	return m.methods, nil
+}
+
+func (m *staticMFA) Verify(_ context.Context, _, _, code string) error {
+	if len(m.methods) == 0 {
+		return model.ErrMFANotEnabled
+	}
+	if code != m.code {
+		return model.ErrInvalidMFACode
+	}
+	return nil
+}
+
+type recordingIssuer struct {
+	sessions []*model.Session
+}
+
+func (i *recordingIssuer) IssueTokens(_ context.Context, session *model.Session) (*model.AuthTokens, error) {
+	i.sessions = append(i.sessions, session)
+	return &model.AuthTokens{AccessToken: "access", TokenType: "Bearer"}, nil
+}
+
+type webAuthnFixture struct {
+	service     *WebAuthnService
+	credentials *memoryCredentials
+	mfa         *staticMFA
+	issuer      *recordingIssuer
+}
+
+func newWebAuthnFixture(t *testing.T) *webAuthnFixture {
+	t.Helper()
+
+	relyingParty, err := newRelyingParty(testRPID, "Test", []string{testOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := util.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &webAuthnFixture{
+		credentials: &memoryCredentials{items: make(map[string]*model.WebAuthnCredential)},
+		mfa:         &staticMFA{},
+		issuer:      &recordingIssuer{},
+	}
+	f.service = &WebAuthnService{
+		credentialRepo: f.credentials,
+		userRepo:       staticUsers{testUserID: {ID: testUserID, Email: "user@example.com", Password: hash}},
+		mfaService:     f.mfa,
+		authService:    f.issuer,
+		redisClient:    &memoryCeremonies{data: make(map[string]string)},
+		limiter:        ratelimit.NewMemoryLimiter(nil),
+		webAuthn:       relyingParty,
+	}
+	return f
+}
+
+func (f *webAuthnFixture) register(t *testing.T, authenticator *softAuthenticator) *model.WebAuthnCredential {
+	t.Helper()
+
+	ctx := context.Background()
+	ceremony, err := f.service.BeginRegistration(ctx, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := f.service.FinishRegistration(ctx, testUserID, ceremony.CeremonyID, "laptop",
+		model.StepUp{Password: testPassword}, authenticator.create(t, ceremony.Options))
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	return cred
+}
+
+func (f *webAuthnFixture) login(t *testing.T, authenticator *softAuthenticator) error {
+	t.Helper()
+
+	ctx := context.Background()
+	ceremony, err := f.service.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.service.FinishLogin(ctx, ceremony.CeremonyID, authenticator.get(t, ceremony.Options), "test", "192.0.2.1")
+	return err
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	f := newWebAuthnFixture(t)
+	authenticator := newSoftAuthenticator(t)
+
+	cred := f.register(t, authenticator)
+	if cred.UserID != testUserID || !bytes.Equal(cred.CredentialID, authenticator.credentialID) {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+
+	for i := 1; i <= 3; i++ {
+		authenticator.signCount = uint32(i)
+		if err := f.login(t, authenticator); err != nil {
+			t.Fatalf("login %d: %v", i, err)
+		}
+	}
+
+	if len(f.issuer.sessions) != 3 || f.issuer.sessions[0].UserID != testUserID {
+		t.Fatalf("expected three sessions for %s, got %+v", testUserID, f.issuer.sessions)
+	}
+	stored, _ := f.credentials.GetCredentialByCredentialID(context.Background(), authenticator.credentialID)
+	if stored.SignCount != 3 || stored.CloneWarning {
+		t.Fatalf("expected sign count 3 without clone warning, got %d/%v", stored.SignCount, stored.CloneWarning)
+	}
+}
+
+func TestWebAuthnSignCounterRegression(t *testing.T) {
+	f := newWebAuthnFixture(t)
+	authenticator := newSoftAuthenticator(t)
+	f.register(t, authenticator)
+
+	authenticator.signCount = 5
+	if err := f.login(t, authenticator); err != nil {
+		t.Fatal(err)
+	}
+
+	// Копия аутентификатора со старым счетчиком
+	authenticator.signCount = 3
+	if err := f.login(t, authenticator); !errors.Is(err, model.ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials for counter regression, got %v", err)
+	}
+	stored, _ := f.credentials.GetCredentialByCredentialID(context.Background(), authenticator.credentialID)
+	if !stored.CloneWarning {
+		t.Fatal("expected clone warning to be stored")
+	}
+
+	// Ключ с предупреждением больше не принимается, даже если счетчик снова растет
+	authenticator.signCount = 10
+	if err := f.login(t, authenticator); !errors.Is(err, model.ErrInvalidCredentials) {
+		t.Fatalf("expected flagged credential to be rejected, got %v", err)
+	}
+	if len(f.issuer.sessions) != 1 {
+		t.Fatalf("expected only the first login to issue tokens, got %d", len(f.issuer.sessions))
+	}
+}
+
+func TestWebAuthnChallengeReuse(t *testing.T) {
+	ctx := context.Background()
+	f := newWebAuthnFixture(t)
+	authenticator := newSoftAuthenticator(t)
+	f.register(t, authenticator)
+
+	ceremony, err := f.service.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.signCount = 1
+	response := authenticator.get(t, ceremony.Options)
+	if _, err := f.service.FinishLogin(ctx, ceremony.CeremonyID, response, "test", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Тот же ceremony_id второй раз
+	if _, err := f.service.FinishLogin(ctx, ceremony.CeremonyID, response, "test", "192.0.2.1"); !errors.Is(err, model.ErrWebAuthnCeremonyFailed) {
+		t.Fatalf("expected ErrWebAuthnCeremonyFailed on ceremony reuse, got %v", err)
+	}
+
+	// Старая подпись против нового challenge
+	fresh, err := f.service.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.FinishLogin(ctx, fresh.CeremonyID, response, "test", "192.0.2.1"); !errors.Is(err, model.ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials on replayed assertion, got %v", err)
+	}
+
+	// Challenge регистрации нельзя выдать за вход
+	registration, err := f.service.BeginRegistration(ctx, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.FinishLogin(ctx, registration.CeremonyID, authenticator.get(t, registration.Options), "test", "192.0.2.1"); !errors.Is(err, model.ErrWebAuthnCeremonyFailed) {
+		t.Fatalf("expected ErrWebAuthnCeremonyFailed for registration ceremony, got %v", err)
+	}
+
+	if len(f.issuer.sessions) != 1 {
+		t.Fatalf("expected exactly one issued session, got %d", len(f.issuer.sessions))
+	}
+}
+
+func TestWebAuthnRegistrationStepUp(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		methods []string
+		stepUp  model.StepUp
+		want    error
+	}{
+		{"no password", nil, model.StepUp{}, model.ErrStepUpRequired},
+		{"wrong password", nil, model.StepUp{Password: "wrong"}, model.ErrInvalidCredentials},
+		{"password only", nil, model.StepUp{Password: testPassword}, nil},
+		{"mfa code missing", []string{model.MFAMethodTOTP}, model.StepUp{Password: testPassword}, model.ErrStepUpRequired},
+		{"mfa code wrong", []string{model.MFAMethodTOTP}, model.StepUp{Password: testPassword, MFAMethod: model.MFAMethodTOTP, MFACode: "000000"}, model.ErrInvalidMFACode},
+		{"password and mfa", []string{model.MFAMethodTOTP}, model.StepUp{Password: testPassword, MFAMethod: model.MFAMethodTOTP, MFACode: "123456"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newWebAuthnFixture(t)
+			f.mfa.methods, f.mfa.code = tt.methods, "123456"
+			authenticator := newSoftAuthenticator(t)
+
+			ceremony, err := f.service.BeginRegistration(ctx, testUserID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = f.service.FinishRegistration(ctx, testUserID, ceremony.CeremonyID, "laptop", tt.stepUp, authenticator.create(t, ceremony.Options))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+
+			stored, _ := f.credentials.ListUserCredentials(ctx, testUserID)
+			if (tt.want == nil) != (len(stored) == 1) {
+				t.Fatalf("expected credential stored=%v, got %d", tt.want == nil, len(stored))
+			}
+		})
+	}
+}
+
+func TestWebAuthnStepUpFailuresAreLimited(t *testing.T) {
+	ctx := context.Background()
+	f := newWebAuthnFixture(t)
+	authenticator := newSoftAuthenticator(t)
+
+	ceremony, err := f.service.BeginRegistration(ctx, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := authenticator.create(t, ceremony.Options)
+
+	for i := 0; i < passkeyStepUpFailures.Limit; i++ {
+		_, err := f.service.FinishRegistration(ctx, testUserID, ceremony.CeremonyID, "laptop", model.StepUp{Password: "wrong"}, response)
+		if !errors.Is(err, model.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected ErrInvalidCredentials, got %v", i+1, err)
+		}
+	}
+
+	// Даже верный пароль не проходит, пока окно не истекло; ceremony при этом не израсходована
+	_, err = f.service.FinishRegistration(ctx, testUserID, ceremony.CeremonyID, "laptop", model.StepUp{Password: testPassword}, response)
+	if !errors.Is(err, model.ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	webhookRequestTimeout = 10 * time.Second
+	webhookInitialBackoff = 1 * time.Second
+	webhookMaxBackoff     = 30 * time.Second
+)
+
+// WebhookSink отправляет события POST-запросом с JSON события. Тело подписывается HMAC-SHA256:
+// X-Audit-Signature: sha256=hex(HMAC(secret, "<X-Audit-Timestamp>.<body>")).
+// Сетевые ошибки, 408, 429 и 5xx повторяются с экспоненциальной задержкой.
+type WebhookSink struct {
+	url        string
+	secret     []byte
+	maxRetries int
+	backoff    time.Duration // Первая задержка перед повтором
+	client     *http.Client
+}
+
+func NewWebhookSink(url, secret string, maxRetries int) (*WebhookSink, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("audit webhook secret is required")
+	}
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	return &WebhookSink{
+		url:        url,
+		secret:     []byte(secret),
+		maxRetries: maxRetries,
+		backoff:    webhookInitialBackoff,
+		client:     &http.Client{Timeout: webhookRequestTimeout},
+	}, nil
+}
+
+func (s *WebhookSink) Send(ctx context.Context, event *model.AuditEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, event.ID, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.maxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, webhookMaxBackoff)
+	}
+}
+
+// post отправляет событие один раз; retry сообщает, имеет ли смысл повторить
+func (s *WebhookSink) post(ctx context.Context, eventID string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Audit-Event-ID", eventID) // Для дедупликации повторов на стороне получателя
+	req.Header.Set("X-Audit-Timestamp", timestamp)
+	req.Header.Set("X-Audit-Signature", "sha256="+s.sign(timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook responded with %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+}
+
+func (s *WebhookSink) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"authorization_authentication/internal/model"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookSinkSignature(t *testing.T) {
+	const secret = "webhook-secret"
+
+	var (
+		mu      sync.Mutex
+		checked bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Audit-Timestamp")
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if got := r.Header.Get("X-Audit-Signature"); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		if got := r.Header.Get("X-Audit-Event-ID"); got != "evt-1" {
+			t.Errorf("event id header = %q", got)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content type = %q", r.Header.Get("Content-Type"))
+		}
+		checked = true
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhookSink(server.URL, secret, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err := sink.Send(context.Background(), testAuditEvent("evt-1", model.AuditOutcomeSuccess)); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !checked {
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // Ответы по порядку; последний повторяется
+		retries  int
+		attempts int
+		wantErr  bool
+	}{
+		{"success", []int{200}, 3, 1, false},
+		{"5xx then success", []int{503, 500, 204}, 3, 3, false},
+		{"429 then success", []int{429, 200}, 3, 2, false},
+		{"408 then success", []int{408, 200}, 3, 2, false},
+		{"400 is not retried", []int{400, 200}, 3, 1, true},
+		{"401 is not retried", []int{401, 200}, 3, 1, true},
+		{"404 is not retried", []int{404, 200}, 3, 1, true},
+		{"retries exhausted", []int{502}, 2, 3, true},
+		{"no retries", []int{503, 200}, 0, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				attempts int
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				status := tt.statuses[min(attempts, len(tt.statuses)-1)]
+				attempts++
+				mu.Unlock()
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			sink, err := NewWebhookSink(server.URL, "secret", tt.retries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink.backoff = time.Millisecond
+			defer sink.Close()
+
+			err = sink.Send(context.Background(), testAuditEvent("evt", model.AuditOutcomeSuccess))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if attempts != tt.attempts {
+				t.Fatalf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestWebhookSinkRequiresSecret(t *testing.T) {
+	if _, err := NewWebhookSink("http://127.0.0.1", "", 0); err == nil {
+		t.Fatal("expected an error without a secret")
+	}
+}
@@ -0,0 +1,128 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// CIDRList набор сетей для списков разрешенных и запрещенных адресов.
+// Пустой или nil список ничего не содержит.
+type CIDRList struct {
+	prefixes []netip.Prefix
+}
+
+// ParseCIDRList разбирает сети вида "203.0.113.0/24" или отдельные адреса. Пустые строки
+// и строки с # пропускаются.
+func ParseCIDRList(lines []string) (*CIDRList, error) {
+	list := &CIDRList{}
+	for i, line := range lines {
+		text := strings.TrimSpace(line)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		prefix, err := parseCIDR(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		list.prefixes = append(list.prefixes, prefix)
+	}
+	return list, nil
+}
+
+// LoadCIDRList читает сети из файла, по одной в строке
+func LoadCIDRList(path string) (*CIDRList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	list, err := ParseCIDRList(lines)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return list, nil
+}
+
+// Contains сообщает, входит ли адрес в одну из сетей списка
+func (l *CIDRList) Contains(ip string) bool {
+	if l == nil {
+		return false
+	}
+
+	normalized, err := NormalizeIP(ip)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(normalized)
+	if err != nil {
+		return false
+	}
+
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings сети списка в каноническом виде
+func (l *CIDRList) Strings() []string {
+	if l == nil {
+		return []string{}
+	}
+
+	result := make([]string, 0, len(l.prefixes))
+	for _, prefix := range l.prefixes {
+		result = append(result, prefix.String())
+	}
+	return result
+}
+
+// ParseNetwork приводит сеть или отдельный адрес к каноническому виду "203.0.113.0/24"
+func ParseNetwork(value string) (string, error) {
+	prefix, err := parseCIDR(strings.TrimSpace(value))
+	if err != nil {
+		return "", err
+	}
+	return prefix.String(), nil
+}
+
+// parseCIDR отдельный адрес считается сетью из одного адреса; IPv4 в IPv6 приводится к IPv4
+func parseCIDR(value string) (netip.Prefix, error) {
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if prefix.Addr().Is4In6() {
+		bits := prefix.Bits() - 96
+		if bits < 0 {
+			return netip.Prefix{}, fmt.Errorf("invalid IPv4-mapped prefix %q", value)
+		}
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), bits)
+	}
+	return prefix.Masked(), nil
+}
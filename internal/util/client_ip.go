@@ -0,0 +1,137 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Заголовки, в которых прокси передают адрес клиента
+const (
+	HeaderForwarded     = "Forwarded" // RFC 7239
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderXRealIP       = "X-Real-IP"
+)
+
+// ClientIPResolver определяет адрес клиента с учетом доверенных прокси. Заголовок читается,
+// только если запрос пришел от доверенного прокси, и проходится справа налево: первый адрес
+// не из доверенных сетей — клиент. Все, что левее, мог дописать сам клиент.
+//
+// Читается только один заголовок — тот, который выставляет прокси. Если проверять несколько,
+// клиент подставит свое значение в тот, который прокси не трогает.
+type ClientIPResolver struct {
+	trusted *CIDRList
+	header  string
+}
+
+// NewClientIPResolver trustedProxies — сети или адреса прокси. Без них заголовки игнорируются
+// и клиентом считается адрес соединения.
+func NewClientIPResolver(trustedProxies []string, header string) (*ClientIPResolver, error) {
+	trusted, err := ParseCIDRList(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+
+	canonical := http.CanonicalHeaderKey(header)
+	switch canonical {
+	case HeaderForwarded, HeaderXForwardedFor, http.CanonicalHeaderKey(HeaderXRealIP):
+	default:
+		return nil, fmt.Errorf("trusted proxies: unsupported client IP header %q", header)
+	}
+
+	return &ClientIPResolver{trusted: trusted, header: canonical}, nil
+}
+
+// Resolve нормализованный адрес клиента без порта
+func (c *ClientIPResolver) Resolve(r *http.Request) string {
+	remote, err := NormalizeIP(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	if !c.FromTrustedProxy(r) {
+		return remote
+	}
+
+	var hops []string
+	values := r.Header.Values(c.header)
+	switch c.header {
+	case HeaderForwarded:
+		hops = forwardedFor(values)
+	case HeaderXForwardedFor:
+		for _, value := range values {
+			hops = append(hops, strings.Split(value, ",")...)
+		}
+	default:
+		// X-Real-IP — один адрес; несколько строк заголовка считаем цепочкой
+		hops = values
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := NormalizeIP(strings.Trim(strings.TrimSpace(hops[i]), "[]"))
+		if err != nil {
+			// Нечитаемое звено ("unknown", обфусцированное имя): дальше цепочке верить нельзя,
+			// клиентом считается последний известный адрес
+			return client
+		}
+		client = hop
+		if !c.trusted.Contains(hop) {
+			return client
+		}
+	}
+	return client
+}
+
+// FromTrustedProxy запрос пришел от доверенного прокси, и его заголовкам (X-Forwarded-Proto и т.п.)
+// можно верить
+func (c *ClientIPResolver) FromTrustedProxy(r *http.Request) bool {
+	remote, err := NormalizeIP(r.RemoteAddr)
+	return err == nil && c != nil && c.trusted.Contains(remote)
+}
+
+// forwardedFor значения параметра for из элементов заголовка Forwarded, слева направо.
+// Элемент без for дает пустое звено, чтобы не пропустить его при проходе справа.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			hop := ""
+			for _, pair := range splitQuoted(element, ';') {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(strings.TrimSpace(key), "for") {
+					hop = forwardedNode(strings.Trim(strings.TrimSpace(val), `"`))
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// forwardedNode адрес из узла RFC 7239: "192.0.2.60", "192.0.2.60:4711", "[2001:db8::1]:4711"
+func forwardedNode(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return strings.Trim(node, "[]")
+}
+
+// splitQuoted делит строку по sep вне кавычек
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
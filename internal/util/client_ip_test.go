@@ -0,0 +1,292 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestClientIPResolverResolve(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "2001:db8:ffff::/48"}
+
+	tests := []struct {
+		name    string
+		header  string
+		remote  string
+		values  []string // Строки заголовка по порядку
+		want    string
+		trusted []string // nil — trusted выше
+	}{
+		{
+			name:   "untrusted remote ignores header",
+			header: HeaderXForwardedFor,
+			remote: "203.0.113.9:5000",
+			values: []string{"198.51.100.1"},
+			want:   "203.0.113.9",
+		},
+		{
+			name:    "no trusted proxies ignores header",
+			header:  HeaderXForwardedFor,
+			remote:  "10.0.0.1:5000",
+			values:  []string{"198.51.100.1"},
+			want:    "10.0.0.1",
+			trusted: []string{},
+		},
+		{
+			name:   "single proxy",
+			header: HeaderXForwardedFor,
+			remote: "10.0.0.1:5000",
+			values: []string{"198.51.100.1"},
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "spoofed left-hand entries are skipped",
+			header: HeaderXForwardedFor,
+			remote: "10.0.0.1:5000",
+			values: []string{"1.2.3.4, 127.0.0.1, 198.51.100.1"},
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "chain of trusted proxies",
+			header: HeaderXForwardedFor,
+			remote: "10.0.0.1:5000",
+			values: []string{"1.2.3.4, 198.51.100.1, 10.1.1.1, 10.2.2.2"},
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "spoofed trusted address on the left",
+			header: HeaderXForwardedFor,
+			remote: "10.0.0.1:5000",
+			values: []string{"10.9.9.9, 198.51.100.1"},
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "multiple header lines form one chain",
+			header: HeaderXForwardedFor,
+			remote: "10.0.0.1:5000",
+			values: []string{"1.2.3.4", "198.51.100.1, 10.1.1.1"},
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "all hops trusted",
+			header: HeaderXForwardedFor,
+			remote: "10.0.0.1:5000",
+			values: []string{"10.3.3.3, 10.2.2.2"},
+			want:   "10.3.3.3",
+		},
+		{
+			name:   "unknown hop stops the walk",
+			header: HeaderXForwardedFor,
+			remote: "10.0.0.1:5000",
+			values: []string{"198.51.100.1, unknown, 10.1.1.1"},
+			want:   "10.1.1.1",
+		},
+		{
+			name:   "garbage right after the proxy",
+			header: HeaderXForwardedFor,
+			remote: "10.0.0.1:5000",
+			values: []string{"198.51.100.1, not-an-ip"},
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "empty header",
+			header: HeaderXForwardedFor,
+			remote: "10.0.0.1:5000",
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "IPv6 in X-Forwarded-For is normalized",
+			header: HeaderXForwardedFor,
+			remote: "[2001:db8:ffff::1]:443",
+			values: []string{"2001:DB8:0:0::1"},
+			want:   "2001:db8::1",
+		},
+		{
+			name:   "X-Real-IP",
+			header: HeaderXRealIP,
+			remote: "10.0.0.1:5000",
+			values: []string{"198.51.100.1"},
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "other headers are not read",
+			header: HeaderXRealIP,
+			remote: "10.0.0.1:5000",
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "Forwarded",
+			header: HeaderForwarded,
+			remote: "10.0.0.1:5000",
+			values: []string{`for=1.2.3.4, for=198.51.100.1;proto=https;by=10.0.0.1`},
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "Forwarded quoted IPv6 with port",
+			header: HeaderForwarded,
+			remote: "10.0.0.1:5000",
+			values: []string{`for="[2001:db8::1]:4711"`},
+			want:   "2001:db8::1",
+		},
+		{
+			name:   "Forwarded IPv4 with port and case-insensitive key",
+			header: HeaderForwarded,
+			remote: "10.0.0.1:5000",
+			values: []string{`For="198.51.100.1:4711"`},
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "Forwarded obfuscated node stops the walk",
+			header: HeaderForwarded,
+			remote: "10.0.0.1:5000",
+			values: []string{`for=198.51.100.1, for=_hidden, for=10.1.1.1`},
+			want:   "10.1.1.1",
+		},
+		{
+			name:   "Forwarded unknown node",
+			header: HeaderForwarded,
+			remote: "10.0.0.1:5000",
+			values: []string{`for=198.51.100.1, for=unknown`},
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "Forwarded element without for",
+			header: HeaderForwarded,
+			remote: "10.0.0.1:5000",
+			values: []string{`for=198.51.100.1, proto=https`},
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "Forwarded separators inside quotes",
+			header: HeaderForwarded,
+			remote: "10.0.0.1:5000",
+			values: []string{`for=1.2.3.4;ext="a,for=9.9.9.9;b", for=198.51.100.1`},
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "Forwarded multiple header lines",
+			header: HeaderForwarded,
+			remote: "10.0.0.1:5000",
+			values: []string{`for=1.2.3.4`, `for=198.51.100.1, for=10.1.1.1`},
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "unparsable remote address is returned as is",
+			header: HeaderXForwardedFor,
+			remote: "pipe",
+			values: []string{"198.51.100.1"},
+			want:   "pipe",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxies := trusted
+			if tt.trusted != nil {
+				proxies = tt.trusted
+			}
+			resolver, err := NewClientIPResolver(proxies, tt.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, value := range tt.values {
+				r.Header.Add(tt.header, value)
+			}
+			// Заголовки, которые резолвер с другой настройкой читать не должен
+			if tt.header != HeaderXRealIP {
+				r.Header.Set(HeaderXRealIP, "192.0.2.66")
+			}
+			if tt.header != HeaderXForwardedFor {
+				r.Header.Set(HeaderXForwardedFor, "192.0.2.67")
+			}
+
+			if got := resolver.Resolve(r); got != tt.want {
+				t.Fatalf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPResolverNil(t *testing.T) {
+	var resolver *ClientIPResolver
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set(HeaderXForwardedFor, "198.51.100.1")
+
+	if got := resolver.Resolve(r); got != "10.0.0.1" {
+		t.Fatalf("Resolve() = %q", got)
+	}
+	if resolver.FromTrustedProxy(r) {
+		t.Fatal("nil resolver trusts nobody")
+	}
+}
+
+func TestNewClientIPResolverValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		header  string
+		wantErr bool
+	}{
+		{"lower-case header", []string{"10.0.0.0/8"}, "x-forwarded-for", false},
+		{"x-real-ip", nil, "x-real-ip", false},
+		{"unsupported header", nil, "X-Client-IP", true},
+		{"invalid network", []string{"10.0.0.0/33"}, HeaderXForwardedFor, true},
+		{"invalid address", []string{"proxy.local"}, HeaderXForwardedFor, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClientIPResolver(tt.proxies, tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestForwardedFor(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		{[]string{`for=192.0.2.60`}, []string{"192.0.2.60"}},
+		{[]string{`for="[2001:db8::1]:4711"`}, []string{"2001:db8::1"}},
+		{[]string{`for="[2001:db8::1]"`}, []string{"2001:db8::1"}},
+		{[]string{`for=192.0.2.60:80;proto=http`}, []string{"192.0.2.60"}},
+		{[]string{` for = 192.0.2.60 ; by=10.0.0.1 `}, []string{"192.0.2.60"}},
+		{[]string{`proto=https, for=unknown`}, []string{"", "unknown"}},
+		{[]string{`for=_gazonk`}, []string{"_gazonk"}},
+		{[]string{`for=1.1.1.1`, `for=2.2.2.2, for=3.3.3.3`}, []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"}},
+		{[]string{`for="a\",b";x=1, for=4.4.4.4`}, []string{`a\",b`, "4.4.4.4"}},
+	}
+	for _, tt := range tests {
+		if got := forwardedFor(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("forwardedFor(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+	tests := []struct {
+		in   string
+		sep  byte
+		want []string
+	}{
+		{"a,b,c", ',', []string{"a", "b", "c"}},
+		{"", ',', []string{""}},
+		{"a,", ',', []string{"a", ""}},
+		{`a="x,y",b`, ',', []string{`a="x,y"`, "b"}},
+		{`a="x\"y,z",b`, ',', []string{`a="x\"y,z"`, "b"}},
+		{`a=1;b="2;3"`, ';', []string{"a=1", `b="2;3"`}},
+		{`a="unterminated,b`, ',', []string{`a="unterminated,b`}},
+	}
+	for _, tt := range tests {
+		if got := splitQuoted(tt.in, tt.sep); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitQuoted(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"bufio"
+	"errors"
+	"net/mail"
+	"os"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+var (
+	ErrInvalidEmail    = errors.New("invalid email address")
+	ErrEmailNotAllowed = errors.New("email addresses from this domain are not accepted")
+)
+
+const maxEmailLength = 254 // RFC 5321: ограничение длины пути
+
+// NormalizeEmail проверяет адрес по RFC 5322 и приводит его к каноническому виду:
+// нижний регистр, домен в ASCII (punycode). Имя ("Alice <a@x.com>") не допускается — только сам адрес.
+func NormalizeEmail(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+
+	addr, err := mail.ParseAddress(raw)
+	if err != nil || addr.Name != "" || addr.Address != raw {
+		return "", ErrInvalidEmail
+	}
+
+	at := strings.LastIndex(addr.Address, "@")
+	local, domain := addr.Address[:at], addr.Address[at+1:]
+
+	asciiDomain, err := idna.Lookup.ToASCII(domain)
+	if err != nil || !strings.Contains(asciiDomain, ".") {
+		return "", ErrInvalidEmail
+	}
+
+	normalized := strings.ToLower(local) + "@" + strings.ToLower(asciiDomain)
+	if len(normalized) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+	return normalized, nil
+}
+
+// DomainBlocklist домены одноразовой почты, с которых нельзя регистрироваться.
+// Поддомены заблокированного домена тоже блокируются. Пустой или nil список ничего не блокирует.
+type DomainBlocklist struct {
+	domains map[string]struct{}
+}
+
+func NewDomainBlocklist(domains []string) *DomainBlocklist {
+	b := &DomainBlocklist{domains: make(map[string]struct{}, len(domains))}
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" || strings.HasPrefix(d, "#") {
+			continue
+		}
+		if ascii, err := idna.Lookup.ToASCII(d); err == nil {
+			d = ascii
+		}
+		b.domains[d] = struct{}{}
+	}
+	return b
+}
+
+// LoadDomainBlocklist читает домены из файла, по одному в строке. Строки с # — комментарии.
+func LoadDomainBlocklist(path string) (*DomainBlocklist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		domains = append(domains, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewDomainBlocklist(domains), nil
+}
+
+// Check возвращает ErrEmailNotAllowed, если домен нормализованного адреса в списке
+func (b *DomainBlocklist) Check(email string) error {
+	if b == nil || len(b.domains) == 0 {
+		return nil
+	}
+
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for {
+		if _, blocked := b.domains[domain]; blocked {
+			return ErrEmailNotAllowed
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return nil
+		}
+		domain = domain[dot+1:]
+	}
+}
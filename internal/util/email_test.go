@@ -0,0 +1,136 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{"already canonical", "user@example.com", "user@example.com", false},
+		{"lowercased", "User.Name@Example.COM", "user.name@example.com", false},
+		{"surrounding spaces", "  user@example.com\t", "user@example.com", false},
+		{"plus tag kept", "user+tag@example.com", "user+tag@example.com", false},
+		{"IDNA domain", "user@bücher.de", "user@xn--bcher-kva.de", false},
+		{"IDNA upper-case domain", "user@BÜCHER.DE", "user@xn--bcher-kva.de", false},
+		{"IDNA cyrillic domain", "user@пример.рф", "user@xn--e1afmkfd.xn--p1ai", false},
+		{"punycode domain kept", "user@xn--bcher-kva.de", "user@xn--bcher-kva.de", false},
+		{"empty", "", "", true},
+		{"no at sign", "plainaddress", "", true},
+		{"display name", "Alice <alice@example.com>", "", true},
+		{"angle brackets", "<alice@example.com>", "", true},
+		{"two at signs", "a@@example.com", "", true},
+		{"space in local part", "a b@example.com", "", true},
+		{"empty local part", "@example.com", "", true},
+		{"dot-less domain", "user@localhost", "", true},
+		{"empty domain label", "user@example..com", "", true},
+		{"trailing dot in local part", "user.@example.com", "", true},
+		{"comment", "user@example.com (work)", "", true},
+		{"two addresses", "a@example.com, b@example.com", "", true},
+		{"invalid IDNA label", "user@xn--zz.com", "", true},
+		{"too long", strings.Repeat("a", 64) + "@" + strings.Repeat("b", 190) + ".com", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeEmail(%q) = %q, %v; wantErr %v", tt.raw, got, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidEmail) {
+				t.Fatalf("err = %v, want ErrInvalidEmail", err)
+			}
+			if got != tt.want {
+				t.Fatalf("NormalizeEmail(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomainBlocklist(t *testing.T) {
+	blocklist := NewDomainBlocklist([]string{
+		"# одноразовая почта",
+		"",
+		"  Mailinator.com ",
+		"tempmail.io",
+		"пример.рф",
+	})
+
+	tests := []struct {
+		email   string
+		blocked bool
+	}{
+		{"user@mailinator.com", true},
+		{"user@sub.mailinator.com", true},
+		{"user@a.b.tempmail.io", true},
+		{"user@xn--e1afmkfd.xn--p1ai", true},
+		{"user@notmailinator.com", false},
+		{"user@mailinator.com.example.org", false},
+		{"user@example.com", false},
+		{"user@com", false},
+	}
+
+	for _, tt := range tests {
+		err := blocklist.Check(tt.email)
+		if tt.blocked && !errors.Is(err, ErrEmailNotAllowed) {
+			t.Errorf("Check(%q) = %v, want ErrEmailNotAllowed", tt.email, err)
+		}
+		if !tt.blocked && err != nil {
+			t.Errorf("Check(%q) = %v, want nil", tt.email, err)
+		}
+	}
+
+	// Адрес проверяется после нормализации: регистр и Unicode не обходят список
+	normalized, err := NormalizeEmail("User@MAILINATOR.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(blocklist.Check(normalized), ErrEmailNotAllowed) {
+		t.Errorf("normalized %q must be blocked", normalized)
+	}
+	normalized, err = NormalizeEmail("user@ПРИМЕР.РФ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(blocklist.Check(normalized), ErrEmailNotAllowed) {
+		t.Errorf("normalized %q must be blocked", normalized)
+	}
+}
+
+func TestDomainBlocklistEmpty(t *testing.T) {
+	for _, blocklist := range []*DomainBlocklist{nil, NewDomainBlocklist(nil), NewDomainBlocklist([]string{"# only comments"})} {
+		if err := blocklist.Check("user@mailinator.com"); err != nil {
+			t.Fatalf("empty blocklist blocked an address: %v", err)
+		}
+	}
+}
+
+func TestLoadDomainBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# disposable\nmailinator.com\n\n10minutemail.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	blocklist, err := LoadDomainBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(blocklist.Check("a@10minutemail.com"), ErrEmailNotAllowed) {
+		t.Fatal("domain from file must be blocked")
+	}
+	if err := blocklist.Check("a@example.com"); err != nil {
+		t.Fatalf("unexpected block: %v", err)
+	}
+
+	if _, err := LoadDomainBlocklist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
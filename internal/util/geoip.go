@@ -0,0 +1,82 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GeoIPTable примерное местоположение по диапазонам адресов. Побеждает самая узкая подходящая сеть.
+// nil-таблица ничего не находит.
+type GeoIPTable struct {
+	entries []geoIPEntry // По убыванию длины префикса
+}
+
+type geoIPEntry struct {
+	prefix   netip.Prefix
+	location string
+}
+
+// LoadGeoIPTable читает файл со строками "CIDR,Местоположение", например
+// "203.0.113.0/24,Berlin, DE". Пустые строки и строки с # пропускаются.
+func LoadGeoIPTable(path string) (*GeoIPTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	table := &GeoIPTable{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		cidr, location, ok := strings.Cut(text, ",")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected CIDR,location", path, line)
+		}
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		table.entries = append(table.entries, geoIPEntry{
+			prefix:   prefix.Masked(),
+			location: strings.TrimSpace(location),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(table.entries, func(i, j int) bool {
+		return table.entries[i].prefix.Bits() > table.entries[j].prefix.Bits()
+	})
+	return table, nil
+}
+
+// Lookup возвращает местоположение адреса или пустую строку, если он не найден
+func (t *GeoIPTable) Lookup(ip string) string {
+	if t == nil {
+		return ""
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	for _, entry := range t.entries {
+		if entry.prefix.Contains(addr) {
+			return entry.location
+		}
+	}
+	return ""
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"errors"
 	"net"
+	"net/netip"
 )
 
 var (
@@ -26,6 +27,29 @@ func NormalizeIP(rawIP string) (string, error) {
 	return ip.String(), nil
 }
 
+// IPPrefix сеть адреса с заданной длиной префикса для IPv4 и IPv6, например "2001:db8::/64".
+// Порт отбрасывается, невалидный адрес возвращается как есть.
+func IPPrefix(rawIP string, ipv4Bits, ipv6Bits int) string {
+	normalized, err := NormalizeIP(rawIP)
+	if err != nil {
+		return rawIP
+	}
+	addr, err := netip.ParseAddr(normalized)
+	if err != nil {
+		return rawIP
+	}
+
+	bits := ipv6Bits
+	if addr.Is4() {
+		bits = ipv4Bits
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return rawIP
+	}
+	return prefix.String()
+}
+
 // NormalizeIPWithPort сохраняет порт, но валидирует IP
 func NormalizeIPWithPort(rawIP string) (string, error) {
 	host, port, err := net.SplitHostPort(rawIP)
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+var (
+	ErrInvalidPhone       = errors.New("invalid phone number")
+	ErrPhoneNotAllowed    = errors.New("premium-rate and shared-cost phone numbers are not accepted")
+	ErrInvalidPhoneRegion = errors.New("unknown default phone region")
+)
+
+// PhoneNumber разобранный номер: канонический E.164 и страна, к которой он относится
+type PhoneNumber struct {
+	E164    string
+	Country string // ISO 3166-1 alpha-2, например "RU"
+}
+
+// PhoneNormalizer разбирает номера в национальном и международном формате.
+// Номера без "+" трактуются как номера региона по умолчанию.
+type PhoneNormalizer struct {
+	defaultRegion string
+}
+
+// NewPhoneNormalizer принимает регион по умолчанию (ISO 3166-1 alpha-2).
+// Пустой регион означает, что принимаются только номера в международном формате.
+func NewPhoneNormalizer(defaultRegion string) (*PhoneNormalizer, error) {
+	region := strings.ToUpper(strings.TrimSpace(defaultRegion))
+	if region != "" && !phonenumbers.GetSupportedRegions()[region] {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPhoneRegion, defaultRegion)
+	}
+	return &PhoneNormalizer{defaultRegion: region}, nil
+}
+
+// Parse проверяет номер и приводит его к E.164. Платные номера отклоняются:
+// на них можно накручивать SMS за наш счет.
+func (n *PhoneNormalizer) Parse(raw string) (*PhoneNumber, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, ErrInvalidPhone
+	}
+
+	region := n.defaultRegion
+	if region == "" {
+		region = "ZZ" // Неизвестный регион: без "+" номер не разберется
+	}
+
+	num, err := phonenumbers.Parse(raw, region)
+	if err != nil || !phonenumbers.IsValidNumber(num) {
+		return nil, ErrInvalidPhone
+	}
+
+	switch phonenumbers.GetNumberType(num) {
+	case phonenumbers.PREMIUM_RATE, phonenumbers.SHARED_COST:
+		return nil, ErrPhoneNotAllowed
+	}
+
+	return &PhoneNumber{
+		E164:    phonenumbers.Format(num, phonenumbers.E164),
+		Country: phonenumbers.GetRegionCodeForNumber(num),
+	}, nil
+}
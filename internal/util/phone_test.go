@@ -0,0 +1,76 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPhoneNormalizerParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		region      string
+		raw         string
+		wantE164    string
+		wantCountry string
+		wantErr     error
+	}{
+		{"national with default region", "RU", "8 (495) 123-45-67", "+74951234567", "RU", nil},
+		{"national mobile", "RU", "912 345 67 89", "+79123456789", "RU", nil},
+		{"international with default region", "RU", "+7 912 345-67-89", "+79123456789", "RU", nil},
+		{"international from another country", "RU", "+44 20 7031 3000", "+442070313000", "GB", nil},
+		{"US national", "US", "(650) 253-0000", "+16502530000", "US", nil},
+		{"surrounding spaces", "US", "  +1 650 253 0000  ", "+16502530000", "US", nil},
+		{"international without default region", "", "+49 30 123456", "+4930123456", "DE", nil},
+		{"national without default region", "", "(650) 253-0000", "", "", ErrInvalidPhone},
+		{"empty", "US", "", "", "", ErrInvalidPhone},
+		{"letters", "US", "call me", "", "", ErrInvalidPhone},
+		{"too short", "US", "12345", "", "", ErrInvalidPhone},
+		{"unassigned range", "RU", "+7 000 000 00 00", "", "", ErrInvalidPhone},
+		{"premium rate US", "US", "+1 900 253 0000", "", "", ErrPhoneNotAllowed},
+		{"premium rate UK", "GB", "0909 876 5432", "", "", ErrPhoneNotAllowed},
+		{"premium rate RU", "RU", "8 809 123 45 67", "", "", ErrPhoneNotAllowed},
+		{"shared cost FR", "FR", "08 21 61 07 00", "", "", ErrPhoneNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := NewPhoneNormalizer(tt.region)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := n.Parse(tt.raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.E164 != tt.wantE164 || got.Country != tt.wantCountry {
+				t.Fatalf("got %+v, want %s %s", *got, tt.wantE164, tt.wantCountry)
+			}
+		})
+	}
+}
+
+func TestNewPhoneNormalizerRegion(t *testing.T) {
+	tests := []struct {
+		region  string
+		wantErr bool
+	}{
+		{"", false},
+		{"RU", false},
+		{" us ", false},
+		{"XX", true},
+		{"Russia", true},
+	}
+	for _, tt := range tests {
+		_, err := NewPhoneNormalizer(tt.region)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewPhoneNormalizer(%q): err = %v, wantErr %v", tt.region, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidPhoneRegion) {
+			t.Errorf("NewPhoneNormalizer(%q): err = %v, want ErrInvalidPhoneRegion", tt.region, err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package util
+
+import "context"
+
+// RequestInfo сведения о входящем запросе, которые нужны глубже обработчиков (журнал аудита)
+type RequestInfo struct {
+	ID        string
+	IP        string
+	UserAgent string
+	Secure    bool // HTTPS напрямую или, по словам доверенного прокси, до него
+}
+
+type requestInfoKey struct{}
+
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext возвращает пустую структуру вне HTTP-запроса (фоновые задачи)
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
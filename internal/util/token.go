@@ -0,0 +1,33 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateRandomToken возвращает криптостойкую случайную строку из n байт в base64url
+func GenerateRandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashToken хеширует высокоэнтропийный токен для хранения.
+// bcrypt здесь не нужен: перебор случайных 256 бит невозможен и без соли.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashTokenHMAC хеширует токен с низкой энтропией (коды восстановления) секретным ключом.
+// Без ключа перебрать такие хеши из копии базы нельзя.
+func HashTokenHMAC(key []byte, token string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,140 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret ключ тестовых векторов SHA-1 из RFC 6238, Appendix B
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	// В RFC коды из 8 цифр; у нас 6 — это последние 6 цифр того же значения
+	tests := []struct {
+		unix int64
+		rfc  string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	key := []byte("12345678901234567890")
+	for _, tt := range tests {
+		want := tt.rfc[2:]
+		step := tt.unix / int64(TOTPPeriod.Seconds())
+
+		if got := hotp(key, step); got != want {
+			t.Errorf("hotp(T=%d) = %s, want %s", tt.unix, got, want)
+		}
+
+		gotStep, ok := ValidateTOTPCode(rfc6238Secret, want, time.Unix(tt.unix, 0))
+		if !ok || gotStep != step {
+			t.Errorf("ValidateTOTPCode(T=%d) = %d, %v; want %d, true", tt.unix, gotStep, ok, step)
+		}
+	}
+}
+
+func TestValidateTOTPCodeWindow(t *testing.T) {
+	// Код шага T=1111111111 (шаг 37037037)
+	const code = "050471"
+	const step = int64(37037037)
+	base := time.Unix(step*30, 0)
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		wantOK bool
+	}{
+		{"start of step", base, true},
+		{"end of step", base.Add(29 * time.Second), true},
+		{"one step later", base.Add(30 * time.Second), true},
+		{"end of next step", base.Add(59 * time.Second), true},
+		{"two steps later", base.Add(60 * time.Second), false},
+		{"one step earlier", base.Add(-1 * time.Second), true},
+		{"start of previous step", base.Add(-30 * time.Second), true},
+		{"two steps earlier", base.Add(-31 * time.Second), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := ValidateTOTPCode(rfc6238Secret, code, tt.now)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			// Шаг — тот, к которому подошел код, а не текущий: по нему отсекается повтор
+			if ok && gotStep != step {
+				t.Fatalf("step = %d, want %d", gotStep, step)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPCodeInput(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		wantOK bool
+	}{
+		{"valid", rfc6238Secret, "050471", true},
+		{"surrounding spaces", rfc6238Secret, " 050471 ", true},
+		{"lower-case secret", strings.ToLower(rfc6238Secret), "050471", true},
+		{"wrong code", rfc6238Secret, "050472", false},
+		{"too short", rfc6238Secret, "50471", false},
+		{"too long", rfc6238Secret, "14050471", false},
+		{"empty", rfc6238Secret, "", false},
+		{"invalid secret", "not base32!", "050471", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTPCode(tt.secret, tt.code, now); ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != totpSecretBytes {
+		t.Fatalf("secret %q: %d bytes, err %v", secret, len(key), err)
+	}
+
+	other, _ := GenerateTOTPSecret()
+	if other == secret {
+		t.Fatal("secrets must be random")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Auth Service", "user@example.com", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" || parsed.Path != "/Auth Service:user@example.com" {
+		t.Fatalf("unexpected URI %s", uri)
+	}
+	q := parsed.Query()
+	for key, want := range map[string]string{
+		"secret": "JBSWY3DPEHPK3PXP", "issuer": "Auth Service", "algorithm": "SHA1", "digits": "6", "period": "30",
+	} {
+		if q.Get(key) != want {
+			t.Errorf("%s = %q, want %q", key, q.Get(key), want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+-- Реестр OAuth-клиентов
+CREATE TABLE oauth_clients (
+                               id VARCHAR(64) PRIMARY KEY,
+                               name VARCHAR(255) NOT NULL,
+                               secret_hash VARCHAR(255), -- NULL у публичных клиентов (SPA, мобильные приложения)
+                               redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+                               grant_types TEXT[] NOT NULL DEFAULT '{}',
+                               scopes TEXT[] NOT NULL DEFAULT '{}',
+                               created_at TIMESTAMP DEFAULT NOW(),
+                               updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Сессии, выданные через OAuth, привязываем к клиенту
+ALTER TABLE sessions
+    ADD COLUMN client_id VARCHAR(64) REFERENCES oauth_clients(id) ON DELETE CASCADE,
+ADD COLUMN scope TEXT;
+
+CREATE INDEX idx_sessions_client_id ON sessions(client_id);
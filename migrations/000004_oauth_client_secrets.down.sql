@@ -0,0 +1,18 @@
+ALTER TABLE oauth_clients ADD COLUMN secret_hash VARCHAR(255);
+
+-- Возвращаем самый новый из действующих секретов
+UPDATE oauth_clients c
+SET secret_hash = (
+    SELECT s.secret_hash
+    FROM oauth_client_secrets s
+    WHERE s.client_id = c.id AND (s.expires_at IS NULL OR s.expires_at > NOW())
+    ORDER BY s.created_at DESC
+    LIMIT 1
+);
+
+ALTER TABLE oauth_clients
+DROP COLUMN IF EXISTS is_confidential,
+DROP COLUMN IF EXISTS is_service_account;
+
+DROP INDEX IF EXISTS idx_oauth_client_secrets_client_id;
+DROP TABLE IF EXISTS oauth_client_secrets;
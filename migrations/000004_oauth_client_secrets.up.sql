@@ -0,0 +1,24 @@
+-- Несколько секретов на клиента: при ротации старый секрет действует до expires_at
+CREATE TABLE oauth_client_secrets (
+                                      id UUID PRIMARY KEY,
+                                      client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+                                      secret_hash VARCHAR(255) NOT NULL,
+                                      created_at TIMESTAMP DEFAULT NOW(),
+                                      expires_at TIMESTAMP -- NULL: действует до следующей ротации
+);
+
+CREATE INDEX idx_oauth_client_secrets_client_id ON oauth_client_secrets(client_id);
+
+ALTER TABLE oauth_clients
+    ADD COLUMN is_confidential BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN is_service_account BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Переносим существующие секреты
+INSERT INTO oauth_client_secrets (id, client_id, secret_hash, created_at)
+SELECT gen_random_uuid(), id, secret_hash, created_at
+FROM oauth_clients
+WHERE secret_hash IS NOT NULL;
+
+UPDATE oauth_clients SET is_confidential = secret_hash IS NOT NULL;
+
+ALTER TABLE oauth_clients DROP COLUMN secret_hash;
@@ -0,0 +1,16 @@
+-- Персональные API-ключи пользователей
+CREATE TABLE api_keys (
+                          id UUID PRIMARY KEY,
+                          user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                          name VARCHAR(255) NOT NULL,
+                          prefix VARCHAR(16) NOT NULL UNIQUE, -- Открытая часть ключа, по ней ищем и показываем в списке
+                          secret_hash VARCHAR(64) NOT NULL,   -- SHA-256 секретной части
+                          scopes TEXT[] NOT NULL DEFAULT '{}',
+                          expires_at TIMESTAMP,
+                          last_used_at TIMESTAMP,
+                          last_used_ip VARCHAR(45),
+                          created_at TIMESTAMP DEFAULT NOW(),
+                          revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
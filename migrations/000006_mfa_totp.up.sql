@@ -0,0 +1,20 @@
+-- Второй фактор: TOTP
+CREATE TABLE user_mfa (
+                          user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+                          totp_secret BYTEA,                        -- Зашифровано AES-256-GCM
+                          totp_confirmed_at TIMESTAMP,              -- NULL, пока пользователь не подтвердил код
+                          totp_last_step BIGINT NOT NULL DEFAULT 0, -- Последний принятый шаг, защищает от повторного кода
+                          created_at TIMESTAMP DEFAULT NOW(),
+                          updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Одноразовые коды восстановления
+CREATE TABLE mfa_recovery_codes (
+                                    id UUID PRIMARY KEY,
+                                    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                    code_hash VARCHAR(64) NOT NULL,
+                                    used_at TIMESTAMP,
+                                    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
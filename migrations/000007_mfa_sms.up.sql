@@ -0,0 +1,3 @@
+-- Второй фактор: SMS-код на подтвержденный телефон
+ALTER TABLE user_mfa
+    ADD COLUMN sms_enabled BOOLEAN NOT NULL DEFAULT FALSE;
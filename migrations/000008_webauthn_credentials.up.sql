@@ -0,0 +1,18 @@
+-- Ключи WebAuthn (passkeys) пользователей
+CREATE TABLE webauthn_credentials (
+                                      id UUID PRIMARY KEY,
+                                      user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                      credential_id BYTEA NOT NULL UNIQUE,          -- rawId от аутентификатора
+                                      public_key BYTEA NOT NULL,                    -- COSE-ключ
+                                      attestation_type VARCHAR(32) NOT NULL DEFAULT '',
+                                      aaguid BYTEA,
+                                      sign_count BIGINT NOT NULL DEFAULT 0,         -- Счетчик подписей, защищает от клонов
+                                      clone_warning BOOLEAN NOT NULL DEFAULT FALSE,
+                                      transports TEXT[] NOT NULL DEFAULT '{}',
+                                      flags SMALLINT NOT NULL DEFAULT 0,            -- Флаги UP/UV/BE/BS
+                                      name VARCHAR(255) NOT NULL DEFAULT '',
+                                      created_at TIMESTAMP DEFAULT NOW(),
+                                      last_used_at TIMESTAMP
+);
+
+CREATE INDEX idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified;
+
+ALTER TABLE users ALTER COLUMN phone_verified DROP NOT NULL;
+
+ALTER TABLE users RENAME COLUMN phone_verified TO verified;
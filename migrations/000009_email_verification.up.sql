@@ -0,0 +1,8 @@
+-- verified всегда означал подтвержденный телефон; email подтверждается отдельно
+ALTER TABLE users RENAME COLUMN verified TO phone_verified;
+
+UPDATE users SET phone_verified = FALSE WHERE phone_verified IS NULL;
+
+ALTER TABLE users
+    ALTER COLUMN phone_verified SET NOT NULL,
+    ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_audit_events_user_id;
+DROP TABLE IF EXISTS audit_events;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS pending_email,
+DROP COLUMN IF EXISTS pending_phone;
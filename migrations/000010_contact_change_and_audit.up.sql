@@ -0,0 +1,18 @@
+-- Новый адрес/телефон хранится отдельно, пока не подтвержден
+ALTER TABLE users
+    ADD COLUMN pending_email VARCHAR(255),
+    ADD COLUMN pending_phone VARCHAR(20);
+
+-- Журнал значимых действий с аккаунтами
+CREATE TABLE audit_events (
+                              id UUID PRIMARY KEY,
+                              user_id UUID REFERENCES users(id) ON DELETE SET NULL, -- Чей аккаунт затронут
+                              actor_id UUID,                                        -- Кто совершил действие
+                              event_type VARCHAR(64) NOT NULL,
+                              ip VARCHAR(45),
+                              user_agent TEXT,
+                              metadata JSONB NOT NULL DEFAULT '{}',
+                              created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_events_user_id ON audit_events(user_id, created_at);
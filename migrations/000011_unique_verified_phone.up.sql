@@ -0,0 +1,23 @@
+-- Приводим номера к E.164: убираем оформление, 00 в начале заменяем на +
+UPDATE users
+SET phone = regexp_replace(regexp_replace(phone, '[\s().-]', '', 'g'), '^00', '+')
+WHERE phone IS NOT NULL;
+
+UPDATE users
+SET pending_phone = regexp_replace(regexp_replace(pending_phone, '[\s().-]', '', 'g'), '^00', '+')
+WHERE pending_phone IS NOT NULL;
+
+-- Раньше подтверждение номера отмечало все аккаунты с этим номером.
+-- Подтвержденным оставляем самый старый аккаунт, остальным подтверждение снимаем.
+UPDATE users u
+SET phone_verified = FALSE
+WHERE u.phone_verified
+  AND EXISTS (
+    SELECT 1 FROM users o
+    WHERE o.phone = u.phone
+      AND o.phone_verified
+      AND (o.created_at, o.id) < (u.created_at, u.id)
+);
+
+-- Один подтвержденный номер — один аккаунт
+CREATE UNIQUE INDEX idx_users_phone_verified ON users(phone) WHERE phone_verified;
@@ -0,0 +1,4 @@
+-- Страна номера (ISO 3166-1 alpha-2) для правил оценки риска.
+-- У существующих номеров заполняется при следующей смене телефона.
+ALTER TABLE users
+    ADD COLUMN phone_country VARCHAR(2);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_email_lower;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
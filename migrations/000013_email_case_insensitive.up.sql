@@ -0,0 +1,19 @@
+-- Адреса, отличающиеся только регистром, раньше создавали разные аккаунты.
+-- Такие дубликаты нужно разобрать вручную: автоматически сливать аккаунты нельзя.
+DO $$
+DECLARE
+    duplicates INTEGER;
+BEGIN
+    SELECT COUNT(*) INTO duplicates
+    FROM (SELECT lower(email) FROM users GROUP BY lower(email) HAVING COUNT(*) > 1) d;
+
+    IF duplicates > 0 THEN
+        RAISE EXCEPTION '% email addresses are registered more than once with different case, resolve them before migrating', duplicates;
+    END IF;
+END $$;
+
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+UPDATE users SET pending_email = lower(pending_email) WHERE pending_email <> lower(pending_email);
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX idx_users_email_lower ON users(lower(email));
@@ -0,0 +1,6 @@
+-- Профиль пользователя, редактируется через PATCH /me
+ALTER TABLE users
+    ADD COLUMN display_name VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT '',    -- BCP 47, например "ru-RU"
+    ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '',  -- Имя из базы IANA, например "Europe/Moscow"
+    ADD COLUMN avatar_url VARCHAR(2048) NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- Мягкое удаление: аккаунт удаляется окончательно после периода ожидания
+ALTER TABLE users
+    ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
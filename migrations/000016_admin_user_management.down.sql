@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_users_phone_pattern;
+DROP INDEX IF EXISTS idx_users_email_pattern;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS password_reset_required,
+DROP COLUMN IF EXISTS locked_at,
+DROP COLUMN IF EXISTS role;
@@ -0,0 +1,11 @@
+-- Роль, блокировка и принудительная смена пароля для администрирования аккаунтов.
+-- Первого администратора назначают вручную:
+--   UPDATE users SET role = 'admin' WHERE lower(email) = lower('ops@example.com');
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user',
+    ADD COLUMN locked_at TIMESTAMP,
+    ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Поиск пользователей по началу адреса и номера
+CREATE INDEX idx_users_email_pattern ON users(lower(email) text_pattern_ops);
+CREATE INDEX idx_users_phone_pattern ON users(phone text_pattern_ops);
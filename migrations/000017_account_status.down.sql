@@ -0,0 +1,14 @@
+ALTER TABLE users
+DROP CONSTRAINT IF EXISTS users_pending_deletion_check,
+DROP CONSTRAINT IF EXISTS users_suspended_until_check,
+DROP CONSTRAINT IF EXISTS users_status_check;
+
+ALTER TABLE users
+    ADD COLUMN locked_at TIMESTAMP;
+
+UPDATE users SET locked_at = NOW() WHERE status IN ('suspended', 'disabled');
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS suspended_until,
+DROP COLUMN IF EXISTS status_reason,
+DROP COLUMN IF EXISTS status;
@@ -0,0 +1,19 @@
+-- Состояние аккаунта вместо отдельной отметки о блокировке
+ALTER TABLE users
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active',
+    ADD COLUMN status_reason TEXT,
+    ADD COLUMN suspended_until TIMESTAMP;
+
+UPDATE users SET status = 'disabled', status_reason = 'locked by administrator' WHERE locked_at IS NOT NULL;
+UPDATE users SET status = 'pending_deletion' WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE users
+DROP COLUMN locked_at;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_status_check
+        CHECK (status IN ('active', 'suspended', 'disabled', 'pending_deletion')),
+    ADD CONSTRAINT users_suspended_until_check
+        CHECK (status <> 'suspended' OR suspended_until IS NOT NULL),
+    ADD CONSTRAINT users_pending_deletion_check
+        CHECK ((status = 'pending_deletion') = (deleted_at IS NOT NULL));
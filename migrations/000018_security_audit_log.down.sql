@@ -0,0 +1,12 @@
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
+
+DROP INDEX IF EXISTS idx_audit_events_type;
+DROP INDEX IF EXISTS idx_audit_events_created_at;
+
+ALTER TABLE audit_events
+DROP CONSTRAINT IF EXISTS audit_events_outcome_check,
+DROP COLUMN IF EXISTS request_id,
+DROP COLUMN IF EXISTS reason,
+DROP COLUMN IF EXISTS outcome,
+DROP COLUMN IF EXISTS session_id;
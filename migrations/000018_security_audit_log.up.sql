@@ -0,0 +1,29 @@
+-- Журнал событий безопасности: исход, причина и запрос, в котором произошло событие
+ALTER TABLE audit_events
+    ADD COLUMN session_id UUID,
+    ADD COLUMN outcome VARCHAR(16) NOT NULL DEFAULT 'success',
+    ADD COLUMN reason VARCHAR(64),
+    ADD COLUMN request_id VARCHAR(64);
+
+ALTER TABLE audit_events
+    ALTER COLUMN created_at SET NOT NULL,
+    ADD CONSTRAINT audit_events_outcome_check CHECK (outcome IN ('success', 'failure'));
+
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX idx_audit_events_type ON audit_events(event_type, created_at);
+
+-- Журнал только дополняется. Единственное допустимое изменение — обнуление user_id
+-- при окончательном удалении пользователя (ON DELETE SET NULL).
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.user_id IS NULL
+        AND (to_jsonb(NEW) - 'user_id') = (to_jsonb(OLD) - 'user_id') THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
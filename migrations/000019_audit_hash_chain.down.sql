@@ -0,0 +1,26 @@
+DROP TABLE IF EXISTS audit_checkpoints;
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.user_id IS NULL
+        AND (to_jsonb(NEW) - 'user_id') = (to_jsonb(OLD) - 'user_id') THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- События удаленных пользователей теряют user_id, иначе ограничение не создать
+ALTER TABLE audit_events DISABLE TRIGGER audit_events_append_only;
+UPDATE audit_events SET user_id = NULL
+WHERE user_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM users WHERE users.id = audit_events.user_id);
+ALTER TABLE audit_events ENABLE TRIGGER audit_events_append_only;
+
+ALTER TABLE audit_events
+    ADD CONSTRAINT audit_events_user_id_fkey
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE audit_events
+DROP COLUMN IF EXISTS hash,
+DROP COLUMN IF EXISTS prev_hash,
+DROP COLUMN IF EXISTS seq;
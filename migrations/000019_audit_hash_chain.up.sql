@@ -0,0 +1,25 @@
+-- Цепочка хешей: каждое событие хранит хеш своего содержимого вместе с хешем предыдущего.
+-- События до этой миграции остаются вне цепочки (seq IS NULL).
+ALTER TABLE audit_events
+    ADD COLUMN seq BIGINT UNIQUE,
+    ADD COLUMN prev_hash CHAR(64),
+    ADD COLUMN hash CHAR(64);
+
+-- Окончательное удаление пользователя больше не меняет его события (ON DELETE SET NULL
+-- изменил бы захешированное содержимое). Журнал теперь не меняется вовсе.
+ALTER TABLE audit_events
+DROP CONSTRAINT IF EXISTS audit_events_user_id_fkey;
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Подписанные ключом JWT отметки о состоянии цепочки
+CREATE TABLE audit_checkpoints (
+                                   seq BIGINT PRIMARY KEY,       -- Последнее событие, покрытое отметкой
+                                   hash CHAR(64) NOT NULL,       -- Его хеш
+                                   signature TEXT NOT NULL,      -- JWS (RS256) с seq и hash
+                                   created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);